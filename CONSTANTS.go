@@ -35458,59 +35458,62 @@ EKDMKPRKAAA=
 	"/resources/describe/sparta.js": {
 		name:    "sparta.js",
 		local:   "resources/describe/sparta.js",
-		size:    2259,
-		modtime: 1582429517,
-		compressed: `
-H4sIAAAAAAAC/4xWf28iNxD9fz/F6C6SbR3stWpVqUT5gwCnUiXhBDRVdTpFxjvs+mLsldfAbSu+e2Xv
-DxaSQ4kUWM+8eR6/Gc+y4xYWk/njdDR5ehjeT+AGyPJ2TCLvEKUzheA5Pkrcww3orVLXURStt1o4aTQU
-mdl7H9W4nyjcoHbTMYP/IoArSt7vJO77wmjHpUZLWCwyqRKLmrI4kwlSVgM1391yO3W4KU5RFjdmhyPF
-i4ISLpzcIfFBHz/CAh24DMHxFVSeXlj7TWtDBGE1HftTvSfwAbp5wgcgfe8nEXiWSzDHVyTkWvGx2J+8
-Tj+EspgnyXmehyi6ookRW8/kD8OTkrbi0UqoILQy22Rt7IZ7zxI3ueIOa8V9drYMWPgx8s/F7CHOuS2Q
-ju5mf40/zeb3w+V09vC0nNx/vhsuJ0/z4d8+4wMI7kQGFFlDanRhFMbKpJR84lJhAs5AYANX7zEArwzG
-ziyclTqljF3OqOIGmMzns/ngJDJ4DlH17wX4VhhdOZujFGEl1yV9fYNe0KYHv7Lruoks3zfOkdEOtSMs
-dvjd0SO73zlT34pYaun+kGmmZJo5qdOZvjM8oQ1ZbhGESZCwGLnIOkWTPVgpI54b7QJb1jDdehetAP54
-LDqpnm/bzOxDn7aXK/RoJeXZfdtLnZh9fG5u17SRuL1jg6BECxhPH5fcpugI69VQrPq6GMDon+VsMRp+
-njyNh8th4y9cqXAAXxrqYEOFwhk7AKK9KL2ur8J34QCkyHiOZADEmq1O+haF4zpVp6EARNSFGgBJuONU
-8RUqdo5acfGcVkxyw1Ns4WF1Cb6Xics8/Offf8u/XwBm6Mv3FuRaBpgwO7QXYCbnQrrSQ386gR3a58PR
-/AO1MUnfovbbVGy0+OXMvpFJ34Uu6XNrzb7fFs9Z+bJqnfzrp6+NW/HSbF03Pc03fnCs/Ohz2VrawrVk
-VfghXLnjTLL2tan07jiV/M2VXMl/EZzJjTJpGW7QAN75+WQt68yVKqNFreh0XMANfCHvK3P/lbRaX8JT
-i+dGYYoXttTK5AVOWqFeidYCtbNS+HfJV3/uF/nFa2MnpxPHD6D6bdRIc3VijIWS4rkbsetgAcIyzm34
-HuOab5WrJp3/O8q0LHM/tzvUcZEr6SjpExbnJj8GndTmLkSDK3OsinCkOwZ0Z1i84c9YRdHzXjnGtn3C
-YrvVzd51v4TP9ucHUXyzSjhh19GB/R8AAP//p84xj9MIAAA=
+		size:    2748,
+		modtime: 1792053521,
+		compressed: `
+H4sIAAAAAAACA41WbY/iNhD+zq+Y3q4uiQq5Vq0qldN+YIFTqXaXE9CtqtNpZRJDfOvYkW3gaI//3pk4
+CYF90SEti2eeGc+L57G3zMB8PLufDMcPd4PbMVxBsLgeBZ0tKpK90zZhBb8XfIcatZHyfafTWW1U4oRW
+YDO9I12o+G4sec6Vm4wi+K8DcBkGF1tU9RKtHBOKmyCKk0zI1HAVRnEmUh5GFVCx7TUzE8dze4oyPNdb
+PpTM2jBguOmWB2T07h3MuQOXcXBsCV7TLde0aSVAIK0mI8rqIoAfoR0nLoMe6QPEoZfXYKgOyli9vyim
+zKvwS9MoZml6Hueh08FAFxiUUMIJJkGyvd44yLTSxpbhVpJUGF7WNIZrw1nqMlgJYx058BALTO7Y3sJK
+6h04XeAfLLVzOu+C1SD5ypHIiHXmYGO5hZStDY+P7fKOpgUtbOhXi33Bfceo4142qoPBgoQOAXoFw38W
+0/lw8HH8cDteDOCHK6zVRqV8ha1NA3j79gwRn7mK4Ns3OlpURrGC1u5wRb6WPusy6dLdk1AIdTMLfLAA
+bQcQlKmS70OVifZZos7DFct5v2XUrbDPxeKd1RtVnmLD1COGgx7PQqs8Ge42RtVwav5lmOpkQ8eITjJL
+92HTivBY80TqTbrSJmekWfC8kMzxatzoaJp9FcmLyD/n07u4YMbycHgz/Wv0YTq7HSwm07uHxfj2481g
+MX6YDf6m43qAhLkkg5DX6eF8Wi15LPU6DD4wIXlKx6j0Bq7aow80Fjx2eu6MUOswil6PyPsGGM9m01n/
+xLLUHFqt+mK18so6FVuuxGofPr9Bt6xNF36N3lcMYtiuVg6RcLDkSCSOf3Xh0TvtnMkvNqZp/APHRNKo
+oGKqbjRLw9pZYThWJcUTEHOWZK2miS4spU4e69qV3rLa0zWpQg+g9KLOSfeIszIaXZz6hllLgvKlPCPb
+nVCp3sXn4mYd1iVuCLZfVqIBjCb3C2bWHCvRraDck5rtt4Z1NFgMar11e4m9/lS7LmVolDiN3gNFRem2
+dR7fhgMENsPdA8QbjQzRoylhai1PTRGXVI3q08Q5hmO45DI6Ry1Z8rj2nkTO1ryBl6vX4DuBhELwn3//
+rfj6CjDj1L7vQa5ECUvwUjKvwHTBEuH2BP3pBHZofh+O4heqzdP191T7+6pY1+KXM3kuUrzc6JT0mDF6
+12uahyPztGut+Ktfn2u1J8X+2SVzyuvV7JeDdmQiY57jojdHLqpuT/Evp3tPo3Zfzk0f3hAroX2LTfz+
+86qOkxFdAZ+CCy/unYTjQ290nvbPhIm2T2RrI9InOGES+Yy1SrA5RiR0N32mvJ/EFyO9jU95hmineoDU
+pbk8EcaJFMg1LYttC4uDTssYiYz+j/iKbaTz/EafY5mq67PlOraFFC4Mesh+hS6ORie9ufGvFnob+Ca0
+HhONQZu54pw9cm8VvvgMwVtyo+odq1NSfjfvzECyfJmyAKWH6H//zAJlvAoAAA==
 `,
 	},
 
 	"/resources/describe/template.html": {
 		name:    "template.html",
 		local:   "resources/describe/template.html",
-		size:    4283,
-		modtime: 1558591067,
-		compressed: `
-H4sIAAAAAAAC/7RYbW/bOBL+nl8xZXHYl1tZaW937+BIBrJOc9te0izWbheHxSIYiWOZDUUKJGXHNfzf
-D5QsWZLTNpe7zReLw+HDmWdGM6NEz7hO3aYgWLpcTk4i/wMSVRYzUmxychItCfnkBCDKySGkSzSWXMze
-zS+Df3gFgMgJJ2my3cJoRmYlUnqLOcFuF4X1TqX0LAhgOpvBQkiyEAQec7s1qDKC0XQ2u/Ty3a7V9esx
-bLejf9HG4+12+0ORdRsPCuABRhfocLfz8rDd2G5JcY/VgF3jHYEtDYFbEmRSJyjhzezmLaxQlmQBDYFQ
-wgmU4iPxE4CEFtoQrAmc2QAqDlIjr85zWpAxxDsnjG2tS40o3DFR3tzp1c27i8ubX6/P569v3t7OX13/
-cnU+f3X76/lvEIMncCp1yS+1ydEJreaUFxKd5/KkBvj3/GY2Pf/l1e3F+fy8ObNx2qZYkKcCdruzmozK
-jsmBgzezitMj7t+01H+Z+do3nzAxc3Tvwg+4wlq6dzEMIXr2+9Sb9/tRiPz2H39MevZ1otWPbfgtzJZ6
-DcKBsLAQ98TB6SoAThfwbVhpJZpvYFs9AuRCBUsS2dKN4e8/GMrP9hsFci5UFjhdjOH70WFrz+vIFmgc
-XuFGl66FW2jlggXmQm7GwH5GeydFxr4D9i4plSvhWivtl9ekZPUw1aURZOAtrf3yqkwFR5hqZbUkdtaF
-teIjjeHFy+K+Z8jztA3m6/dzNBkdzFkL7pZjeHF6+pcGqnG2Kyu0FT55xoCJ1bJ09EUWACrRaWMMgKSF
-6wh671cU1jXhJPLkV9FUuIJUorUxU7hK0ED9E9B9gYoHOW8EHM1dHUxvBiRZJdknT4R9lCAxqDiDpaFF
-zJ6zB2sM7s9y0dqQaimxsNRc2qwZCN5gTxvZZO9yVMpm+yc0rx3llg3M8W7mJsDS6fYYQCRFRy8QjvL6
-Iol5wjFwmHS0B14GUqi71sP6BAOtUinSu5jZpV6/F7T++qt666tvelAAkcgzsCaNGUeHY5FjRqFdZX+9
-z+VZgpZ+/P677RaE4nQPo9d++xoLn6p5UTq6Pf9tdlUh39Y/l6VKffaM7Cpjux2rsy5mf3vJ9tlWPfds
-gMYfHgglhaIgkTq9A5QiUz7MDFC6mDEIJ1BfY7t8NCGsF1J8llrgRhdcr9WQiCNWW83A6SyTNDS6yaqB
-+JAiF81NAw2jJcUsKZ3TR3s+Cvv7YsY/AYBGYLBEW+iiLGLmTEkPqtRvD/GYLVB2UrX5q+tVn4YumcP3
-oiUkJ1Wy+g6JCUlJPNkcuT287kBxC9RNdm9LkBhC7pYLYazrvLg/1WK49PIjGx8HzTEz1MG88OsnYqXa
-dqGmN7NXT0TKjOhWqH8awZ9qkzCp7FlVCZ7soUpJOSPSLmIrPM6TkItVr0wN38Tepi1QQdUQYsaFLSRu
-xtB9+8/2/epFcX/W9KmqTUGC6V1mdKn4+Pnp6ekZ5GgyocZwCr4dskkUevT/oibUjqd+dlo0s1Pg9sPT
-I+ovtBR9AuPBivwJ3f9bib5GhRnlpNxca2l9qe6Ph39aje5fA80Y+slCHYWl7LbRA73Q7ZzyMZ3zS53S
-VUNRzG4TiYfOuXSusOMwzHQ9yY2EfkwUCpV9LgKzCutnkjm5lz/8OCrU5/j+IsPDEl/xPYH6lic3xP+V
-MeGWZTJKdR7ma8JMUlgbFKY6z4ULq5mrkrwnY30+7HZDbm2OUpJ1w0L19UOHvxnUnYcOPyLV2oIVhQpX
-9UdmjkLt27N/ZIeBUDkUikywkKXgrDMy+sqxErQOWp3DRNjs7wc5r9bN3mb7eGb3NaxTTvuL7qCKhn++
-eA3uPD4e+CF8GI+ip+G/1I6beWGo0VrKD/ZIASBKNW91PlitalMNrpuKMNXKkarc9bpHd4SFoWGbKfpx
-fpimTnB9IOun+nMjCqv/VfwnAAD//8ok1O67EAAA
+		size:    4327,
+		modtime: 1792053521,
+		compressed: `
+H4sIAAAAAAACA7VYbW/bNhD+nl9xVTH0ZZXldm03OLaB1EnWdHFS1G6LoSgCSqRlNhQpkFQcL/B/31Gy
+ZEnO27ItXyzeG4/PHe+O6T+iKrLLlMHcJmK403c/IIiMBx6T3nAHKYzQ4Q5AP2GWQDQn2jA78D5PD/3f
+nAByLLeCDa+uoDNh+oJH7IQkDFarflBwcqFHvg+jyQRmXDADvu9sXl1p3IpBBxmHjr5aVbJu3UOJzh9s
+6eytVmulvrFLZxTAGejsE0tQDelBxbi6YpI6W6WxMTlnYDLNwM4ZxEKFRMCHyekJXBCRoT8EWVxyy4ng
+fzGKeiGbKSQuUEUvgUgKQhGa61M2Y1ozWtPQpvIu0jy120A5d0fHp5/3D08/jfemR6cnZ9OD8cfjvenB
+2ae9rzAAB+BIqIweKp0Qy5WcsiQVxDosdwoDf05PJ6O9jwdn+3vTvVJnaZWJSMocFCi625YdH2zLjtla
+Ngcu93m4wevDJMd/K04fqjDdHaUCB5dcA8+ySxv8IBekoK7hCAI08m3kjvJtK5yO/f37sOFfLbLNPAie
+w2SuFsAtcIMpdonBsSoPllUpPA9yqVDRJVzlnwAJl/6c8Xhue/DrG82S3TUjJZRyGfuo2IPXnQ1rHYOO
+SYm25JgsVWYrczMlrT8jCRfLHnjviTkXPPZegPc5zKTNYKykcssxkyL/GKlMc6bhhC3c8jiLOCUwUtIo
+wbzdulmDCdaDl6/Sy4Yjj6Mq8EdfpkTHbOPOglM7R51u96fSVHnYOi1VhrtE6wEJcd/MsjtRAMhJ3dIZ
+AMFmtkZo3MV+UNSPnb4DP4+mJBcQCWLMwMPPkGgofnx2meI18xNaEijR50UwnRsQxjllnTx90rTih5ij
+1IO5ZrOB99i7th6RtS7llQ+REoKkhpWblmsPOC1tj0racH3kfiZK9juijyxLjNdyxx0z0T7JrKrUUFHw
+mpzPUbPYSJAkpMS3JKxJt07pCy7PqxMWGh4oGQkenQ88gzfgC2eLp08K1pNnDVNojCcxGB0NPIq3rMcT
+ErPAXMQ/XyZiNySGvX39AlHjkrJL6Bw59pikLlWTFFPjbO/r5Di3fFb8HGYyctnTQRveauUVWTfwfnnl
+rbMt/274AOV5qM8lnof5oVDROWAVjaULs4efqOdBMIRiG1PHowxhsRD8VmiBapVStZBtILZQrSTRhTjG
+C9hyusyqFnmTIvvlTi0Jjbd54IWZtWqL56Kw3g/xuMEA0Zz4c2JSlWYpVlKdsWtFitvD0KEZEbVULf+K
+etWEoQ5m+15UgCRMZl6xhyAhE4LRcLl17PZ2G4grQ/Vkd77gjcXaYOczro2tXdx3BRl7C9K3fLyfaUpi
+zWo29936gbYiZeqmRqeTgwdaijWvV6jfcflQn7iORMOrnPDgE8qISat5VLdYEbfzJMBEaZSp9k1sMLFh
+SsgbAjrBDY402CPrt3933a9eYg8p+1TepiAk0XmsVSZp73G3292FBPscx37VBdcOvSF2GrT+D2pCcfDI
+zVmzcs7y7XrQukf9hQqiG2xcW5FvkP3PSvSYSPzEq2qnSgnjSnVzlPzfanRzGyhH1hsLdT/IRL2NbuCF
+eucU9+mcd3VKmw9FA+8sxCdN1Tnn1qamFwSxKia5Dlf3iUIq49siMMltvWcC30mv3rztoPgteN+JcLvE
+53gPodjlwQ3x3yLG7TwLO5FKgmTBCPatoHAoQFLCbZDPXDnlCz6JXD4gBC1sTUKwhxjbLlRPr1N+1qo7
+1ynfI9WqgtUP8KzFgzQhXK7bs/v0NgOhtLhm2p+JDOt1bWR0leMC77NfyWwmwpK/HuScWD17S/b2zO5q
+WK2cNhf1QZVoenvxau25re67Ibwdj7Qh4V5q2808xZfwWmoufpgtARSJFK1kfhgcdnJXNVmUFQH7icX6
+5I7rZLf2CHCTdptJm3G+HqZacF0gi6/iuYHPD/d/jb8BhaYPNucQAAA=
 `,
 	},
 
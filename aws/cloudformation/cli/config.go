@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// defaultConfigFileName is the name of the optional YAML file in the user's
+// home directory that supplies default flag values
+const defaultConfigFileName = ".sparta-link.yaml"
+
+// defaultConfigFilePath returns the path to the per-user config file, or
+// the empty string if the home directory can't be determined
+func defaultConfigFilePath() string {
+	homeDir, homeDirErr := os.UserHomeDir()
+	if homeDirErr != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, defaultConfigFileName)
+}
+
+// applyConfigFile reads the YAML file at configPath and uses its values
+// as defaults for every flag that wasn't explicitly set on the command
+// line. Keys are flag names and values are either scalars or, for
+// repeatable flags, lists. When mustExist is false a missing file is
+// not an error.
+func applyConfigFile(cmd *cobra.Command, configPath string, mustExist bool) error {
+	if configPath == "" {
+		return nil
+	}
+	flags := cmd.Flags()
+	configBytes, configBytesErr := ioutil.ReadFile(configPath)
+	if configBytesErr != nil {
+		if os.IsNotExist(configBytesErr) && !mustExist {
+			return nil
+		}
+		return errors.Wrapf(configBytesErr, "Attempting to read config file: %s", configPath)
+	}
+	configValues := make(map[string]interface{})
+	unmarshalErr := yaml.Unmarshal(configBytes, &configValues)
+	if unmarshalErr != nil {
+		return errors.Wrapf(unmarshalErr, "Attempting to parse config file: %s", configPath)
+	}
+	for eachKey, eachValue := range configValues {
+		flag := flags.Lookup(eachKey)
+		if flag == nil {
+			return errors.Errorf("Config file %s contains unknown flag: %s", configPath, eachKey)
+		}
+		// Command line flags take precedence
+		if flag.Changed {
+			continue
+		}
+		values := []interface{}{eachValue}
+		if listValues, isList := eachValue.([]interface{}); isList {
+			values = listValues
+		}
+		for _, eachFlagValue := range values {
+			setErr := flags.Set(eachKey, fmt.Sprintf("%v", eachFlagValue))
+			if setErr != nil {
+				return errors.Wrapf(setErr, "Invalid config file value for flag: %s", eachKey)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func testConfigCommand() (*cobra.Command, *optionsLinkStruct) {
+	options := &optionsLinkStruct{}
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().StringArrayVar(&options.StackNames, "stackName", nil, "")
+	cmd.Flags().StringVar(&options.OutputDirectory, "output", "", "")
+	return cmd, options
+}
+
+func TestConfigFileDefaults(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-config")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	configPath := filepath.Join(tempDir, defaultConfigFileName)
+	configBody := "stackName: ConfigStack\noutput: /tmp/fromConfig\n"
+	writeErr := ioutil.WriteFile(configPath, []byte(configBody), 0644)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	cmd, options := testConfigCommand()
+	parseErr := cmd.Flags().Parse([]string{"--output", "/tmp/fromFlag"})
+	if parseErr != nil {
+		t.Fatal(parseErr)
+	}
+	applyErr := applyConfigFile(cmd, configPath, true)
+	if applyErr != nil {
+		t.Fatal(applyErr)
+	}
+	if len(options.StackNames) != 1 || options.StackNames[0] != "ConfigStack" {
+		t.Fatalf("Expected config file stackName, got: %v", options.StackNames)
+	}
+	if options.OutputDirectory != "/tmp/fromFlag" {
+		t.Fatalf("Expected flag to override config file output, got: %s", options.OutputDirectory)
+	}
+}
+
+func TestConfigFileMissing(t *testing.T) {
+	cmd, _ := testConfigCommand()
+	missingPath := filepath.Join(os.TempDir(), "link-config-missing.yaml")
+	if err := applyConfigFile(cmd, missingPath, false); err != nil {
+		t.Fatalf("Expected optional missing config to be ignored: %s", err)
+	}
+	if err := applyConfigFile(cmd, missingPath, true); err == nil {
+		t.Fatalf("Expected explicit missing config to fail")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/mweagle/Sparta/aws/cloudformation/link"
+	"github.com/pkg/errors"
+)
+
+// consolidatedOutputsFileName is the basename of the file written by
+// --consolidated-outputs
+const consolidatedOutputsFileName = "consolidated-outputs"
+
+// consolidatedOutputs returns the outputs of every stack keyed by stack
+// name, so that the same output key in different stacks doesn't collide.
+// A stack whose name is already in use, such as a deleted stack described
+// by ID, is keyed by its stack ID instead.
+func consolidatedOutputs(stacks []*cloudformation.Stack) map[string]map[string]string {
+	outputs := make(map[string]map[string]string, len(stacks))
+	summaries := link.SummarizeStacks(&cloudformation.DescribeStacksOutput{
+		Stacks: stacks,
+	})
+	for eachIndex, eachSummary := range summaries {
+		stackKey := eachSummary.StackName
+		if _, exists := outputs[stackKey]; exists {
+			stackKey = aws.StringValue(stacks[eachIndex].StackId)
+		}
+		outputs[stackKey] = eachSummary.Outputs
+	}
+	return outputs
+}
+
+// writeConsolidatedOutputs writes the outputs of every described stack,
+// including any nested stacks described with --recurse, to a single
+// consolidated-outputs file in the output directory
+func writeConsolidatedOutputs(stacks []*cloudformation.Stack,
+	options optionsLinkStruct,
+	w io.Writer) error {
+	outputsInfo, outputsInfoErr := link.MarshalOutput(consolidatedOutputs(stacks), options.Format)
+	if outputsInfoErr != nil {
+		return errors.Wrap(outputsInfoErr, "Failed to serialize consolidated outputs")
+	}
+	outputsFilepath := filepath.Join(options.OutputDirectory,
+		fmt.Sprintf("%s.%s",
+			consolidatedOutputsFileName,
+			link.OutputFileExtension(options.Format)))
+	outputsFilepath, outputErr := emitOutputFile(outputsFilepath, outputsInfo, options)
+	if outputErr != nil {
+		return errors.Wrap(outputErr, "Attempting to write consolidated outputs file")
+	}
+	fmt.Fprintln(w, outputFileMessage(outputsFilepath, options))
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func TestDescribeConsolidatedOutputs(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-outputs")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mockClient := &mockCloudFormationClient{
+		resources: map[string][]*cloudformation.StackResource{
+			"ParentStack": {nestedStackResource(testChildStackID)},
+		},
+		// Both stacks define a BucketName output
+		outputs: map[string][]*cloudformation.Output{
+			"ParentStack": {{
+				OutputKey:   aws.String("BucketName"),
+				OutputValue: aws.String("parent-bucket"),
+			}},
+			testChildStackID: {{
+				OutputKey:   aws.String("BucketName"),
+				OutputValue: aws.String("child-bucket"),
+			}, {
+				OutputKey:   aws.String("QueueURL"),
+				OutputValue: aws.String("https://sqs.us-east-1.amazonaws.com/123456789012/child"),
+			}},
+		},
+	}
+	options := optionsLinkStruct{
+		StackNames:          []string{"ParentStack"},
+		OutputDirectory:     tempDir,
+		Recurse:             true,
+		MaxDepth:            defaultMaxNestedDepth,
+		ConsolidatedOutputs: true,
+	}
+	describeErr := describeStacks(mockClient, options, ioutil.Discard)
+	if describeErr != nil {
+		t.Fatalf("Failed to describe stacks: %s", describeErr)
+	}
+	outputBytes, outputErr := ioutil.ReadFile(filepath.Join(tempDir, consolidatedOutputsFileName+".json"))
+	if outputErr != nil {
+		t.Fatalf("Expected consolidated outputs file: %s", outputErr)
+	}
+	var outputs map[string]map[string]string
+	unmarshalErr := json.Unmarshal(outputBytes, &outputs)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal consolidated outputs: %s", unmarshalErr)
+	}
+	if len(outputs) != 2 ||
+		outputs["ParentStack"]["BucketName"] != "parent-bucket" ||
+		outputs["ChildStack"]["BucketName"] != "child-bucket" ||
+		outputs["ChildStack"]["QueueURL"] == "" {
+		t.Fatalf("Expected the outputs of both stacks keyed by stack name. Found: %s", string(outputBytes))
+	}
+}
+
+func TestConsolidatedOutputsNameCollision(t *testing.T) {
+	deletedStackID := "arn:aws:cloudformation:us-east-1:123456789012:stack/MyStack/guid-deleted"
+	outputs := consolidatedOutputs([]*cloudformation.Stack{
+		{StackName: aws.String("MyStack"), StackId: aws.String("arn:aws:cloudformation:us-east-1:123456789012:stack/MyStack/guid-live")},
+		{StackName: aws.String("MyStack"), StackId: aws.String(deletedStackID)},
+	})
+	if _, exists := outputs["MyStack"]; !exists || len(outputs) != 2 {
+		t.Fatalf("Expected the live stack keyed by name. Found: %v", outputs)
+	}
+	if _, exists := outputs[deletedStackID]; !exists {
+		t.Fatalf("Expected the colliding stack keyed by ID. Found: %v", outputs)
+	}
+}
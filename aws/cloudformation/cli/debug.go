@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// writeSessionConfig writes the resolved region, credential provider and
+// CloudFormation endpoint for the session. Credential values are never
+// included.
+func writeSessionConfig(sess *session.Session, w io.Writer) error {
+	providerName := "none"
+	if sess.Config.Credentials != nil {
+		credsValue, credsValueErr := sess.Config.Credentials.Get()
+		if credsValueErr != nil {
+			providerName = fmt.Sprintf("unresolved (%s)", credsValueErr)
+		} else {
+			providerName = credsValue.ProviderName
+		}
+	}
+	clientConfig := sess.ClientConfig(cloudformation.EndpointsID)
+	_, writeErr := fmt.Fprintf(w,
+		"Region: %s\nCredential provider: %s\nEndpoint: %s\n",
+		aws.StringValue(sess.Config.Region),
+		providerName,
+		clientConfig.Endpoint)
+	return writeErr
+}
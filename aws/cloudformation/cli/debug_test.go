@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestWriteSessionConfig(t *testing.T) {
+	sess, sessErr := session.NewSession(&aws.Config{
+		Region:      aws.String("us-west-2"),
+		Credentials: credentials.NewStaticCredentials("AKIDEXAMPLE", "SECRETEXAMPLE", ""),
+	})
+	if sessErr != nil {
+		t.Fatalf("Failed to create session: %s", sessErr)
+	}
+	output := &bytes.Buffer{}
+	writeErr := writeSessionConfig(sess, output)
+	if writeErr != nil {
+		t.Fatalf("Failed to write session config: %s", writeErr)
+	}
+	debugOutput := output.String()
+	for _, eachExpected := range []string{"us-west-2", credentials.StaticProviderName} {
+		if !strings.Contains(debugOutput, eachExpected) {
+			t.Fatalf("Expected debug output to contain %s. Found: %s", eachExpected, debugOutput)
+		}
+	}
+	if strings.Contains(debugOutput, "SECRETEXAMPLE") {
+		t.Fatalf("Debug output must not contain secret values: %s", debugOutput)
+	}
+}
@@ -3,13 +3,14 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
-	"path/filepath"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/mweagle/Sparta/aws/cloudformation/link"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	validator "gopkg.in/go-playground/validator.v9"
@@ -20,12 +21,58 @@ var validate *validator.Validate
 /******************************************************************************/
 // Global options
 type optionsLinkStruct struct {
-	StackName       string `validate:"required"`
-	OutputDirectory string `validate:"required"`
+	StackNames          []string
+	OutputDirectory     string
+	ConfigFile          string
+	SummaryOnly         bool
+	DebugConfig         bool
+	Gzip                bool
+	Format              string
+	IncludeTemplate     bool
+	IncludeEvents       bool
+	MaxEvents           int
+	Recurse             bool
+	MaxDepth            int
+	ConsolidatedOutputs bool
+	ListOnly            bool
+	Stdout              bool
+	NDJSON              bool
+	Region              string
+	Profile             string
+	RoleArn             string
+	Statuses            []string
+	Tags                []string
+	Verbose             bool
+	ExternalID          string
+	DryRun              bool
+	Offline             bool
+	MaxRetries          int
 }
 
 var optionsLink optionsLinkStruct
 
+// cfnDescriber is the subset of the CloudFormation API used by the link
+// command
+type cfnDescriber interface {
+	link.StackDescriber
+	GetTemplate(*cloudformation.GetTemplateInput) (*cloudformation.GetTemplateOutput, error)
+	DescribeStackEvents(*cloudformation.DescribeStackEventsInput) (*cloudformation.DescribeStackEventsOutput, error)
+	DescribeStackResources(*cloudformation.DescribeStackResourcesInput) (*cloudformation.DescribeStackResourcesOutput, error)
+	ListStacks(*cloudformation.ListStacksInput) (*cloudformation.ListStacksOutput, error)
+}
+
+// newCFNDescriber returns the CloudFormation client used by RunE. Tests
+// replace it to avoid calling AWS. The SDK retryer is disabled so that
+// withThrottleRetries, and therefore --maxRetries, is the only retry
+// policy.
+var newCFNDescriber = func(sess *session.Session) cfnDescriber {
+	return cloudformation.New(sess, aws.NewConfig().WithMaxRetries(0))
+}
+
+// allStacksFileName is the output file basename used when no --stackName
+// is provided and every stack in the region is described
+const allStacksFileName = link.AllStacksFileName
+
 // RootCmd represents the root Cobra command invoked for the discovery
 // and serialization of an existing CloudFormation stack
 var RootCmd = &cobra.Command{
@@ -33,64 +80,405 @@ var RootCmd = &cobra.Command{
 	Short: "Link is a tool to discover and serialize a prexisting CloudFormation stack",
 	Long:  "",
 	PreRunE: func(cmd *cobra.Command, args []string) error {
+		// Fill in any defaults from the config file. An explicit --config
+		// path must exist, the per-user default is optional.
+		configPath := optionsLink.ConfigFile
+		if configPath == "" {
+			configPath = defaultConfigFilePath()
+		}
+		configErr := applyConfigFile(cmd, configPath, optionsLink.ConfigFile != "")
+		if configErr != nil {
+			return configErr
+		}
 		validateErr := validate.Struct(optionsLink)
 		if nil != validateErr {
 			return validateErr
 		}
-		// Make sure the output value is a directory
-		osStat, osStatErr := os.Stat(optionsLink.OutputDirectory)
-		if nil != osStatErr {
-			return osStatErr
+		formatErr := validateOutputFormat(optionsLink.Format)
+		if formatErr != nil {
+			return formatErr
 		}
-		if !osStat.IsDir() {
-			return errors.Errorf("--output (%s) is not a valid directory", optionsLink.OutputDirectory)
+		if optionsLink.IncludeTemplate && optionsLink.SummaryOnly {
+			return errors.New("--includeTemplate cannot be combined with --summary-only")
 		}
-		return nil
+		if optionsLink.IncludeEvents && optionsLink.SummaryOnly {
+			return errors.New("--includeEvents cannot be combined with --summary-only")
+		}
+		if len(optionsLink.Statuses) != 0 {
+			statusErr := validateStackStatuses(optionsLink.Statuses)
+			if statusErr != nil {
+				return statusErr
+			}
+		}
+		_, tagsErr := parseStackTags(optionsLink.Tags)
+		if tagsErr != nil {
+			return tagsErr
+		}
+		if optionsLink.Recurse && optionsLink.SummaryOnly {
+			return errors.New("--recurse cannot be combined with --summary-only")
+		}
+		if optionsLink.Recurse && optionsLink.MaxDepth < 1 {
+			return errors.Errorf("--maxDepth (%d) must be at least 1", optionsLink.MaxDepth)
+		}
+		if optionsLink.MaxRetries < 0 {
+			return errors.Errorf("--maxRetries (%d) must not be negative", optionsLink.MaxRetries)
+		}
+		if optionsLink.MaxEvents < 0 {
+			return errors.Errorf("--maxEvents (%d) must not be negative", optionsLink.MaxEvents)
+		}
+		if optionsLink.Region != "" {
+			regionErr := validateRegion(optionsLink.Region)
+			if regionErr != nil {
+				return regionErr
+			}
+		}
+		if optionsLink.RoleArn != "" {
+			roleErr := validateRoleARN(optionsLink.RoleArn)
+			if roleErr != nil {
+				return roleErr
+			}
+		} else if optionsLink.ExternalID != "" {
+			return errors.New("--externalId requires --roleArn")
+		}
+		dryRunErr := validateDryRunOptions(optionsLink)
+		if dryRunErr != nil {
+			return dryRunErr
+		}
+		if optionsLink.ListOnly {
+			// Nothing is written, so --output isn't required
+			return validateListOnlyOptions(optionsLink)
+		}
+		if optionsLink.Stdout {
+			return validateStdoutOptions(optionsLink)
+		}
+		if optionsLink.NDJSON {
+			return errors.New("--ndjson requires --stdout")
+		}
+		if optionsLink.OutputDirectory == "" {
+			return errors.New("--output is required unless --stdout is set")
+		}
+		if optionsLink.DryRun {
+			// Don't create the directory for a run that doesn't write files
+			return nil
+		}
+		return ensureOutputDirectory(optionsLink.OutputDirectory)
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if optionsLink.Offline {
+			return planOfflineStacks(optionsLink, os.Stdout)
+		}
 		// Get the output and stuff it to a file
-		sess, err := session.NewSession()
+		sess, err := newSession(optionsLink.Region, optionsLink.Profile)
 		if err != nil {
 			return errors.Wrap(err, "Attempting to create session")
 		}
+		sess = assumeRoleSession(sess, optionsLink.RoleArn, optionsLink.ExternalID)
+		if optionsLink.DebugConfig {
+			err = writeSessionConfig(sess, os.Stderr)
+			if err != nil {
+				return errors.Wrap(err, "Attempting to write session config")
+			}
+		}
 
-		svc := cloudformation.New(sess)
+		svc := withThrottleRetries(newCFNDescriber(sess), optionsLink.MaxRetries)
+		if optionsLink.ListOnly {
+			return listStacks(svc, optionsLink, os.Stdout)
+		}
+		if optionsLink.Stdout {
+			return streamStacks(svc, optionsLink, os.Stdout)
+		}
+		return describeStacks(svc, optionsLink, os.Stdout)
+	},
+}
 
-		params := &cloudformation.DescribeStacksInput{
-			StackName: aws.String(optionsLink.StackName),
+// stackDisplayName returns the name used to identify stackName in
+// messages. An empty stackName describes every stack.
+func stackDisplayName(stackName string) string {
+	if stackName == "" {
+		return "all stacks"
+	}
+	return stackName
+}
+
+// linkDescribeOptions returns the link.DescribeOptions that serialize a
+// stack as requested by the command line options
+func linkDescribeOptions(options optionsLinkStruct) (link.DescribeOptions, error) {
+	tags, tagsErr := parseStackTags(options.Tags)
+	if tagsErr != nil {
+		return link.DescribeOptions{}, tagsErr
+	}
+	return link.DescribeOptions{
+		Format:      options.Format,
+		Gzip:        options.Gzip,
+		SummaryOnly: options.SummaryOnly,
+		Tags:        tags,
+		Statuses:    options.Statuses,
+		DryRun:      options.DryRun,
+	}, nil
+}
+
+// stackOutputPath returns the path of the file describeStack writes for
+// stackName, before any --gzip extension. An empty stackName describes
+// every stack.
+func stackOutputPath(stackName string, options optionsLinkStruct) string {
+	return link.StackOutputPath(options.OutputDirectory, stackName, options.Format)
+}
+
+// describeStack writes the description of a single stack to the output
+// directory with link.DescribeStack and returns the path of the created
+// file together with the described stacks. An empty stackName describes
+// every stack. The path is empty if no stack matched, in which case
+// nothing was written.
+func describeStack(svc cfnDescriber,
+	stackName string,
+	options optionsLinkStruct,
+	w io.Writer) (string, []*cloudformation.Stack, error) {
+	describeOptions, describeOptionsErr := linkDescribeOptions(options)
+	if describeOptionsErr != nil {
+		return "", nil, describeOptionsErr
+	}
+	result, resultErr := link.DescribeStack(svc,
+		stackName,
+		options.OutputDirectory,
+		describeOptions)
+	if resultErr != nil {
+		return "", nil, stackNotFound(stackName, resultErr)
+	}
+	if !result.HasStacks() {
+		return "", nil, nil
+	}
+	switch {
+	case options.DryRun:
+		for _, eachStack := range result.Response.Stacks {
+			fmt.Fprintf(w, "Would describe stack: %s\n", aws.StringValue(eachStack.StackName))
+		}
+	case options.SummaryOnly:
+		summaryErr := writeStreamValue(w, result.Summaries, options.Format)
+		if summaryErr != nil {
+			return "", nil, errors.Wrap(summaryErr, "Attempting to write stack summary")
+		}
+	default:
+		fmt.Fprintln(w, result.Response)
+	}
+	if options.IncludeTemplate {
+		templateErr := writeStackTemplates(svc, result.Response.Stacks, options, w)
+		if templateErr != nil {
+			return "", nil, templateErr
 		}
-		describeStacksResponse, describeStacksResponseErr := svc.DescribeStacks(params)
+	}
+	if options.IncludeEvents {
+		eventsErr := writeStackEvents(svc, result.Response.Stacks, options, w)
+		if eventsErr != nil {
+			return "", nil, eventsErr
+		}
+	}
+	return result.OutputPath, result.Response.Stacks, nil
+}
+
+// describeTarget is a stack to describe and its nesting depth relative to
+// the --stackName stack that referenced it
+type describeTarget struct {
+	stackName string
+	depth     int
+}
 
-		if describeStacksResponseErr != nil {
-			return describeStacksResponseErr
+// describeStacks describes the stacks returned by targetStackNames. With
+// --recurse, nested stacks are described as well, up to --maxDepth levels
+// deep. A failure to describe one stack doesn't prevent the others from
+// being described. The returned error summarizes every failure.
+func describeStacks(svc cfnDescriber,
+	options optionsLinkStruct,
+	w io.Writer) error {
+	stackNames, stackNamesErr := targetStackNames(svc, options)
+	if stackNamesErr != nil {
+		return stackNamesErr
+	}
+	if len(stackNames) == 0 {
+		fmt.Fprintf(w, "No stacks match --status %s\n", strings.Join(options.Statuses, ", "))
+		return nil
+	}
+	pending := make([]describeTarget, 0, len(stackNames))
+	for _, eachStackName := range stackNames {
+		pending = append(pending, describeTarget{stackName: eachStackName})
+	}
+	// Stack names and IDs that have been described, so that nested stack
+	// cycles terminate
+	described := make(map[string]bool)
+	describeCount := 0
+	var describedStacks []*cloudformation.Stack
+	var failures []error
+	for len(pending) != 0 {
+		target := pending[0]
+		pending = pending[1:]
+		if target.stackName != "" && described[target.stackName] {
+			continue
 		}
+		described[target.stackName] = true
+		describeCount++
 
-		stackInfo, stackInfoErr := json.Marshal(describeStacksResponse)
-		if stackInfoErr != nil {
-			return errors.Wrapf(stackInfoErr, "Failed to describe stacks")
+		outputFilepath, stacks, describeErr := describeStack(svc, target.stackName, options, w)
+		if describeErr != nil {
+			failures = append(failures, errors.Wrap(describeErr, stackDisplayName(target.stackName)))
+			continue
 		}
-		outputFilepath := filepath.Join(optionsLink.OutputDirectory, fmt.Sprintf("%s.json", optionsLink.StackName))
-		err = ioutil.WriteFile(outputFilepath, stackInfo, 0644)
-		if nil != err {
-			return errors.Wrap(err, "Attempting to write output file")
+		if outputFilepath == "" {
+			fmt.Fprintf(w, "No matching stacks: %s\n", stackDisplayName(target.stackName))
+			continue
 		}
-		fmt.Println("Created file: " + outputFilepath)
-		fmt.Println(describeStacksResponse)
-		return nil
-	},
+		fmt.Fprintln(w, outputFileMessage(outputFilepath, options))
+		describedStacks = append(describedStacks, stacks...)
+		for _, eachStack := range stacks {
+			described[aws.StringValue(eachStack.StackId)] = true
+			described[aws.StringValue(eachStack.StackName)] = true
+		}
+		if !options.Recurse || target.depth >= options.MaxDepth {
+			continue
+		}
+		nestedStackIDs, nestedErr := nestedStackIDs(svc, stacks)
+		if nestedErr != nil {
+			failures = append(failures, errors.Wrap(nestedErr, stackDisplayName(target.stackName)))
+			continue
+		}
+		for _, eachNestedID := range nestedStackIDs {
+			pending = append(pending, describeTarget{
+				stackName: eachNestedID,
+				depth:     target.depth + 1,
+			})
+		}
+	}
+	if options.ConsolidatedOutputs && len(describedStacks) != 0 {
+		outputsErr := writeConsolidatedOutputs(describedStacks, options, w)
+		if outputsErr != nil {
+			return outputsErr
+		}
+	}
+	return describeFailuresError(failures, describeCount)
+}
+
+// validateStdoutOptions returns an error if any option that only applies
+// to file output is combined with --stdout
+func validateStdoutOptions(options optionsLinkStruct) error {
+	if options.IncludeTemplate {
+		return errors.New("--includeTemplate cannot be combined with --stdout")
+	}
+	if options.IncludeEvents {
+		return errors.New("--includeEvents cannot be combined with --stdout")
+	}
+	if options.Recurse {
+		return errors.New("--recurse cannot be combined with --stdout")
+	}
+	if options.ConsolidatedOutputs {
+		return errors.New("--consolidated-outputs cannot be combined with --stdout")
+	}
+	if options.Gzip {
+		return errors.New("--gzip cannot be combined with --stdout")
+	}
+	if options.NDJSON && options.Format == outputFormatYAML {
+		return errors.New("--ndjson requires --format json")
+	}
+	return nil
+}
+
+// streamStacks writes the description of the stacks returned by
+// targetStackNames to out. A single --stackName description is written as
+// is. Otherwise the descriptions are wrapped in an array so that the
+// stream remains parseable, or written one per line with --ndjson.
+func streamStacks(svc cfnDescriber,
+	options optionsLinkStruct,
+	out io.Writer) error {
+	stackNames, stackNamesErr := targetStackNames(svc, options)
+	if stackNamesErr != nil {
+		return stackNamesErr
+	}
+	describeOptions, describeOptionsErr := linkDescribeOptions(options)
+	if describeOptionsErr != nil {
+		return describeOptionsErr
+	}
+	var failures []error
+	serializedResponses := make([]interface{}, 0, len(stackNames))
+	for _, eachStackName := range stackNames {
+		result, resultErr := link.Describe(svc, eachStackName, describeOptions)
+		if resultErr != nil {
+			failures = append(failures,
+				errors.Wrap(stackNotFound(eachStackName, resultErr), stackDisplayName(eachStackName)))
+			continue
+		}
+		serializedResponses = append(serializedResponses, result.Serialized())
+	}
+
+	var outputErr error
+	switch {
+	case options.NDJSON:
+		for _, eachResponse := range serializedResponses {
+			outputErr = json.NewEncoder(out).Encode(eachResponse)
+			if outputErr != nil {
+				break
+			}
+		}
+	case len(options.Statuses) == 0 && len(stackNames) == 1 && len(serializedResponses) == 1:
+		outputErr = writeStreamValue(out, serializedResponses[0], options.Format)
+	default:
+		outputErr = writeStreamValue(out, serializedResponses, options.Format)
+	}
+	if outputErr != nil {
+		return errors.Wrap(outputErr, "Attempting to write to stdout")
+	}
+	return describeFailuresError(failures, len(stackNames))
+}
+
+// writeStreamValue marshals v in the given format and writes it to out
+// followed by a newline
+func writeStreamValue(out io.Writer, v interface{}, format string) error {
+	outputBytes, outputErr := link.MarshalOutput(v, format)
+	if outputErr != nil {
+		return outputErr
+	}
+	if len(outputBytes) == 0 || outputBytes[len(outputBytes)-1] != '\n' {
+		outputBytes = append(outputBytes, '\n')
+	}
+	_, writeErr := out.Write(outputBytes)
+	return writeErr
 }
 
 func init() {
 	validate = validator.New()
 	cobra.OnInitialize()
-	RootCmd.PersistentFlags().StringVar(&optionsLink.StackName, "stackName", "", "CloudFormation Stack Name/ID to query")
-	RootCmd.PersistentFlags().StringVar(&optionsLink.OutputDirectory, "output", "", "Output directory")
+	RootCmd.PersistentFlags().StringArrayVar(&optionsLink.StackNames, "stackName", nil, "CloudFormation Stack Name/ID to query. May be repeated to describe multiple stacks. If omitted, every stack is described")
+	RootCmd.PersistentFlags().StringArrayVar(&optionsLink.Statuses, "status", nil, "Only describe stacks with this status (e.g. CREATE_COMPLETE). May be repeated. Combined with --tag, every stack is listed once and filtered by both")
+	RootCmd.PersistentFlags().StringArrayVar(&optionsLink.Tags, "tag", nil, "Only describe stacks with this key=value tag. May be repeated, in which case stacks must match every tag")
+	RootCmd.PersistentFlags().StringVar(&optionsLink.OutputDirectory, "output", "", "Output directory, created if it doesn't exist. Required unless --stdout is set")
+	RootCmd.PersistentFlags().BoolVar(&optionsLink.Stdout, "stdout", false, "Write the serialized stack(s) to stdout rather than to files. Multiple stacks are wrapped in an array")
+	RootCmd.PersistentFlags().BoolVar(&optionsLink.NDJSON, "ndjson", false, "With --stdout, write one JSON document per line rather than an array")
+	RootCmd.PersistentFlags().BoolVar(&optionsLink.ListOnly, "list-only", false, "Only print the name and status of each matching stack. No files are written")
+	RootCmd.PersistentFlags().BoolVar(&optionsLink.SummaryOnly, "summary-only", false, "Only write the stack name, status, times and outputs. No additional API calls are made")
+	RootCmd.PersistentFlags().BoolVar(&optionsLink.DebugConfig, "debug-config", false, "Log the resolved AWS region, credential provider and endpoint")
+	RootCmd.PersistentFlags().BoolVar(&optionsLink.Gzip, "gzip", false, "Gzip compress the output file and append the .gz extension")
+	RootCmd.PersistentFlags().StringVar(&optionsLink.Format, "format", outputFormatJSON, fmt.Sprintf("Output format. One of: %s, %s", outputFormatJSON, outputFormatYAML))
+	RootCmd.PersistentFlags().BoolVar(&optionsLink.IncludeTemplate, "includeTemplate", false, "Also save each stack's template body to <stackName>.template.json or .yaml")
+	RootCmd.PersistentFlags().BoolVar(&optionsLink.IncludeEvents, "includeEvents", false, "Also save each stack's event history, oldest first, to <stackName>.events.json")
+	RootCmd.PersistentFlags().IntVar(&optionsLink.MaxEvents, "maxEvents", 0, "Limit --includeEvents to the most recent events. 0 saves every event")
+	RootCmd.PersistentFlags().BoolVar(&optionsLink.Recurse, "recurse", false, "Also describe nested AWS::CloudFormation::Stack stacks, each to its own file")
+	RootCmd.PersistentFlags().BoolVar(&optionsLink.ConsolidatedOutputs, "consolidated-outputs", false, fmt.Sprintf("Also save the outputs of every described stack, including nested stacks, to %s.json keyed by stack name", consolidatedOutputsFileName))
+	RootCmd.PersistentFlags().IntVar(&optionsLink.MaxDepth, "maxDepth", defaultMaxNestedDepth, "Maximum nested stack depth to describe with --recurse")
+	RootCmd.PersistentFlags().IntVar(&optionsLink.MaxRetries, "maxRetries", defaultMaxRetries, "Maximum number of times a throttled CloudFormation call is retried with exponential backoff. 0 disables retries. Other errors aren't retried")
+	RootCmd.PersistentFlags().StringVar(&optionsLink.Region, "region", "", "AWS region to query. Takes precedence over AWS_REGION and the profile region")
+	RootCmd.PersistentFlags().StringVar(&optionsLink.Profile, "profile", "", "Shared config profile to use. Takes precedence over AWS_PROFILE")
+	RootCmd.PersistentFlags().StringVar(&optionsLink.RoleArn, "roleArn", "", "IAM role ARN to assume before describing stacks, for cross-account access")
+	RootCmd.PersistentFlags().StringVar(&optionsLink.ExternalID, "externalId", "", "External ID to supply when assuming --roleArn")
+	RootCmd.PersistentFlags().BoolVar(&optionsLink.Verbose, "verbose", false, "Include the underlying AWS error in failure messages")
+	RootCmd.PersistentFlags().BoolVar(&optionsLink.DryRun, "dry-run", false, "List the stacks that would be described and the files that would be created without writing anything. Read-only AWS calls are still made")
+	RootCmd.PersistentFlags().BoolVar(&optionsLink.Offline, "offline", false, "With --dry-run, don't call AWS. The plan is built from --stackName alone")
+	RootCmd.PersistentFlags().StringVar(&optionsLink.ConfigFile, "config", "", fmt.Sprintf("Config file with default flag values (default $HOME/%s)", defaultConfigFileName))
 }
 
 func main() {
 	// Take a stack name and an output file...
 	if err := RootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(-1)
+		if optionsLink.Verbose {
+			fmt.Println(verboseErrorMessage(err))
+		} else {
+			fmt.Println(err)
+		}
+		os.Exit(exitCodeForError(err))
 	}
 }
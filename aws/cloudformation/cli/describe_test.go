@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/pkg/errors"
+)
+
+type mockCloudFormationClient struct {
+	failingStacks map[string]bool
+	templates     map[string]string
+	eventPages    map[string][][]*cloudformation.StackEvent
+	resources     map[string][]*cloudformation.StackResource
+	outputs       map[string][]*cloudformation.Output
+	describeCalls int
+	summaries     []*cloudformation.StackSummary
+}
+
+func (mock *mockCloudFormationClient) DescribeStacks(input *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+	mock.describeCalls++
+	stackName := aws.StringValue(input.StackName)
+	if mock.failingStacks[stackName] {
+		return nil, awserr.New("ValidationError",
+			fmt.Sprintf("Stack with id %s does not exist", stackName),
+			nil)
+	}
+	stack := &cloudformation.Stack{
+		StackName:   aws.String(stackName),
+		StackStatus: aws.String("CREATE_COMPLETE"),
+		Outputs:     mock.outputs[stackName],
+	}
+	// Stacks described by ID report their name, as CloudFormation does
+	if arn.IsARN(stackName) {
+		stack.StackId = aws.String(stackName)
+		stack.StackName = aws.String(stackNameForFile(stackName))
+	}
+	return &cloudformation.DescribeStacksOutput{
+		Stacks: []*cloudformation.Stack{stack},
+	}, nil
+}
+
+func (mock *mockCloudFormationClient) GetTemplate(input *cloudformation.GetTemplateInput) (*cloudformation.GetTemplateOutput, error) {
+	stackName := aws.StringValue(input.StackName)
+	templateBody, exists := mock.templates[stackName]
+	if !exists {
+		return nil, errors.Errorf("Stack with id %s does not exist", stackName)
+	}
+	return &cloudformation.GetTemplateOutput{
+		TemplateBody: aws.String(templateBody),
+	}, nil
+}
+
+func (mock *mockCloudFormationClient) DescribeStackEvents(input *cloudformation.DescribeStackEventsInput) (*cloudformation.DescribeStackEventsOutput, error) {
+	eventPages := mock.eventPages[aws.StringValue(input.StackName)]
+	if len(eventPages) == 0 {
+		return &cloudformation.DescribeStackEventsOutput{}, nil
+	}
+	pageIndex := 0
+	if input.NextToken != nil {
+		fmt.Sscanf(aws.StringValue(input.NextToken), "page%d", &pageIndex)
+	}
+	response := &cloudformation.DescribeStackEventsOutput{
+		StackEvents: eventPages[pageIndex],
+	}
+	if pageIndex+1 < len(eventPages) {
+		response.NextToken = aws.String(fmt.Sprintf("page%d", pageIndex+1))
+	}
+	return response, nil
+}
+
+func (mock *mockCloudFormationClient) DescribeStackResources(input *cloudformation.DescribeStackResourcesInput) (*cloudformation.DescribeStackResourcesOutput, error) {
+	return &cloudformation.DescribeStackResourcesOutput{
+		StackResources: mock.resources[aws.StringValue(input.StackName)],
+	}, nil
+}
+
+func (mock *mockCloudFormationClient) ListStacks(input *cloudformation.ListStacksInput) (*cloudformation.ListStacksOutput, error) {
+	statusFilter := make(map[string]bool)
+	for _, eachStatus := range input.StackStatusFilter {
+		statusFilter[aws.StringValue(eachStatus)] = true
+	}
+	response := &cloudformation.ListStacksOutput{}
+	for _, eachSummary := range mock.summaries {
+		if len(statusFilter) == 0 || statusFilter[aws.StringValue(eachSummary.StackStatus)] {
+			response.StackSummaries = append(response.StackSummaries, eachSummary)
+		}
+	}
+	return response, nil
+}
+
+func TestDescribeMultipleStacks(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-describe")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	options := optionsLinkStruct{
+		StackNames:      []string{"StackOne", "StackTwo", "StackThree"},
+		OutputDirectory: tempDir,
+	}
+	describeErr := describeStacks(&mockCloudFormationClient{}, options, ioutil.Discard)
+	if describeErr != nil {
+		t.Fatalf("Failed to describe stacks: %s", describeErr)
+	}
+	for _, eachStackName := range options.StackNames {
+		outputPath := filepath.Join(tempDir, eachStackName+".json")
+		if _, statErr := os.Stat(outputPath); statErr != nil {
+			t.Fatalf("Expected output file %s: %s", outputPath, statErr)
+		}
+	}
+}
+
+func TestDescribeMultipleStacksPartialFailure(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-describe")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	options := optionsLinkStruct{
+		StackNames:      []string{"StackOne", "MissingStack", "StackThree"},
+		OutputDirectory: tempDir,
+	}
+	mockClient := &mockCloudFormationClient{
+		failingStacks: map[string]bool{"MissingStack": true},
+	}
+	describeErr := describeStacks(mockClient, options, ioutil.Discard)
+	if describeErr == nil {
+		t.Fatalf("Expected describe to fail for MissingStack")
+	}
+	if !strings.Contains(describeErr.Error(), "1 of 3") ||
+		!strings.Contains(describeErr.Error(), "MissingStack") {
+		t.Fatalf("Unexpected error summary: %s", describeErr)
+	}
+	for _, eachStackName := range []string{"StackOne", "StackThree"} {
+		outputPath := filepath.Join(tempDir, eachStackName+".json")
+		if _, statErr := os.Stat(outputPath); statErr != nil {
+			t.Fatalf("Expected output file %s: %s", outputPath, statErr)
+		}
+	}
+}
+
+type mockPaginatedCloudFormationClient struct {
+	pages [][]string
+	tags  map[string][]*cloudformation.Tag
+	// statuses overrides the CREATE_COMPLETE status of a stack
+	statuses       map[string]string
+	describeCalls  int
+	listStackCalls int
+}
+
+func (mock *mockPaginatedCloudFormationClient) DescribeStacks(input *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+	mock.describeCalls++
+	pageIndex := 0
+	if input.NextToken != nil {
+		fmt.Sscanf(aws.StringValue(input.NextToken), "page%d", &pageIndex)
+	}
+	response := &cloudformation.DescribeStacksOutput{}
+	for _, eachStackName := range mock.pages[pageIndex] {
+		stackStatus := "CREATE_COMPLETE"
+		if overrideStatus, exists := mock.statuses[eachStackName]; exists {
+			stackStatus = overrideStatus
+		}
+		response.Stacks = append(response.Stacks, &cloudformation.Stack{
+			StackName:   aws.String(eachStackName),
+			StackStatus: aws.String(stackStatus),
+			Tags:        mock.tags[eachStackName],
+		})
+	}
+	if pageIndex+1 < len(mock.pages) {
+		response.NextToken = aws.String(fmt.Sprintf("page%d", pageIndex+1))
+	}
+	return response, nil
+}
+
+func (mock *mockPaginatedCloudFormationClient) GetTemplate(input *cloudformation.GetTemplateInput) (*cloudformation.GetTemplateOutput, error) {
+	return &cloudformation.GetTemplateOutput{}, nil
+}
+
+func (mock *mockPaginatedCloudFormationClient) DescribeStackEvents(input *cloudformation.DescribeStackEventsInput) (*cloudformation.DescribeStackEventsOutput, error) {
+	return &cloudformation.DescribeStackEventsOutput{}, nil
+}
+
+func (mock *mockPaginatedCloudFormationClient) DescribeStackResources(input *cloudformation.DescribeStackResourcesInput) (*cloudformation.DescribeStackResourcesOutput, error) {
+	return &cloudformation.DescribeStackResourcesOutput{}, nil
+}
+
+func (mock *mockPaginatedCloudFormationClient) ListStacks(input *cloudformation.ListStacksInput) (*cloudformation.ListStacksOutput, error) {
+	mock.listStackCalls++
+	return &cloudformation.ListStacksOutput{}, nil
+}
+
+func TestDescribeAllStacksPaginated(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-describe")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mockClient := &mockPaginatedCloudFormationClient{
+		pages: [][]string{
+			{"StackOne", "StackTwo"},
+			{"StackThree"},
+		},
+	}
+	options := optionsLinkStruct{
+		OutputDirectory: tempDir,
+	}
+	describeErr := describeStacks(mockClient, options, ioutil.Discard)
+	if describeErr != nil {
+		t.Fatalf("Failed to describe stacks: %s", describeErr)
+	}
+	outputBytes, outputBytesErr := ioutil.ReadFile(filepath.Join(tempDir, allStacksFileName+".json"))
+	if outputBytesErr != nil {
+		t.Fatalf("Failed to read output file: %s", outputBytesErr)
+	}
+	var allStacks cloudformation.DescribeStacksOutput
+	unmarshalErr := json.Unmarshal(outputBytes, &allStacks)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal output: %s", unmarshalErr)
+	}
+	if len(allStacks.Stacks) != 3 {
+		t.Fatalf("Expected 3 stacks across both pages. Found: %d", len(allStacks.Stacks))
+	}
+	if aws.StringValue(allStacks.Stacks[2].StackName) != "StackThree" {
+		t.Fatalf("Expected second page stack. Found: %#v", allStacks.Stacks[2])
+	}
+}
+
+func TestRunEWithMockDescriber(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-describe")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	savedFactory := newCFNDescriber
+	savedOptions := optionsLink
+	defer func() {
+		newCFNDescriber = savedFactory
+		optionsLink = savedOptions
+	}()
+
+	testCases := []struct {
+		name       string
+		stackName  string
+		client     cfnDescriber
+		expectErr  bool
+		outputFile string
+	}{
+		{
+			name:       "success",
+			stackName:  "MyStack",
+			client:     &mockCloudFormationClient{},
+			outputFile: "MyStack.json",
+		},
+		{
+			name:      "describe error",
+			stackName: "MissingStack",
+			client: &mockCloudFormationClient{
+				failingStacks: map[string]bool{"MissingStack": true},
+			},
+			expectErr: true,
+		},
+	}
+	for _, eachTestCase := range testCases {
+		t.Run(eachTestCase.name, func(t *testing.T) {
+			client := eachTestCase.client
+			newCFNDescriber = func(sess *session.Session) cfnDescriber {
+				return client
+			}
+			optionsLink = optionsLinkStruct{
+				StackNames:      []string{eachTestCase.stackName},
+				OutputDirectory: tempDir,
+			}
+			runErr := RootCmd.RunE(RootCmd, nil)
+			if eachTestCase.expectErr {
+				if runErr == nil {
+					t.Fatalf("Expected RunE to fail")
+				}
+				return
+			}
+			if runErr != nil {
+				t.Fatalf("Unexpected RunE error: %s", runErr)
+			}
+			outputPath := filepath.Join(tempDir, eachTestCase.outputFile)
+			if _, statErr := os.Stat(outputPath); statErr != nil {
+				t.Fatalf("Expected output file %s: %s", outputPath, statErr)
+			}
+		})
+	}
+}
+
+func TestStreamStacksStdout(t *testing.T) {
+	readPipe, writePipe, pipeErr := os.Pipe()
+	if pipeErr != nil {
+		t.Fatal(pipeErr)
+	}
+	savedStdout := os.Stdout
+	os.Stdout = writePipe
+	options := optionsLinkStruct{
+		StackNames: []string{"StackOne", "StackTwo"},
+		Stdout:     true,
+	}
+	streamErr := streamStacks(&mockCloudFormationClient{}, options, os.Stdout)
+	os.Stdout = savedStdout
+	writePipe.Close()
+	if streamErr != nil {
+		t.Fatalf("Failed to stream stacks: %s", streamErr)
+	}
+	captured, capturedErr := ioutil.ReadAll(readPipe)
+	if capturedErr != nil {
+		t.Fatal(capturedErr)
+	}
+	var responses []cloudformation.DescribeStacksOutput
+	unmarshalErr := json.Unmarshal(captured, &responses)
+	if unmarshalErr != nil {
+		t.Fatalf("Expected a JSON array on stdout: %s\n%s", unmarshalErr, string(captured))
+	}
+	if len(responses) != 2 ||
+		aws.StringValue(responses[1].Stacks[0].StackName) != "StackTwo" {
+		t.Fatalf("Unexpected stdout content: %s", string(captured))
+	}
+}
+
+func TestStreamStacksNDJSON(t *testing.T) {
+	var output bytes.Buffer
+	options := optionsLinkStruct{
+		StackNames: []string{"StackOne", "StackTwo", "StackThree"},
+		Stdout:     true,
+		NDJSON:     true,
+	}
+	streamErr := streamStacks(&mockCloudFormationClient{}, options, &output)
+	if streamErr != nil {
+		t.Fatalf("Failed to stream stacks: %s", streamErr)
+	}
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 NDJSON lines. Found: %d", len(lines))
+	}
+	for _, eachLine := range lines {
+		var response cloudformation.DescribeStacksOutput
+		if unmarshalErr := json.Unmarshal([]byte(eachLine), &response); unmarshalErr != nil {
+			t.Fatalf("Invalid NDJSON line %s: %s", eachLine, unmarshalErr)
+		}
+	}
+}
+
+func TestValidateStdoutOptions(t *testing.T) {
+	invalidOptions := []optionsLinkStruct{
+		{Stdout: true, IncludeTemplate: true},
+		{Stdout: true, Gzip: true},
+		{Stdout: true, NDJSON: true, Format: outputFormatYAML},
+	}
+	for _, eachOptions := range invalidOptions {
+		if err := validateStdoutOptions(eachOptions); err == nil {
+			t.Fatalf("Expected options to be rejected: %#v", eachOptions)
+		}
+	}
+	if err := validateStdoutOptions(optionsLinkStruct{Stdout: true, NDJSON: true}); err != nil {
+		t.Fatalf("Expected options to be valid: %s", err)
+	}
+}
@@ -1,4 +1,19 @@
 /*Package main provides a simple cli tool to produce a DescribeStackResponse
 for a given CloudFormation Stack Name or ID.
+
+Stacks in another account can be described by supplying --roleArn (and
+optionally --externalId). The role's trust relationship must allow the
+caller's principal to assume it, for example:
+
+	{
+	  "Effect": "Allow",
+	  "Principal": {"AWS": "arn:aws:iam::<CALLER_ACCOUNT_ID>:root"},
+	  "Action": "sts:AssumeRole",
+	  "Condition": {"StringEquals": {"sts:ExternalId": "<EXTERNAL_ID>"}}
+	}
+
+The Condition is only required when --externalId is used. The role also
+needs cloudformation:DescribeStacks permissions, plus any permissions for
+the optional API calls such as cloudformation:GetTemplate.
 */
 package main
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/mweagle/Sparta/aws/cloudformation/link"
+	"github.com/pkg/errors"
+)
+
+// validateDryRunOptions returns an error if --dry-run or --offline is
+// combined with an option it doesn't support. --offline doesn't call AWS,
+// so it's limited to the options that can be planned from the flags alone.
+func validateDryRunOptions(options optionsLinkStruct) error {
+	if options.Offline && !options.DryRun {
+		return errors.New("--offline requires --dry-run")
+	}
+	if options.DryRun && options.Stdout {
+		return errors.New("--dry-run cannot be combined with --stdout")
+	}
+	if !options.Offline {
+		return nil
+	}
+	offlineConflicts := []struct {
+		flag    string
+		enabled bool
+	}{
+		{"--status", len(options.Statuses) != 0},
+		{"--tag", len(options.Tags) != 0},
+		{"--recurse", options.Recurse},
+		{"--includeTemplate", options.IncludeTemplate},
+		{"--includeEvents", options.IncludeEvents},
+		{"--consolidated-outputs", options.ConsolidatedOutputs},
+		{"--debug-config", options.DebugConfig},
+	}
+	for _, eachConflict := range offlineConflicts {
+		if eachConflict.enabled {
+			return errors.Errorf("%s cannot be combined with --offline", eachConflict.flag)
+		}
+	}
+	return nil
+}
+
+// emitOutputFile writes data to outputPath, or with --dry-run only
+// computes the path that would be written. It returns the path in either
+// case.
+func emitOutputFile(outputPath string, data []byte, options optionsLinkStruct) (string, error) {
+	return emitOutputStream(outputPath, bytes.NewReader(data), options)
+}
+
+// emitOutputStream copies r to outputPath without buffering it, or with
+// --dry-run only computes the path that would be written. It returns the
+// path in either case.
+func emitOutputStream(outputPath string, r io.Reader, options optionsLinkStruct) (string, error) {
+	if options.DryRun {
+		return link.OutputFilePath(outputPath, options.Gzip), nil
+	}
+	return link.CopyOutputFile(outputPath, r, options.Gzip)
+}
+
+// outputFileMessage returns the message reporting that outputPath was, or
+// with --dry-run would be, created
+func outputFileMessage(outputPath string, options optionsLinkStruct) string {
+	if options.DryRun {
+		return "Would create file: " + outputPath
+	}
+	return "Created file: " + outputPath
+}
+
+// planOfflineStacks writes the stacks and output files that describeStacks
+// would produce, without calling AWS. Stack names are taken from
+// --stackName as given, so they aren't checked for existence.
+func planOfflineStacks(options optionsLinkStruct, w io.Writer) error {
+	stackNames := options.StackNames
+	if len(stackNames) == 0 {
+		stackNames = []string{""}
+	}
+	for _, eachStackName := range stackNames {
+		fmt.Fprintf(w, "Would describe stack: %s\n", stackDisplayName(eachStackName))
+		outputPath := link.OutputFilePath(stackOutputPath(eachStackName, options), options.Gzip)
+		fmt.Fprintln(w, outputFileMessage(outputPath, options))
+	}
+	return nil
+}
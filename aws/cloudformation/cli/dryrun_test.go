@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDescribeStacksDryRun(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-dryrun")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mockClient := &mockCloudFormationClient{
+		templates: map[string]string{"MyStack": `{"Resources":{}}`},
+	}
+	options := optionsLinkStruct{
+		StackNames:      []string{"MyStack"},
+		OutputDirectory: tempDir,
+		IncludeTemplate: true,
+		IncludeEvents:   true,
+		Gzip:            true,
+		DryRun:          true,
+	}
+	var output bytes.Buffer
+	describeErr := describeStacks(mockClient, options, &output)
+	if describeErr != nil {
+		t.Fatalf("Failed to describe stacks: %s", describeErr)
+	}
+	entries, entriesErr := ioutil.ReadDir(tempDir)
+	if entriesErr != nil {
+		t.Fatal(entriesErr)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("Expected no files to be created. Found: %d", len(entries))
+	}
+	if mockClient.describeCalls == 0 {
+		t.Fatalf("Expected --dry-run to call DescribeStacks")
+	}
+	for _, eachFile := range []string{"MyStack.json.gz",
+		"MyStack.template.json.gz",
+		"MyStack.events.json.gz"} {
+		expected := "Would create file: " + filepath.Join(tempDir, eachFile)
+		if !strings.Contains(output.String(), expected) {
+			t.Fatalf("Expected output to contain %q. Found: %s", expected, output.String())
+		}
+	}
+	if !strings.Contains(output.String(), "Would describe stack: MyStack") {
+		t.Fatalf("Expected output to list the stack. Found: %s", output.String())
+	}
+}
+
+func TestPlanOfflineStacks(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-offline")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	outputDirectory := filepath.Join(tempDir, "missing")
+	options := optionsLinkStruct{
+		StackNames:      []string{"StackOne", "StackTwo"},
+		OutputDirectory: outputDirectory,
+		Format:          outputFormatYAML,
+		DryRun:          true,
+		Offline:         true,
+	}
+	var output bytes.Buffer
+	planErr := planOfflineStacks(options, &output)
+	if planErr != nil {
+		t.Fatalf("Failed to plan stacks: %s", planErr)
+	}
+	if _, statErr := os.Stat(outputDirectory); !os.IsNotExist(statErr) {
+		t.Fatalf("Expected --offline not to create the output directory")
+	}
+	for _, eachFile := range []string{"StackOne.yaml", "StackTwo.yaml"} {
+		expected := "Would create file: " + filepath.Join(outputDirectory, eachFile)
+		if !strings.Contains(output.String(), expected) {
+			t.Fatalf("Expected output to contain %q. Found: %s", expected, output.String())
+		}
+	}
+}
+
+func TestValidateDryRunOptions(t *testing.T) {
+	testCases := []struct {
+		options   optionsLinkStruct
+		expectErr bool
+	}{
+		{optionsLinkStruct{DryRun: true}, false},
+		{optionsLinkStruct{DryRun: true, Offline: true}, false},
+		{optionsLinkStruct{DryRun: true, IncludeTemplate: true}, false},
+		{optionsLinkStruct{Offline: true}, true},
+		{optionsLinkStruct{DryRun: true, Stdout: true}, true},
+		{optionsLinkStruct{DryRun: true, Offline: true, Recurse: true}, true},
+		{optionsLinkStruct{DryRun: true, Offline: true, Tags: []string{"env=prod"}}, true},
+	}
+	for _, eachTestCase := range testCases {
+		validateErr := validateDryRunOptions(eachTestCase.options)
+		if (validateErr != nil) != eachTestCase.expectErr {
+			t.Fatalf("Unexpected validation result for %+v: %v", eachTestCase.options, validateErr)
+		}
+	}
+}
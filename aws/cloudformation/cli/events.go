@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/mweagle/Sparta/aws/cloudformation/link"
+	"github.com/pkg/errors"
+)
+
+// describeStackEventsAllPages returns the stack events for stackName,
+// oldest first. DescribeStackEvents returns the newest events first, so
+// when maxEvents is positive only the maxEvents most recent events are
+// fetched.
+func describeStackEventsAllPages(svc cfnDescriber,
+	stackName string,
+	maxEvents int) ([]*cloudformation.StackEvent, error) {
+	params := &cloudformation.DescribeStackEventsInput{
+		StackName: aws.String(stackName),
+	}
+	var stackEvents []*cloudformation.StackEvent
+	for {
+		describeEventsResponse, describeEventsErr := svc.DescribeStackEvents(params)
+		if describeEventsErr != nil {
+			return nil, describeEventsErr
+		}
+		stackEvents = append(stackEvents, describeEventsResponse.StackEvents...)
+		if maxEvents > 0 && len(stackEvents) >= maxEvents {
+			// Sort before truncating in case a page isn't strictly ordered
+			sortStackEvents(stackEvents)
+			stackEvents = stackEvents[len(stackEvents)-maxEvents:]
+			return stackEvents, nil
+		}
+		if aws.StringValue(describeEventsResponse.NextToken) == "" {
+			break
+		}
+		params.NextToken = describeEventsResponse.NextToken
+	}
+	sortStackEvents(stackEvents)
+	return stackEvents, nil
+}
+
+// sortStackEvents sorts the events oldest first
+func sortStackEvents(stackEvents []*cloudformation.StackEvent) {
+	sort.SliceStable(stackEvents, func(i, j int) bool {
+		return aws.TimeValue(stackEvents[i].Timestamp).Before(aws.TimeValue(stackEvents[j].Timestamp))
+	})
+}
+
+// writeStackEvents fetches the event history for each stack and writes it
+// to <stackName>.events.<ext> in the output directory
+func writeStackEvents(svc cfnDescriber,
+	stacks []*cloudformation.Stack,
+	options optionsLinkStruct,
+	w io.Writer) error {
+	for _, eachStack := range stacks {
+		stackName := aws.StringValue(eachStack.StackName)
+		stackID := stackName
+		if eachStack.StackId != nil {
+			stackID = aws.StringValue(eachStack.StackId)
+		}
+		stackEvents, stackEventsErr := describeStackEventsAllPages(svc, stackID, options.MaxEvents)
+		if stackEventsErr != nil {
+			return errors.Wrapf(stackEventsErr, "Attempting to describe events for stack %s", stackName)
+		}
+		eventsInfo, eventsInfoErr := link.MarshalOutput(stackEvents, options.Format)
+		if eventsInfoErr != nil {
+			return errors.Wrapf(eventsInfoErr, "Failed to serialize events for stack %s", stackName)
+		}
+		eventsFilepath := filepath.Join(options.OutputDirectory,
+			fmt.Sprintf("%s.events.%s",
+				stackNameForFile(stackName),
+				link.OutputFileExtension(options.Format)))
+		eventsFilepath, outputErr := emitOutputFile(eventsFilepath, eventsInfo, options)
+		if outputErr != nil {
+			return errors.Wrapf(outputErr, "Attempting to write events file for stack %s", stackName)
+		}
+		fmt.Fprintln(w, outputFileMessage(eventsFilepath, options))
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func testStackEvent(eventID string, timestamp time.Time) *cloudformation.StackEvent {
+	return &cloudformation.StackEvent{
+		EventId:        aws.String(eventID),
+		StackName:      aws.String("FailedStack"),
+		ResourceStatus: aws.String("CREATE_FAILED"),
+		Timestamp:      aws.Time(timestamp),
+	}
+}
+
+func testEventClient() *mockCloudFormationClient {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	// DescribeStackEvents pages are newest first
+	return &mockCloudFormationClient{
+		eventPages: map[string][][]*cloudformation.StackEvent{
+			"FailedStack": {
+				{
+					testStackEvent("event4", start.Add(4*time.Minute)),
+					testStackEvent("event3", start.Add(3*time.Minute)),
+				},
+				{
+					testStackEvent("event2", start.Add(2*time.Minute)),
+					testStackEvent("event1", start.Add(1*time.Minute)),
+				},
+			},
+		},
+	}
+}
+
+func readStackEvents(t *testing.T, eventsPath string) []cloudformation.StackEvent {
+	eventsBytes, eventsBytesErr := ioutil.ReadFile(eventsPath)
+	if eventsBytesErr != nil {
+		t.Fatalf("Expected events file %s: %s", eventsPath, eventsBytesErr)
+	}
+	var stackEvents []cloudformation.StackEvent
+	unmarshalErr := json.Unmarshal(eventsBytes, &stackEvents)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal events: %s", unmarshalErr)
+	}
+	return stackEvents
+}
+
+func TestDescribeIncludeEvents(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-events")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	options := optionsLinkStruct{
+		StackNames:      []string{"FailedStack"},
+		OutputDirectory: tempDir,
+		IncludeEvents:   true,
+	}
+	describeErr := describeStacks(testEventClient(), options, ioutil.Discard)
+	if describeErr != nil {
+		t.Fatalf("Failed to describe stacks: %s", describeErr)
+	}
+	stackEvents := readStackEvents(t, filepath.Join(tempDir, "FailedStack.events.json"))
+	expectedIDs := []string{"event1", "event2", "event3", "event4"}
+	if len(stackEvents) != len(expectedIDs) {
+		t.Fatalf("Expected %d events. Found: %d", len(expectedIDs), len(stackEvents))
+	}
+	for eachIndex, eachID := range expectedIDs {
+		if aws.StringValue(stackEvents[eachIndex].EventId) != eachID {
+			t.Fatalf("Expected %s at index %d. Found: %s",
+				eachID,
+				eachIndex,
+				aws.StringValue(stackEvents[eachIndex].EventId))
+		}
+	}
+}
+
+func TestDescribeMaxEvents(t *testing.T) {
+	stackEvents, stackEventsErr := describeStackEventsAllPages(testEventClient(), "FailedStack", 3)
+	if stackEventsErr != nil {
+		t.Fatalf("Failed to describe events: %s", stackEventsErr)
+	}
+	if len(stackEvents) != 3 {
+		t.Fatalf("Expected 3 events. Found: %d", len(stackEvents))
+	}
+	// The most recent events are kept, oldest first
+	if aws.StringValue(stackEvents[0].EventId) != "event2" ||
+		aws.StringValue(stackEvents[2].EventId) != "event4" {
+		t.Fatalf("Unexpected events: %s, %s",
+			aws.StringValue(stackEvents[0].EventId),
+			aws.StringValue(stackEvents[2].EventId))
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pkg/errors"
+)
+
+const (
+	// exitCodeFailure is the process exit code for a general failure
+	exitCodeFailure = -1
+	// exitCodeStackNotFound is the process exit code when every failure
+	// was due to a stack that doesn't exist
+	exitCodeStackNotFound = 3
+)
+
+// stackNotFoundError is returned when a stack doesn't exist. The
+// original AWS error is retained for --verbose output.
+type stackNotFoundError struct {
+	stackName string
+	awsErr    error
+}
+
+func (notFound *stackNotFoundError) Error() string {
+	return fmt.Sprintf("Stack %s does not exist", notFound.stackName)
+}
+
+// OrigErr returns the AWS error that was classified as not found
+func (notFound *stackNotFoundError) OrigErr() error {
+	return notFound.awsErr
+}
+
+// isStackNotFound returns true if err is the ValidationError that
+// CloudFormation returns for a nonexistent stack
+func isStackNotFound(err error) bool {
+	awsErr, isAWSErr := err.(awserr.Error)
+	return isAWSErr &&
+		awsErr.Code() == "ValidationError" &&
+		strings.Contains(awsErr.Message(), "does not exist")
+}
+
+// stackNotFound returns a stackNotFoundError for stackName if err is the
+// error CloudFormation returns for a nonexistent stack. Other errors are
+// returned unchanged.
+func stackNotFound(stackName string, err error) error {
+	if !isStackNotFound(err) {
+		return err
+	}
+	return &stackNotFoundError{
+		stackName: stackName,
+		awsErr:    err,
+	}
+}
+
+// describeError summarizes the stacks that couldn't be described
+type describeError struct {
+	failures   []error
+	stackCount int
+}
+
+func (describeErr *describeError) Error() string {
+	failureMessages := make([]string, len(describeErr.failures))
+	for eachIndex, eachFailure := range describeErr.failures {
+		failureMessages[eachIndex] = eachFailure.Error()
+	}
+	return fmt.Sprintf("Failed to describe %d of %d stacks:\n  %s",
+		len(describeErr.failures),
+		describeErr.stackCount,
+		strings.Join(failureMessages, "\n  "))
+}
+
+// describeFailuresError returns an error summarizing the stacks that
+// couldn't be described, or nil if there were no failures
+func describeFailuresError(failures []error, stackCount int) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	return &describeError{
+		failures:   failures,
+		stackCount: stackCount,
+	}
+}
+
+// exitCodeForError returns the process exit code for err. Scripts can use
+// exitCodeStackNotFound to distinguish a missing stack from a failed AWS
+// call.
+func exitCodeForError(err error) int {
+	describeErr, isDescribeErr := errors.Cause(err).(*describeError)
+	if !isDescribeErr {
+		if _, isNotFound := errors.Cause(err).(*stackNotFoundError); isNotFound {
+			return exitCodeStackNotFound
+		}
+		return exitCodeFailure
+	}
+	for _, eachFailure := range describeErr.failures {
+		if _, isNotFound := errors.Cause(eachFailure).(*stackNotFoundError); !isNotFound {
+			return exitCodeFailure
+		}
+	}
+	return exitCodeStackNotFound
+}
+
+// verboseErrorMessage returns the message for err including the original
+// AWS error of any stackNotFoundError
+func verboseErrorMessage(err error) string {
+	failures := []error{err}
+	if describeErr, isDescribeErr := errors.Cause(err).(*describeError); isDescribeErr {
+		failures = describeErr.failures
+	}
+	messages := []string{err.Error()}
+	for _, eachFailure := range failures {
+		if notFound, isNotFound := errors.Cause(eachFailure).(*stackNotFoundError); isNotFound {
+			messages = append(messages, fmt.Sprintf("%s: %s", notFound.stackName, notFound.awsErr))
+		}
+	}
+	return strings.Join(messages, "\n")
+}
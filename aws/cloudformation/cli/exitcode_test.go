@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pkg/errors"
+)
+
+func TestExitCodeStackNotFound(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-exitcode")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mockClient := &mockCloudFormationClient{
+		failingStacks: map[string]bool{"MissingStack": true},
+	}
+	testCases := []struct {
+		stackNames       []string
+		expectedExitCode int
+	}{
+		{[]string{"MissingStack"}, exitCodeStackNotFound},
+		{[]string{"MissingStack", "ExistingStack"}, exitCodeStackNotFound},
+	}
+	for _, eachTestCase := range testCases {
+		options := optionsLinkStruct{
+			StackNames:      eachTestCase.stackNames,
+			OutputDirectory: tempDir,
+		}
+		describeErr := describeStacks(mockClient, options, ioutil.Discard)
+		if describeErr == nil {
+			t.Fatalf("Expected describe to fail for %v", eachTestCase.stackNames)
+		}
+		exitCode := exitCodeForError(describeErr)
+		if exitCode != eachTestCase.expectedExitCode {
+			t.Fatalf("Expected exit code %d for %v. Found: %d",
+				eachTestCase.expectedExitCode,
+				eachTestCase.stackNames,
+				exitCode)
+		}
+		if !strings.Contains(describeErr.Error(), "Stack MissingStack does not exist") {
+			t.Fatalf("Expected friendly not found message. Found: %s", describeErr)
+		}
+		if !strings.Contains(verboseErrorMessage(describeErr), "ValidationError") {
+			t.Fatalf("Expected verbose message to include the AWS error. Found: %s",
+				verboseErrorMessage(describeErr))
+		}
+	}
+}
+
+func TestExitCodeFailure(t *testing.T) {
+	accessDenied := awserr.New("AccessDenied", "User is not authorized", nil)
+	if isStackNotFound(accessDenied) {
+		t.Fatalf("Expected AccessDenied not to be classified as not found")
+	}
+	mixedErr := describeFailuresError([]error{
+		errors.Wrap(&stackNotFoundError{stackName: "MissingStack"}, "MissingStack"),
+		errors.Wrap(accessDenied, "OtherStack"),
+	}, 2)
+	if exitCode := exitCodeForError(mixedErr); exitCode != exitCodeFailure {
+		t.Fatalf("Expected exit code %d for mixed failures. Found: %d", exitCodeFailure, exitCode)
+	}
+	if exitCode := exitCodeForError(errors.New("Attempting to create session")); exitCode != exitCodeFailure {
+		t.Fatalf("Expected exit code %d for general failure. Found: %d", exitCodeFailure, exitCode)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mweagle/Sparta/aws/cloudformation/link"
+	"github.com/pkg/errors"
+)
+
+// validateListOnlyOptions returns an error if --list-only is combined with
+// an option that describes or writes stack details
+func validateListOnlyOptions(options optionsLinkStruct) error {
+	listOnlyConflicts := []struct {
+		flag    string
+		enabled bool
+	}{
+		{"--stdout", options.Stdout},
+		{"--dry-run", options.DryRun},
+		{"--gzip", options.Gzip},
+		{"--includeTemplate", options.IncludeTemplate},
+		{"--includeEvents", options.IncludeEvents},
+		{"--recurse", options.Recurse},
+		{"--consolidated-outputs", options.ConsolidatedOutputs},
+	}
+	for _, eachConflict := range listOnlyConflicts {
+		if eachConflict.enabled {
+			return errors.Errorf("%s cannot be combined with --list-only", eachConflict.flag)
+		}
+	}
+	return nil
+}
+
+// listStacks writes the name and status of every stack matching the
+// --stackName, --status and --tag filters to w, one tab separated stack
+// per line. Only DescribeStacks and ListStacks are called and no files
+// are written.
+func listStacks(svc cfnDescriber,
+	options optionsLinkStruct,
+	w io.Writer) error {
+	stackNames, stackNamesErr := targetStackNames(svc, options)
+	if stackNamesErr != nil {
+		return stackNamesErr
+	}
+	describeOptions, describeOptionsErr := linkDescribeOptions(options)
+	if describeOptionsErr != nil {
+		return describeOptionsErr
+	}
+	describeOptions.SummaryOnly = true
+	var failures []error
+	for _, eachStackName := range stackNames {
+		result, resultErr := link.Describe(svc, eachStackName, describeOptions)
+		if resultErr != nil {
+			failures = append(failures,
+				errors.Wrap(stackNotFound(eachStackName, resultErr), stackDisplayName(eachStackName)))
+			continue
+		}
+		for _, eachSummary := range result.Summaries {
+			fmt.Fprintf(w, "%s\t%s\n", eachSummary.StackName, eachSummary.StackStatus)
+		}
+	}
+	return describeFailuresError(failures, len(stackNames))
+}
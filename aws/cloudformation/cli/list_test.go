@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func TestListStacks(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-list")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mockClient := &summaryOnlyClient{
+		mockCloudFormationClient: &mockCloudFormationClient{},
+		t:                        t,
+	}
+	options := optionsLinkStruct{
+		StackNames:      []string{"StackOne", "StackTwo"},
+		OutputDirectory: tempDir,
+		ListOnly:        true,
+	}
+	var output bytes.Buffer
+	listErr := listStacks(mockClient, options, &output)
+	if listErr != nil {
+		t.Fatalf("Failed to list stacks: %s", listErr)
+	}
+	expected := "StackOne\tCREATE_COMPLETE\nStackTwo\tCREATE_COMPLETE\n"
+	if output.String() != expected {
+		t.Fatalf("Expected only stack names and statuses.\nExpected: %q\nFound: %q",
+			expected,
+			output.String())
+	}
+	entries, entriesErr := ioutil.ReadDir(tempDir)
+	if entriesErr != nil {
+		t.Fatal(entriesErr)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("Expected --list-only not to write files. Found: %d", len(entries))
+	}
+}
+
+func TestListStacksByStatusAndTags(t *testing.T) {
+	mockClient := &mockPaginatedCloudFormationClient{
+		pages: [][]string{{"PaymentsProd", "PaymentsFailed", "SearchDev"}},
+		tags: map[string][]*cloudformation.Tag{
+			"PaymentsProd":   stackTags("Environment", "prod"),
+			"PaymentsFailed": stackTags("Environment", "prod"),
+			"SearchDev":      stackTags("Environment", "dev"),
+		},
+		statuses: map[string]string{
+			"PaymentsFailed": cloudformation.StackStatusRollbackComplete,
+		},
+	}
+	options := optionsLinkStruct{
+		Statuses: []string{cloudformation.StackStatusCreateComplete},
+		Tags:     []string{"Environment=prod"},
+		ListOnly: true,
+	}
+	var output bytes.Buffer
+	listErr := listStacks(mockClient, options, &output)
+	if listErr != nil {
+		t.Fatalf("Failed to list stacks: %s", listErr)
+	}
+	if output.String() != "PaymentsProd\tCREATE_COMPLETE\n" {
+		t.Fatalf("Expected only the matching stack. Found: %q", output.String())
+	}
+}
+
+func TestValidateListOnlyOptions(t *testing.T) {
+	testCases := []struct {
+		options   optionsLinkStruct
+		expectErr bool
+	}{
+		{optionsLinkStruct{ListOnly: true}, false},
+		{optionsLinkStruct{ListOnly: true, Tags: []string{"env=prod"}}, false},
+		{optionsLinkStruct{ListOnly: true, Stdout: true}, true},
+		{optionsLinkStruct{ListOnly: true, IncludeTemplate: true}, true},
+		{optionsLinkStruct{ListOnly: true, Recurse: true}, true},
+	}
+	for _, eachTestCase := range testCases {
+		validateErr := validateListOnlyOptions(eachTestCase.options)
+		if (validateErr != nil) != eachTestCase.expectErr {
+			t.Fatalf("Unexpected validation result for %+v: %v", eachTestCase.options, validateErr)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/pkg/errors"
+)
+
+// nestedStackResourceType is the resource type of a nested stack
+const nestedStackResourceType = "AWS::CloudFormation::Stack"
+
+// defaultMaxNestedDepth is the default --maxDepth value
+const defaultMaxNestedDepth = 5
+
+// nestedStackIDs returns the physical IDs of the nested stacks that are
+// resources of the given stacks. Nested stacks that haven't been created
+// yet have no physical ID and are skipped.
+func nestedStackIDs(svc cfnDescriber,
+	stacks []*cloudformation.Stack) ([]string, error) {
+	var nestedIDs []string
+	for _, eachStack := range stacks {
+		stackID := aws.StringValue(eachStack.StackId)
+		if stackID == "" {
+			stackID = aws.StringValue(eachStack.StackName)
+		}
+		params := &cloudformation.DescribeStackResourcesInput{
+			StackName: aws.String(stackID),
+		}
+		resourcesResponse, resourcesErr := svc.DescribeStackResources(params)
+		if resourcesErr != nil {
+			return nil, errors.Wrapf(resourcesErr,
+				"Attempting to describe resources for stack %s",
+				aws.StringValue(eachStack.StackName))
+		}
+		for _, eachResource := range resourcesResponse.StackResources {
+			physicalID := aws.StringValue(eachResource.PhysicalResourceId)
+			if aws.StringValue(eachResource.ResourceType) == nestedStackResourceType &&
+				physicalID != "" {
+				nestedIDs = append(nestedIDs, physicalID)
+			}
+		}
+	}
+	return nestedIDs, nil
+}
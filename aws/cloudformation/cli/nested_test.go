@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+const (
+	testChildStackID      = "arn:aws:cloudformation:us-east-1:123456789012:stack/ChildStack/guid-child"
+	testGrandchildStackID = "arn:aws:cloudformation:us-east-1:123456789012:stack/GrandchildStack/guid-grandchild"
+)
+
+func nestedStackResource(physicalID string) *cloudformation.StackResource {
+	return &cloudformation.StackResource{
+		LogicalResourceId:  aws.String("Nested"),
+		PhysicalResourceId: aws.String(physicalID),
+		ResourceType:       aws.String(nestedStackResourceType),
+	}
+}
+
+func testNestedClient() *mockCloudFormationClient {
+	return &mockCloudFormationClient{
+		resources: map[string][]*cloudformation.StackResource{
+			"ParentStack": {
+				{
+					LogicalResourceId:  aws.String("Bucket"),
+					PhysicalResourceId: aws.String("my-bucket"),
+					ResourceType:       aws.String("AWS::S3::Bucket"),
+				},
+				nestedStackResource(testChildStackID),
+			},
+			// The child refers back to the parent to verify cycles terminate
+			testChildStackID: {
+				nestedStackResource("ParentStack"),
+				nestedStackResource(testGrandchildStackID),
+			},
+		},
+	}
+}
+
+func TestDescribeRecurseNestedStacks(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-nested")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mockClient := testNestedClient()
+	options := optionsLinkStruct{
+		StackNames:      []string{"ParentStack"},
+		OutputDirectory: tempDir,
+		Recurse:         true,
+		MaxDepth:        defaultMaxNestedDepth,
+	}
+	describeErr := describeStacks(mockClient, options, ioutil.Discard)
+	if describeErr != nil {
+		t.Fatalf("Failed to describe stacks: %s", describeErr)
+	}
+	for _, eachFile := range []string{"ParentStack.json", "ChildStack.json", "GrandchildStack.json"} {
+		if _, statErr := os.Stat(filepath.Join(tempDir, eachFile)); statErr != nil {
+			t.Fatalf("Expected output file %s: %s", eachFile, statErr)
+		}
+	}
+	if mockClient.describeCalls != 3 {
+		t.Fatalf("Expected each stack to be described once. Found %d calls", mockClient.describeCalls)
+	}
+}
+
+func TestDescribeRecurseMaxDepth(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-nested")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	options := optionsLinkStruct{
+		StackNames:      []string{"ParentStack"},
+		OutputDirectory: tempDir,
+		Recurse:         true,
+		MaxDepth:        1,
+	}
+	describeErr := describeStacks(testNestedClient(), options, ioutil.Discard)
+	if describeErr != nil {
+		t.Fatalf("Failed to describe stacks: %s", describeErr)
+	}
+	if _, statErr := os.Stat(filepath.Join(tempDir, "ChildStack.json")); statErr != nil {
+		t.Fatalf("Expected child stack output: %s", statErr)
+	}
+	if _, statErr := os.Stat(filepath.Join(tempDir, "GrandchildStack.json")); !os.IsNotExist(statErr) {
+		t.Fatalf("Expected grandchild stack beyond --maxDepth to be skipped")
+	}
+}
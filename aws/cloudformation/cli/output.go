@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+
+	"github.com/mweagle/Sparta/aws/cloudformation/link"
+	"github.com/pkg/errors"
+)
+
+const (
+	// outputFormatJSON writes the stack description as JSON
+	outputFormatJSON = link.FormatJSON
+	// outputFormatYAML writes the stack description as YAML
+	outputFormatYAML = link.FormatYAML
+)
+
+// validateOutputFormat returns an error if format isn't a supported
+// --format value
+func validateOutputFormat(format string) error {
+	switch format {
+	case outputFormatJSON, outputFormatYAML:
+		return nil
+	default:
+		return errors.Errorf("Unsupported --format value: %s. Must be one of: %s, %s",
+			format,
+			outputFormatJSON,
+			outputFormatYAML)
+	}
+}
+
+// ensureOutputDirectory creates the outputDirectory, including any
+// parents, if it doesn't exist. It returns an error if the path exists
+// but isn't a directory.
+func ensureOutputDirectory(outputDirectory string) error {
+	osStat, osStatErr := os.Stat(outputDirectory)
+	if os.IsNotExist(osStatErr) {
+		mkdirErr := os.MkdirAll(outputDirectory, 0755)
+		if mkdirErr != nil {
+			return errors.Wrapf(mkdirErr, "Attempting to create --output directory: %s", outputDirectory)
+		}
+		return nil
+	}
+	if nil != osStatErr {
+		return osStatErr
+	}
+	if !osStat.IsDir() {
+		return errors.Errorf("--output (%s) is not a valid directory", outputDirectory)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/mweagle/Sparta/aws/cloudformation/link"
+)
+
+func TestDescribeStacksGzip(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-output")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mockClient := &mockCloudFormationClient{}
+	options := optionsLinkStruct{
+		StackNames:      []string{"MyStack"},
+		OutputDirectory: tempDir,
+		Gzip:            true,
+	}
+	describeErr := describeStacks(mockClient, options, ioutil.Discard)
+	if describeErr != nil {
+		t.Fatalf("Failed to describe stacks: %s", describeErr)
+	}
+	if _, statErr := os.Stat(filepath.Join(tempDir, "MyStack.json")); !os.IsNotExist(statErr) {
+		t.Fatalf("Expected only the compressed output file")
+	}
+	outputFile, outputFileErr := os.Open(filepath.Join(tempDir, "MyStack.json.gz"))
+	if outputFileErr != nil {
+		t.Fatalf("Expected compressed output file: %s", outputFileErr)
+	}
+	defer outputFile.Close()
+	gzipReader, gzipReaderErr := gzip.NewReader(outputFile)
+	if gzipReaderErr != nil {
+		t.Fatalf("Failed to open gzip output: %s", gzipReaderErr)
+	}
+	decompressed, decompressedErr := ioutil.ReadAll(gzipReader)
+	if decompressedErr != nil {
+		t.Fatalf("Failed to read gzip output: %s", decompressedErr)
+	}
+	mockResponse, _ := (&mockCloudFormationClient{}).DescribeStacks(&cloudformation.DescribeStacksInput{
+		StackName: aws.String("MyStack"),
+	})
+	expected, expectedErr := link.MarshalOutput(mockResponse, link.FormatJSON)
+	if expectedErr != nil {
+		t.Fatal(expectedErr)
+	}
+	if string(decompressed) != string(expected) {
+		t.Fatalf("Expected decompressed output to match the response.\nExpected: %s\nFound: %s",
+			expected,
+			decompressed)
+	}
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	for _, eachFormat := range []string{outputFormatJSON, outputFormatYAML} {
+		if err := validateOutputFormat(eachFormat); err != nil {
+			t.Fatalf("Expected %s to be valid: %s", eachFormat, err)
+		}
+	}
+	if err := validateOutputFormat("xml"); err == nil {
+		t.Fatalf("Expected xml format to be rejected")
+	}
+}
+
+func TestEnsureOutputDirectory(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-output")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Existing directory
+	if err := ensureOutputDirectory(tempDir); err != nil {
+		t.Fatalf("Expected existing directory to be accepted: %s", err)
+	}
+	// Missing directory, including parents
+	missingDir := filepath.Join(tempDir, "artifacts", "stacks")
+	if err := ensureOutputDirectory(missingDir); err != nil {
+		t.Fatalf("Expected missing directory to be created: %s", err)
+	}
+	osStat, osStatErr := os.Stat(missingDir)
+	if osStatErr != nil || !osStat.IsDir() {
+		t.Fatalf("Expected %s to be a directory: %v", missingDir, osStatErr)
+	}
+	// Path is a file
+	filePath := filepath.Join(tempDir, "stacks.json")
+	if err := ioutil.WriteFile(filePath, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureOutputDirectory(filePath); err == nil {
+		t.Fatalf("Expected file path to be rejected")
+	}
+}
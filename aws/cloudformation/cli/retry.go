@@ -0,0 +1,141 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+const (
+	// defaultMaxRetries is the default number of times a throttled
+	// CloudFormation call is retried
+	defaultMaxRetries = 5
+	// retryBaseDelay is the upper bound of the first retry delay. The
+	// bound doubles with each retry.
+	retryBaseDelay = 100 * time.Millisecond
+	// retryMaxDelay caps the retry delay
+	retryMaxDelay = 5 * time.Second
+)
+
+// retrySleep waits before a retry. Tests replace it to avoid sleeping.
+var retrySleep = time.Sleep
+
+// throttlingErrorCodes are the AWS error codes CloudFormation returns when
+// the API rate limit is exceeded
+var throttlingErrorCodes = map[string]bool{
+	"Throttling":               true,
+	"ThrottlingException":      true,
+	"RequestLimitExceeded":     true,
+	"TooManyRequestsException": true,
+}
+
+// isThrottlingError returns true if err is an AWS rate limit error
+func isThrottlingError(err error) bool {
+	awsErr, isAWSErr := err.(awserr.Error)
+	return isAWSErr && throttlingErrorCodes[awsErr.Code()]
+}
+
+// retryDelay returns the exponential backoff delay, with full jitter,
+// before the given zero based retry
+func retryDelay(retry int) time.Duration {
+	maxDelay := retryMaxDelay
+	if retry < 16 && retryBaseDelay<<uint(retry) < retryMaxDelay {
+		maxDelay = retryBaseDelay << uint(retry)
+	}
+	return time.Duration(rand.Int63n(int64(maxDelay) + 1))
+}
+
+// throttleRetryDescriber retries CloudFormation calls that fail with a
+// throttling error, up to maxRetries times. Other errors are returned
+// immediately.
+type throttleRetryDescriber struct {
+	cfnDescriber
+	maxRetries int
+}
+
+// retry calls apiCall, backing off and retrying while it's throttled
+func (retrier *throttleRetryDescriber) retry(apiCall func() error) error {
+	for retry := 0; ; retry++ {
+		callErr := apiCall()
+		if callErr == nil ||
+			!isThrottlingError(callErr) ||
+			retry >= retrier.maxRetries {
+			return callErr
+		}
+		retrySleep(retryDelay(retry))
+	}
+}
+
+// DescribeStacks calls the wrapped DescribeStacks, retrying while it's
+// throttled
+func (retrier *throttleRetryDescriber) DescribeStacks(input *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+	var output *cloudformation.DescribeStacksOutput
+	retryErr := retrier.retry(func() error {
+		var callErr error
+		output, callErr = retrier.cfnDescriber.DescribeStacks(input)
+		return callErr
+	})
+	return output, retryErr
+}
+
+// GetTemplate calls the wrapped GetTemplate, retrying while it's
+// throttled
+func (retrier *throttleRetryDescriber) GetTemplate(input *cloudformation.GetTemplateInput) (*cloudformation.GetTemplateOutput, error) {
+	var output *cloudformation.GetTemplateOutput
+	retryErr := retrier.retry(func() error {
+		var callErr error
+		output, callErr = retrier.cfnDescriber.GetTemplate(input)
+		return callErr
+	})
+	return output, retryErr
+}
+
+// DescribeStackEvents calls the wrapped DescribeStackEvents, retrying
+// while it's throttled
+func (retrier *throttleRetryDescriber) DescribeStackEvents(input *cloudformation.DescribeStackEventsInput) (*cloudformation.DescribeStackEventsOutput, error) {
+	var output *cloudformation.DescribeStackEventsOutput
+	retryErr := retrier.retry(func() error {
+		var callErr error
+		output, callErr = retrier.cfnDescriber.DescribeStackEvents(input)
+		return callErr
+	})
+	return output, retryErr
+}
+
+// DescribeStackResources calls the wrapped DescribeStackResources,
+// retrying while it's throttled
+func (retrier *throttleRetryDescriber) DescribeStackResources(input *cloudformation.DescribeStackResourcesInput) (*cloudformation.DescribeStackResourcesOutput, error) {
+	var output *cloudformation.DescribeStackResourcesOutput
+	retryErr := retrier.retry(func() error {
+		var callErr error
+		output, callErr = retrier.cfnDescriber.DescribeStackResources(input)
+		return callErr
+	})
+	return output, retryErr
+}
+
+// ListStacks calls the wrapped ListStacks, retrying while it's throttled
+func (retrier *throttleRetryDescriber) ListStacks(input *cloudformation.ListStacksInput) (*cloudformation.ListStacksOutput, error) {
+	var output *cloudformation.ListStacksOutput
+	retryErr := retrier.retry(func() error {
+		var callErr error
+		output, callErr = retrier.cfnDescriber.ListStacks(input)
+		return callErr
+	})
+	return output, retryErr
+}
+
+// withThrottleRetries returns svc with throttled calls retried up to
+// maxRetries times. A maxRetries of 0 returns svc
+// unchanged.
+func withThrottleRetries(svc cfnDescriber, maxRetries int) cfnDescriber {
+	if maxRetries <= 0 {
+		return svc
+	}
+	return &throttleRetryDescriber{
+		cfnDescriber: svc,
+		maxRetries:   maxRetries,
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// throttlingCloudFormationClient fails the first throttleCount
+// DescribeStacks calls with failure
+type throttlingCloudFormationClient struct {
+	mockCloudFormationClient
+	throttleCount int
+	failure       error
+}
+
+func (mock *throttlingCloudFormationClient) DescribeStacks(input *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+	if mock.describeCalls < mock.throttleCount {
+		mock.describeCalls++
+		return nil, mock.failure
+	}
+	return mock.mockCloudFormationClient.DescribeStacks(input)
+}
+
+func TestThrottleRetryDescriber(t *testing.T) {
+	savedSleep := retrySleep
+	defer func() {
+		retrySleep = savedSleep
+	}()
+	var delays []time.Duration
+	retrySleep = func(delay time.Duration) {
+		delays = append(delays, delay)
+	}
+	throttleErr := awserr.New("Throttling", "Rate exceeded", nil)
+	input := &cloudformation.DescribeStacksInput{StackName: aws.String("MyStack")}
+
+	// Throttled once, then succeeds
+	mockClient := &throttlingCloudFormationClient{
+		throttleCount: 1,
+		failure:       throttleErr,
+	}
+	response, responseErr := withThrottleRetries(mockClient, 3).DescribeStacks(input)
+	if responseErr != nil {
+		t.Fatalf("Expected the throttled call to eventually succeed: %s", responseErr)
+	}
+	if len(response.Stacks) != 1 || mockClient.describeCalls != 2 || len(delays) != 1 {
+		t.Fatalf("Expected a single retry. Found %d calls and %d delays",
+			mockClient.describeCalls,
+			len(delays))
+	}
+
+	// Throttled more often than --maxRetries allows
+	delays = nil
+	mockClient = &throttlingCloudFormationClient{
+		throttleCount: 10,
+		failure:       throttleErr,
+	}
+	_, responseErr = withThrottleRetries(mockClient, 3).DescribeStacks(input)
+	if !isThrottlingError(responseErr) || mockClient.describeCalls != 4 {
+		t.Fatalf("Expected the throttling error after 3 retries. Found %d calls: %v",
+			mockClient.describeCalls,
+			responseErr)
+	}
+
+	// Other errors aren't retried
+	delays = nil
+	mockClient = &throttlingCloudFormationClient{
+		throttleCount: 1,
+		failure:       awserr.New("AccessDenied", "Not authorized", nil),
+	}
+	_, responseErr = withThrottleRetries(mockClient, 3).DescribeStacks(input)
+	if responseErr == nil || mockClient.describeCalls != 1 || len(delays) != 0 {
+		t.Fatalf("Expected a non-throttling error to fail immediately. Found %d calls",
+			mockClient.describeCalls)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for retry := 0; retry != 64; retry++ {
+		delay := retryDelay(retry)
+		if delay < 0 || delay > retryMaxDelay {
+			t.Fatalf("Retry %d delay %s is out of bounds", retry, delay)
+		}
+		if retry == 0 && delay > retryBaseDelay {
+			t.Fatalf("Expected the first delay to be at most %s. Found: %s", retryBaseDelay, delay)
+		}
+	}
+}
+
+func TestNewCFNDescriberDisablesSDKRetries(t *testing.T) {
+	sess, sessErr := session.NewSession(&aws.Config{Region: aws.String("us-east-1")})
+	if sessErr != nil {
+		t.Fatal(sessErr)
+	}
+	client, isClient := newCFNDescriber(sess).(*cloudformation.CloudFormation)
+	if !isClient {
+		t.Fatalf("Expected a CloudFormation client")
+	}
+	// Otherwise each --maxRetries attempt is also retried by the SDK
+	if client.Config.MaxRetries == nil || aws.IntValue(client.Config.MaxRetries) != 0 {
+		t.Fatalf("Expected the SDK retryer to be disabled. Found: %v", client.Config.MaxRetries)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/pkg/errors"
+)
+
+// reRegion matches plausible AWS region names (us-east-1, us-gov-west-1,
+// cn-north-1, ap-southeast-2, ...)
+var reRegion = regexp.MustCompile(`^[a-z]{2}(-gov|-iso[a-z]?)?-[a-z]+-[0-9]{1,2}$`)
+
+// validateRegion returns an error if the region doesn't look like an
+// AWS region name
+func validateRegion(region string) error {
+	if !reRegion.MatchString(region) {
+		return errors.Errorf("--region (%s) is not a valid AWS region name", region)
+	}
+	return nil
+}
+
+// sessionOptions returns the session options for the --region and
+// --profile flags. A --region value takes precedence over AWS_REGION
+// and the profile's region. A --profile value takes precedence over
+// AWS_PROFILE and enables the shared config file so that the
+// profile's region is honored.
+func sessionOptions(region string, profile string) session.Options {
+	options := session.Options{}
+	if region != "" {
+		options.Config.Region = aws.String(region)
+	}
+	if profile != "" {
+		options.Profile = profile
+		options.SharedConfigState = session.SharedConfigEnable
+	}
+	return options
+}
+
+// newSession returns the AWS session for the --region and --profile flags.
+// When neither is set the ambient environment and shared config are used.
+func newSession(region string, profile string) (*session.Session, error) {
+	if region == "" && profile == "" {
+		return session.NewSession()
+	}
+	return session.NewSessionWithOptions(sessionOptions(region, profile))
+}
+
+// newAssumeRoleCredentials returns the credentials for an assumed role.
+// Tests replace it to verify the provider configuration without calling
+// STS.
+var newAssumeRoleCredentials = stscreds.NewCredentials
+
+// validateRoleARN returns an error if roleArn isn't an IAM role ARN
+func validateRoleARN(roleArn string) error {
+	parsedARN, parsedARNErr := arn.Parse(roleArn)
+	if parsedARNErr != nil {
+		return errors.Wrapf(parsedARNErr, "--roleArn (%s) is not a valid ARN", roleArn)
+	}
+	if parsedARN.Service != "iam" || !strings.HasPrefix(parsedARN.Resource, "role/") {
+		return errors.Errorf("--roleArn (%s) is not an IAM role ARN", roleArn)
+	}
+	return nil
+}
+
+// assumeRoleSession returns a copy of sess that uses the credentials of
+// the assumed roleArn. The optional externalID is passed to
+// sts:AssumeRole. When roleArn is empty sess is returned unchanged.
+func assumeRoleSession(sess *session.Session,
+	roleArn string,
+	externalID string) *session.Session {
+	if roleArn == "" {
+		return sess
+	}
+	roleCredentials := newAssumeRoleCredentials(sess,
+		roleArn,
+		func(provider *stscreds.AssumeRoleProvider) {
+			if externalID != "" {
+				provider.ExternalID = aws.String(externalID)
+			}
+		})
+	return sess.Copy(&aws.Config{Credentials: roleCredentials})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestValidateRegion(t *testing.T) {
+	validRegions := []string{"us-east-1", "eu-west-3", "ap-southeast-2", "us-gov-west-1", "cn-north-1"}
+	for _, eachRegion := range validRegions {
+		if err := validateRegion(eachRegion); err != nil {
+			t.Errorf("Expected %s to be valid: %s", eachRegion, err)
+		}
+	}
+	invalidRegions := []string{"", "us-east", "US-EAST-1", "useast1", "us-east-1a"}
+	for _, eachRegion := range invalidRegions {
+		if err := validateRegion(eachRegion); err == nil {
+			t.Errorf("Expected %s to be invalid", eachRegion)
+		}
+	}
+}
+
+func TestSessionOptions(t *testing.T) {
+	options := sessionOptions("eu-west-1", "deploy")
+	if aws.StringValue(options.Config.Region) != "eu-west-1" {
+		t.Fatalf("Expected region override. Found: %s", aws.StringValue(options.Config.Region))
+	}
+	if options.Profile != "deploy" || options.SharedConfigState != session.SharedConfigEnable {
+		t.Fatalf("Expected profile with shared config enabled. Found: %#v", options)
+	}
+	options = sessionOptions("", "")
+	if options.Config.Region != nil || options.Profile != "" {
+		t.Fatalf("Expected empty options. Found: %#v", options)
+	}
+}
+
+func TestValidateRoleARN(t *testing.T) {
+	if err := validateRoleARN("arn:aws:iam::123456789012:role/LinkReader"); err != nil {
+		t.Fatalf("Expected role ARN to be valid: %s", err)
+	}
+	invalidARNs := []string{"LinkReader",
+		"arn:aws:iam::123456789012:user/someone",
+		"arn:aws:s3:::bucket/role/LinkReader"}
+	for _, eachARN := range invalidARNs {
+		if err := validateRoleARN(eachARN); err == nil {
+			t.Errorf("Expected %s to be invalid", eachARN)
+		}
+	}
+}
+
+func TestAssumeRoleSession(t *testing.T) {
+	savedFactory := newAssumeRoleCredentials
+	defer func() {
+		newAssumeRoleCredentials = savedFactory
+	}()
+	var configuredProvider stscreds.AssumeRoleProvider
+	roleCredentials := credentials.NewStaticCredentials("AKID", "SECRET", "TOKEN")
+	newAssumeRoleCredentials = func(c client.ConfigProvider,
+		roleARN string,
+		options ...func(*stscreds.AssumeRoleProvider)) *credentials.Credentials {
+		configuredProvider.RoleARN = roleARN
+		for _, eachOption := range options {
+			eachOption(&configuredProvider)
+		}
+		return roleCredentials
+	}
+
+	sess, sessErr := session.NewSession(&aws.Config{Region: aws.String("us-west-2")})
+	if sessErr != nil {
+		t.Fatal(sessErr)
+	}
+	if assumeRoleSession(sess, "", "") != sess {
+		t.Fatalf("Expected session to be unchanged without a role ARN")
+	}
+
+	roleArn := "arn:aws:iam::123456789012:role/LinkReader"
+	roleSession := assumeRoleSession(sess, roleArn, "shared-secret")
+	if configuredProvider.RoleARN != roleArn {
+		t.Fatalf("Expected provider role ARN %s. Found: %s", roleArn, configuredProvider.RoleARN)
+	}
+	if aws.StringValue(configuredProvider.ExternalID) != "shared-secret" {
+		t.Fatalf("Expected provider external ID. Found: %#v", configuredProvider.ExternalID)
+	}
+	if roleSession.Config.Credentials != roleCredentials {
+		t.Fatalf("Expected session to use the assumed role credentials")
+	}
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"github.com/mweagle/Sparta/aws/cloudformation/link"
+)
+
+// stackNameForFile returns the stack name to use in output filenames. See
+// link.StackNameForFile.
+func stackNameForFile(stackNameOrID string) string {
+	return link.StackNameForFile(stackNameOrID)
+}
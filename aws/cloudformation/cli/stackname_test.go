@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestStackNameForFile(t *testing.T) {
+	testCases := map[string]string{
+		"MyStack": "MyStack",
+		"arn:aws:cloudformation:us-west-2:123456789012:stack/MyStack/c4b1f6a0-53a5-11ea-8d71-0a5e4d2d1f3a": "MyStack",
+		"arn:aws:cloudformation:us-west-2:123456789012:other:thing":                                        "arn-aws-cloudformation-us-west-2-123456789012-other-thing",
+	}
+	for eachInput, eachExpected := range testCases {
+		fileName := stackNameForFile(eachInput)
+		if fileName != eachExpected {
+			t.Errorf("Expected %s for %s. Found: %s", eachExpected, eachInput, fileName)
+		}
+	}
+}
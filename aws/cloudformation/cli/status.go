@@ -0,0 +1,95 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/pkg/errors"
+)
+
+// stackStatuses are the valid --status values
+var stackStatuses = map[string]bool{
+	cloudformation.StackStatusCreateInProgress:                        true,
+	cloudformation.StackStatusCreateFailed:                            true,
+	cloudformation.StackStatusCreateComplete:                          true,
+	cloudformation.StackStatusRollbackInProgress:                      true,
+	cloudformation.StackStatusRollbackFailed:                          true,
+	cloudformation.StackStatusRollbackComplete:                        true,
+	cloudformation.StackStatusDeleteInProgress:                        true,
+	cloudformation.StackStatusDeleteFailed:                            true,
+	cloudformation.StackStatusDeleteComplete:                          true,
+	cloudformation.StackStatusUpdateInProgress:                        true,
+	cloudformation.StackStatusUpdateCompleteCleanupInProgress:         true,
+	cloudformation.StackStatusUpdateComplete:                          true,
+	cloudformation.StackStatusUpdateRollbackInProgress:                true,
+	cloudformation.StackStatusUpdateRollbackFailed:                    true,
+	cloudformation.StackStatusUpdateRollbackCompleteCleanupInProgress: true,
+	cloudformation.StackStatusUpdateRollbackComplete:                  true,
+	cloudformation.StackStatusReviewInProgress:                        true,
+	cloudformation.StackStatusImportInProgress:                        true,
+	cloudformation.StackStatusImportComplete:                          true,
+	cloudformation.StackStatusImportRollbackInProgress:                true,
+	cloudformation.StackStatusImportRollbackFailed:                    true,
+	cloudformation.StackStatusImportRollbackComplete:                  true,
+}
+
+// validateStackStatuses returns an error if any of the statuses isn't a
+// CloudFormation stack status
+func validateStackStatuses(statuses []string) error {
+	for _, eachStatus := range statuses {
+		if !stackStatuses[eachStatus] {
+			validStatuses := make([]string, 0, len(stackStatuses))
+			for eachValidStatus := range stackStatuses {
+				validStatuses = append(validStatuses, eachValidStatus)
+			}
+			sort.Strings(validStatuses)
+			return errors.Errorf("--status (%s) is not a valid stack status. Must be one of: %s",
+				eachStatus,
+				strings.Join(validStatuses, ", "))
+		}
+	}
+	return nil
+}
+
+// listStackIDs returns the IDs of every stack whose status is one of the
+// given statuses. IDs are returned rather than names so that deleted
+// stacks can be described.
+func listStackIDs(svc cfnDescriber, statuses []string) ([]string, error) {
+	params := &cloudformation.ListStacksInput{
+		StackStatusFilter: aws.StringSlice(statuses),
+	}
+	var stackIDs []string
+	for {
+		listStacksResponse, listStacksErr := svc.ListStacks(params)
+		if listStacksErr != nil {
+			return nil, errors.Wrap(listStacksErr, "Attempting to list stacks")
+		}
+		for _, eachSummary := range listStacksResponse.StackSummaries {
+			stackIDs = append(stackIDs, aws.StringValue(eachSummary.StackId))
+		}
+		if aws.StringValue(listStacksResponse.NextToken) == "" {
+			break
+		}
+		params.NextToken = listStacksResponse.NextToken
+	}
+	return stackIDs, nil
+}
+
+// targetStackNames returns the stacks to describe. These are the
+// --stackName values if provided, else the stacks matching --status. An
+// empty name, which describes every stack, is returned when neither is
+// provided. It's also returned when --status is combined with --tag, since
+// ListStacks doesn't return tags. In that case every stack is listed by a
+// single DescribeStacks enumeration and both filters are applied in
+// memory.
+func targetStackNames(svc cfnDescriber, options optionsLinkStruct) ([]string, error) {
+	if len(options.StackNames) != 0 {
+		return options.StackNames, nil
+	}
+	if len(options.Statuses) != 0 && len(options.Tags) == 0 {
+		return listStackIDs(svc, options.Statuses)
+	}
+	return []string{""}, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func TestValidateStackStatuses(t *testing.T) {
+	validStatuses := []string{cloudformation.StackStatusCreateComplete,
+		cloudformation.StackStatusRollbackComplete}
+	if err := validateStackStatuses(validStatuses); err != nil {
+		t.Fatalf("Expected statuses to be valid: %s", err)
+	}
+	if err := validateStackStatuses([]string{"create_complete"}); err == nil {
+		t.Fatalf("Expected lowercase status to be rejected")
+	}
+}
+
+func TestDescribeStacksByStatus(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-status")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mockClient := &mockCloudFormationClient{
+		summaries: []*cloudformation.StackSummary{
+			{
+				StackId:     aws.String("arn:aws:cloudformation:us-east-1:123456789012:stack/HealthyStack/guid-healthy"),
+				StackName:   aws.String("HealthyStack"),
+				StackStatus: aws.String(cloudformation.StackStatusCreateComplete),
+			},
+			{
+				StackId:     aws.String("arn:aws:cloudformation:us-east-1:123456789012:stack/FailedStack/guid-failed"),
+				StackName:   aws.String("FailedStack"),
+				StackStatus: aws.String(cloudformation.StackStatusRollbackComplete),
+			},
+		},
+	}
+	options := optionsLinkStruct{
+		Statuses:        []string{cloudformation.StackStatusCreateComplete},
+		OutputDirectory: tempDir,
+	}
+	describeErr := describeStacks(mockClient, options, ioutil.Discard)
+	if describeErr != nil {
+		t.Fatalf("Failed to describe stacks: %s", describeErr)
+	}
+	if _, statErr := os.Stat(filepath.Join(tempDir, "HealthyStack.json")); statErr != nil {
+		t.Fatalf("Expected CREATE_COMPLETE stack output: %s", statErr)
+	}
+	if _, statErr := os.Stat(filepath.Join(tempDir, "FailedStack.json")); !os.IsNotExist(statErr) {
+		t.Fatalf("Expected ROLLBACK_COMPLETE stack to be excluded")
+	}
+}
+
+func TestDescribeStacksByStatusAndTags(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-status")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mockClient := &mockPaginatedCloudFormationClient{
+		pages: [][]string{
+			{"PaymentsProd", "PaymentsFailed"},
+			{"SearchProd", "PaymentsDev"},
+		},
+		tags: map[string][]*cloudformation.Tag{
+			"PaymentsProd":   stackTags("Environment", "prod"),
+			"PaymentsFailed": stackTags("Environment", "prod"),
+			"SearchProd":     stackTags("Environment", "prod"),
+			"PaymentsDev":    stackTags("Environment", "dev"),
+		},
+		statuses: map[string]string{
+			"PaymentsFailed": cloudformation.StackStatusRollbackComplete,
+			"SearchProd":     cloudformation.StackStatusUpdateComplete,
+		},
+	}
+	options := optionsLinkStruct{
+		Statuses: []string{cloudformation.StackStatusCreateComplete,
+			cloudformation.StackStatusUpdateComplete},
+		Tags:            []string{"Environment=prod"},
+		OutputDirectory: tempDir,
+	}
+	describeErr := describeStacks(mockClient, options, ioutil.Discard)
+	if describeErr != nil {
+		t.Fatalf("Failed to describe stacks: %s", describeErr)
+	}
+	if mockClient.listStackCalls != 0 || mockClient.describeCalls != len(mockClient.pages) {
+		t.Fatalf("Expected a single DescribeStacks listing. Found %d ListStacks and %d DescribeStacks calls",
+			mockClient.listStackCalls,
+			mockClient.describeCalls)
+	}
+	outputBytes, outputBytesErr := ioutil.ReadFile(filepath.Join(tempDir, allStacksFileName+".json"))
+	if outputBytesErr != nil {
+		t.Fatalf("Failed to read output file: %s", outputBytesErr)
+	}
+	var allStacks cloudformation.DescribeStacksOutput
+	unmarshalErr := json.Unmarshal(outputBytes, &allStacks)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal output: %s", unmarshalErr)
+	}
+	stackNames := make([]string, 0, len(allStacks.Stacks))
+	for _, eachStack := range allStacks.Stacks {
+		stackNames = append(stackNames, aws.StringValue(eachStack.StackName))
+	}
+	if len(stackNames) != 2 || stackNames[0] != "PaymentsProd" || stackNames[1] != "SearchProd" {
+		t.Fatalf("Expected only the stacks matching both the tag and a status. Found: %v", stackNames)
+	}
+}
+
+func TestDescribeStackNameByStatus(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-status")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	options := optionsLinkStruct{
+		StackNames:      []string{"MyStack"},
+		Statuses:        []string{cloudformation.StackStatusUpdateComplete},
+		OutputDirectory: tempDir,
+	}
+	var output bytes.Buffer
+	describeErr := describeStacks(&mockCloudFormationClient{}, options, &output)
+	if describeErr != nil {
+		t.Fatalf("Failed to describe stacks: %s", describeErr)
+	}
+	if _, statErr := os.Stat(filepath.Join(tempDir, "MyStack.json")); !os.IsNotExist(statErr) {
+		t.Fatalf("Expected the CREATE_COMPLETE stack to be excluded by --status")
+	}
+	if !strings.Contains(output.String(), "No matching stacks: MyStack") {
+		t.Fatalf("Expected a no matching stacks message. Found: %s", output.String())
+	}
+}
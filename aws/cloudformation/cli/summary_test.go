@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/mweagle/Sparta/aws/cloudformation/link"
+)
+
+// summaryOnlyClient fails the test if --summary-only makes any call other
+// than DescribeStacks
+type summaryOnlyClient struct {
+	*mockCloudFormationClient
+	t *testing.T
+}
+
+func (client *summaryOnlyClient) GetTemplate(input *cloudformation.GetTemplateInput) (*cloudformation.GetTemplateOutput, error) {
+	client.t.Fatalf("Unexpected GetTemplate call with --summary-only")
+	return nil, nil
+}
+
+func (client *summaryOnlyClient) DescribeStackEvents(input *cloudformation.DescribeStackEventsInput) (*cloudformation.DescribeStackEventsOutput, error) {
+	client.t.Fatalf("Unexpected DescribeStackEvents call with --summary-only")
+	return nil, nil
+}
+
+func (client *summaryOnlyClient) DescribeStackResources(input *cloudformation.DescribeStackResourcesInput) (*cloudformation.DescribeStackResourcesOutput, error) {
+	client.t.Fatalf("Unexpected DescribeStackResources call with --summary-only")
+	return nil, nil
+}
+
+func TestDescribeStacksSummaryOnly(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-summary")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mockClient := &summaryOnlyClient{
+		mockCloudFormationClient: &mockCloudFormationClient{},
+		t:                        t,
+	}
+	options := optionsLinkStruct{
+		StackNames:      []string{"StackOne", "StackTwo"},
+		OutputDirectory: tempDir,
+		SummaryOnly:     true,
+	}
+	var output bytes.Buffer
+	describeErr := describeStacks(mockClient, options, &output)
+	if describeErr != nil {
+		t.Fatalf("Failed to describe stacks: %s", describeErr)
+	}
+	if mockClient.describeCalls != len(options.StackNames) {
+		t.Fatalf("Expected a DescribeStacks call per stack. Found: %d", mockClient.describeCalls)
+	}
+	for _, eachStackName := range options.StackNames {
+		outputBytes, outputErr := ioutil.ReadFile(filepath.Join(tempDir, eachStackName+".json"))
+		if outputErr != nil {
+			t.Fatalf("Expected output file for %s: %s", eachStackName, outputErr)
+		}
+		var summaries []*link.StackSummary
+		unmarshalErr := json.Unmarshal(outputBytes, &summaries)
+		if unmarshalErr != nil {
+			t.Fatalf("Expected the stack summaries: %s", unmarshalErr)
+		}
+		if len(summaries) != 1 || summaries[0].StackName != eachStackName {
+			t.Fatalf("Unexpected summaries: %s", string(outputBytes))
+		}
+		expected := `{"StackName":"` + eachStackName + `","StackStatus":"CREATE_COMPLETE","Outputs":{}}`
+		if !strings.Contains(output.String(), expected) {
+			t.Fatalf("Expected output to contain the summary %s. Found: %s", expected, output.String())
+		}
+	}
+	if strings.Contains(output.String(), "Stacks: [") {
+		t.Fatalf("Expected the full DescribeStacks response not to be written. Found: %s", output.String())
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// parseStackTags returns the --tag key=value pairs as a map. Values may be
+// empty, keys may not.
+func parseStackTags(tags []string) (map[string]string, error) {
+	parsedTags := make(map[string]string, len(tags))
+	for _, eachTag := range tags {
+		keyValue := strings.SplitN(eachTag, "=", 2)
+		if len(keyValue) != 2 || keyValue[0] == "" {
+			return nil, errors.Errorf("--tag (%s) must be of the form key=value", eachTag)
+		}
+		if existingValue, exists := parsedTags[keyValue[0]]; exists && existingValue != keyValue[1] {
+			return nil, errors.Errorf("--tag key %s is defined more than once", keyValue[0])
+		}
+		parsedTags[keyValue[0]] = keyValue[1]
+	}
+	return parsedTags, nil
+}
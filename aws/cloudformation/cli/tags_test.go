@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func stackTags(keyValues ...string) []*cloudformation.Tag {
+	var tags []*cloudformation.Tag
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		tags = append(tags, &cloudformation.Tag{
+			Key:   aws.String(keyValues[i]),
+			Value: aws.String(keyValues[i+1]),
+		})
+	}
+	return tags
+}
+
+func TestParseStackTags(t *testing.T) {
+	parsedTags, parsedTagsErr := parseStackTags([]string{"Team=payments", "Empty="})
+	if parsedTagsErr != nil {
+		t.Fatalf("Failed to parse tags: %s", parsedTagsErr)
+	}
+	if parsedTags["Team"] != "payments" || len(parsedTags) != 2 {
+		t.Fatalf("Unexpected tags: %v", parsedTags)
+	}
+	for _, eachInvalid := range [][]string{{"Team"}, {"=payments"}, {"Team=a", "Team=b"}} {
+		if _, invalidErr := parseStackTags(eachInvalid); invalidErr == nil {
+			t.Fatalf("Expected an error for tags: %v", eachInvalid)
+		}
+	}
+}
+
+func TestDescribeStacksByTags(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-tags")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mockClient := &mockPaginatedCloudFormationClient{
+		pages: [][]string{
+			{"PaymentsProd", "PaymentsDev"},
+			{"SearchProd"},
+		},
+		tags: map[string][]*cloudformation.Tag{
+			"PaymentsProd": stackTags("Team", "payments", "Environment", "prod"),
+			"PaymentsDev":  stackTags("Team", "payments", "Environment", "dev"),
+			"SearchProd":   stackTags("Team", "search", "Environment", "prod"),
+		},
+	}
+	options := optionsLinkStruct{
+		Tags:            []string{"Team=payments", "Environment=prod"},
+		OutputDirectory: tempDir,
+	}
+	describeErr := describeStacks(mockClient, options, ioutil.Discard)
+	if describeErr != nil {
+		t.Fatalf("Failed to describe stacks: %s", describeErr)
+	}
+	outputBytes, outputBytesErr := ioutil.ReadFile(filepath.Join(tempDir, allStacksFileName+".json"))
+	if outputBytesErr != nil {
+		t.Fatalf("Failed to read output file: %s", outputBytesErr)
+	}
+	var allStacks cloudformation.DescribeStacksOutput
+	unmarshalErr := json.Unmarshal(outputBytes, &allStacks)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal output: %s", unmarshalErr)
+	}
+	if len(allStacks.Stacks) != 1 ||
+		aws.StringValue(allStacks.Stacks[0].StackName) != "PaymentsProd" {
+		t.Fatalf("Expected only the stack matching both tags. Found: %s", string(outputBytes))
+	}
+}
+
+func TestDescribeStackNameTagMismatch(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-tags")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	options := optionsLinkStruct{
+		StackNames:      []string{"MyStack"},
+		Tags:            []string{"Team=payments"},
+		OutputDirectory: tempDir,
+	}
+	var output bytes.Buffer
+	describeErr := describeStacks(&mockCloudFormationClient{}, options, &output)
+	if describeErr != nil {
+		t.Fatalf("Failed to describe stacks: %s", describeErr)
+	}
+	entries, entriesErr := ioutil.ReadDir(tempDir)
+	if entriesErr != nil {
+		t.Fatal(entriesErr)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("Expected no output file for a stack that doesn't match --tag. Found: %d files",
+			len(entries))
+	}
+	if strings.Contains(output.String(), "Created file") ||
+		!strings.Contains(output.String(), "No matching stacks: MyStack") {
+		t.Fatalf("Expected a no matching stacks message. Found: %s", output.String())
+	}
+}
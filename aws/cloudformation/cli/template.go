@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/pkg/errors"
+)
+
+// templateFileExtension returns the file extension, without the leading
+// period, for a template body. CloudFormation returns templates in the
+// format they were authored, so JSON is detected by the leading brace.
+func templateFileExtension(templateBody string) string {
+	if strings.HasPrefix(strings.TrimSpace(templateBody), "{") {
+		return outputFormatJSON
+	}
+	return outputFormatYAML
+}
+
+// writeStackTemplates fetches the template for each stack and writes it to
+// <stackName>.template.<ext> in the output directory. Stacks without a
+// retrievable template, such as those that have been deleted, are
+// reported to w and skipped.
+func writeStackTemplates(svc cfnDescriber,
+	stacks []*cloudformation.Stack,
+	options optionsLinkStruct,
+	w io.Writer) error {
+	for _, eachStack := range stacks {
+		stackName := aws.StringValue(eachStack.StackName)
+		params := &cloudformation.GetTemplateInput{
+			StackName: aws.String(stackName),
+		}
+		if eachStack.StackId != nil {
+			params.StackName = eachStack.StackId
+		}
+		getTemplateResponse, getTemplateErr := svc.GetTemplate(params)
+		if getTemplateErr != nil {
+			fmt.Fprintf(w, "No template available for stack %s: %s\n", stackName, getTemplateErr)
+			continue
+		}
+		templateBody := aws.StringValue(getTemplateResponse.TemplateBody)
+		if templateBody == "" {
+			fmt.Fprintf(w, "No template available for stack %s\n", stackName)
+			continue
+		}
+		templateFilepath := filepath.Join(options.OutputDirectory,
+			fmt.Sprintf("%s.template.%s",
+				stackNameForFile(stackName),
+				templateFileExtension(templateBody)))
+		// Stream the body rather than copying it, since templates can be
+		// large
+		templateFilepath, outputErr := emitOutputStream(templateFilepath,
+			strings.NewReader(templateBody),
+			options)
+		if outputErr != nil {
+			return errors.Wrapf(outputErr, "Attempting to write template file for stack %s", stackName)
+		}
+		fmt.Fprintln(w, outputFileMessage(templateFilepath, options))
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplateFileExtension(t *testing.T) {
+	expected := map[string]string{
+		"{\"Resources\": {}}":          outputFormatJSON,
+		"  \n{\"Resources\": {}}":      outputFormatJSON,
+		"AWSTemplateFormatVersion: ''": outputFormatYAML,
+	}
+	for eachBody, eachExtension := range expected {
+		if extension := templateFileExtension(eachBody); extension != eachExtension {
+			t.Fatalf("Expected %s extension for %q. Found: %s", eachExtension, eachBody, extension)
+		}
+	}
+}
+
+func TestDescribeIncludeTemplate(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-template")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mockClient := &mockCloudFormationClient{
+		templates: map[string]string{
+			"JSONStack": "{\"Resources\": {}}",
+			"YAMLStack": "Resources: {}\n",
+		},
+	}
+	options := optionsLinkStruct{
+		StackNames:      []string{"JSONStack", "YAMLStack", "DeletedStack"},
+		OutputDirectory: tempDir,
+		IncludeTemplate: true,
+	}
+	describeErr := describeStacks(mockClient, options, ioutil.Discard)
+	if describeErr != nil {
+		t.Fatalf("Failed to describe stacks: %s", describeErr)
+	}
+	for eachStackName, eachTemplateFile := range map[string]string{
+		"JSONStack": "JSONStack.template.json",
+		"YAMLStack": "YAMLStack.template.yaml",
+	} {
+		templateBytes, templateBytesErr := ioutil.ReadFile(filepath.Join(tempDir, eachTemplateFile))
+		if templateBytesErr != nil {
+			t.Fatalf("Expected template file %s: %s", eachTemplateFile, templateBytesErr)
+		}
+		if string(templateBytes) != mockClient.templates[eachStackName] {
+			t.Fatalf("Unexpected template body for %s: %s", eachStackName, string(templateBytes))
+		}
+	}
+	// Stacks without a template are skipped
+	matches, _ := filepath.Glob(filepath.Join(tempDir, "DeletedStack.template.*"))
+	if len(matches) != 0 {
+		t.Fatalf("Expected no template file for DeletedStack. Found: %v", matches)
+	}
+}
+
+func TestDescribeIncludeLargeTemplate(t *testing.T) {
+	// Large enough that buffering it twice would be noticeable
+	largeTemplate := "{\"Resources\": {" +
+		strings.Repeat("\"Bucket\": {\"Type\": \"AWS::S3::Bucket\"},", 256*1024) +
+		"}}"
+	mockClient := &mockCloudFormationClient{
+		templates: map[string]string{
+			"LargeStack": largeTemplate,
+		},
+	}
+	for _, eachGzip := range []bool{false, true} {
+		tempDir, tempDirErr := ioutil.TempDir("", "link-template")
+		if tempDirErr != nil {
+			t.Fatal(tempDirErr)
+		}
+		defer os.RemoveAll(tempDir)
+
+		options := optionsLinkStruct{
+			StackNames:      []string{"LargeStack"},
+			OutputDirectory: tempDir,
+			IncludeTemplate: true,
+			Gzip:            eachGzip,
+		}
+		describeErr := describeStacks(mockClient, options, ioutil.Discard)
+		if describeErr != nil {
+			t.Fatalf("Failed to describe stacks: %s", describeErr)
+		}
+		templateFilepath := filepath.Join(tempDir, "LargeStack.template.json")
+		if eachGzip {
+			templateFilepath += ".gz"
+		}
+		templateFile, templateFileErr := os.Open(templateFilepath)
+		if templateFileErr != nil {
+			t.Fatalf("Expected template file %s: %s", templateFilepath, templateFileErr)
+		}
+		defer templateFile.Close()
+		var templateBytes []byte
+		var readErr error
+		if eachGzip {
+			gzipReader, gzipReaderErr := gzip.NewReader(templateFile)
+			if gzipReaderErr != nil {
+				t.Fatalf("Failed to open gzip template file: %s", gzipReaderErr)
+			}
+			templateBytes, readErr = ioutil.ReadAll(gzipReader)
+		} else {
+			templateBytes, readErr = ioutil.ReadAll(templateFile)
+		}
+		if readErr != nil {
+			t.Fatalf("Failed to read template file: %s", readErr)
+		}
+		if string(templateBytes) != largeTemplate {
+			t.Fatalf("Unexpected template body (gzip: %t). Expected %d bytes, found %d",
+				eachGzip,
+				len(largeTemplate),
+				len(templateBytes))
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Package link provides the stack serialization used by the link CLI
+// (aws/cloudformation/cli) so that other tools can describe an existing
+// CloudFormation stack to disk without shelling out to the command
+package link
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/pkg/errors"
+)
+
+// StackDescriber is the subset of the CloudFormation API needed to
+// describe stacks. *cloudformation.CloudFormation satisfies it.
+type StackDescriber interface {
+	DescribeStacks(*cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error)
+}
+
+// AllStacksFileName is the output file basename used when an empty stack
+// name is provided and every stack in the region is described
+const AllStacksFileName = "stacks"
+
+// DescribeStacksAllPages follows NextToken until every page of the
+// DescribeStacks response has been fetched and returns a single response
+// with all the stacks. An empty stackName describes every stack. AWS
+// errors are returned unwrapped so that callers can inspect them.
+func DescribeStacksAllPages(svc StackDescriber,
+	stackName string) (*cloudformation.DescribeStacksOutput, error) {
+	params := &cloudformation.DescribeStacksInput{}
+	if stackName != "" {
+		params.StackName = aws.String(stackName)
+	}
+	allStacks := &cloudformation.DescribeStacksOutput{}
+	for {
+		describeStacksResponse, describeStacksResponseErr := svc.DescribeStacks(params)
+		if describeStacksResponseErr != nil {
+			return nil, describeStacksResponseErr
+		}
+		allStacks.Stacks = append(allStacks.Stacks, describeStacksResponse.Stacks...)
+		if aws.StringValue(describeStacksResponse.NextToken) == "" {
+			break
+		}
+		params.NextToken = describeStacksResponse.NextToken
+	}
+	return allStacks, nil
+}
+
+// StackNameForFile returns the stack name to use in output filenames.
+// DescribeStacks accepts either a stack name or a stack ARN of the form
+// arn:aws:cloudformation:region:account:stack/name/id. ARNs are reduced
+// to the stack name and any remaining path separators are replaced.
+func StackNameForFile(stackNameOrID string) string {
+	stackName := stackNameOrID
+	if arn.IsARN(stackNameOrID) {
+		parsedARN, parsedARNErr := arn.Parse(stackNameOrID)
+		if parsedARNErr == nil {
+			resourceParts := strings.Split(parsedARN.Resource, "/")
+			if len(resourceParts) >= 2 && resourceParts[1] != "" {
+				stackName = resourceParts[1]
+			}
+		}
+	}
+	return strings.NewReplacer("/", "-", ":", "-", "\\", "-").Replace(stackName)
+}
+
+// DescribeOptions control how DescribeStack serializes a stack. The zero
+// value writes the full DescribeStacks response as uncompressed JSON.
+type DescribeOptions struct {
+	// Format is FormatJSON or FormatYAML. An empty Format is treated as
+	// FormatJSON.
+	Format string
+	// Gzip compresses the output file and appends the .gz extension
+	Gzip bool
+	// SummaryOnly serializes a StackSummary per stack rather than the full
+	// DescribeStacks response
+	SummaryOnly bool
+	// Tags limits the description to the stacks that define every
+	// key/value pair
+	Tags map[string]string
+	// Statuses limits the description to the stacks whose status is one
+	// of the values. Stacks must also match Tags.
+	Statuses []string
+	// DryRun computes the output path without writing the file
+	DryRun bool
+}
+
+// DescribeResult is the outcome of describing a stack
+type DescribeResult struct {
+	// OutputPath is the path of the file that was, or with DryRun would
+	// be, written. It's empty for Describe and when no stacks matched.
+	OutputPath string
+	// Response is the DescribeStacks response, limited to the stacks
+	// matching DescribeOptions.Tags and DescribeOptions.Statuses
+	Response *cloudformation.DescribeStacksOutput
+	// Summaries are the stack summaries. They're only set when
+	// DescribeOptions.SummaryOnly is true.
+	Summaries []*StackSummary
+}
+
+// Serialized returns the value written to the output file, which
+// respects DescribeOptions.SummaryOnly
+func (result *DescribeResult) Serialized() interface{} {
+	if result.Summaries != nil {
+		return result.Summaries
+	}
+	return result.Response
+}
+
+// HasStacks returns true if at least one stack matched
+func (result *DescribeResult) HasStacks() bool {
+	return result.Response != nil && len(result.Response.Stacks) != 0
+}
+
+// StackOutputPath returns the path of the file DescribeStack writes for
+// stackName in outputDir, before any .gz extension. An empty stackName
+// describes every stack to AllStacksFileName.
+func StackOutputPath(outputDir string, stackName string, format string) string {
+	outputFileName := AllStacksFileName
+	if stackName != "" {
+		outputFileName = StackNameForFile(stackName)
+	}
+	return filepath.Join(outputDir,
+		fmt.Sprintf("%s.%s", outputFileName, OutputFileExtension(format)))
+}
+
+// Describe returns the description of stackName without writing it. Only
+// DescribeStacks is called, so SummaryOnly doesn't make any additional API
+// calls. The Tags and Statuses filters are applied in memory. AWS errors are returned unwrapped so that callers can inspect
+// them.
+func Describe(svc StackDescriber,
+	stackName string,
+	options DescribeOptions) (*DescribeResult, error) {
+	describeStacksResponse, describeStacksResponseErr := DescribeStacksAllPages(svc, stackName)
+	if describeStacksResponseErr != nil {
+		return nil, describeStacksResponseErr
+	}
+	describeStacksResponse = FilterStacksByStatus(describeStacksResponse, options.Statuses)
+	result := &DescribeResult{
+		Response: FilterStacksByTags(describeStacksResponse, options.Tags),
+	}
+	if options.SummaryOnly {
+		result.Summaries = SummarizeStacks(result.Response)
+	}
+	return result, nil
+}
+
+// DescribeStack writes the description of stackName to outputDir, as
+// described by options, and returns the result together with the path of
+// the file. An empty stackName describes every stack. The outputDir must
+// already exist. If no stack matches, for instance because options.Tags
+// or options.Statuses excluded every stack, nothing is written and the result has no
+// OutputPath. Use HasStacks to test for that case.
+func DescribeStack(svc StackDescriber,
+	stackName string,
+	outputDir string,
+	options DescribeOptions) (*DescribeResult, error) {
+	result, resultErr := Describe(svc, stackName, options)
+	if resultErr != nil {
+		return nil, resultErr
+	}
+	if !result.HasStacks() {
+		return result, nil
+	}
+	outputPath := StackOutputPath(outputDir, stackName, options.Format)
+	if options.DryRun {
+		result.OutputPath = OutputFilePath(outputPath, options.Gzip)
+		return result, nil
+	}
+	stackInfo, stackInfoErr := MarshalOutput(result.Serialized(), options.Format)
+	if stackInfoErr != nil {
+		return nil, errors.Wrapf(stackInfoErr, "Failed to describe stacks")
+	}
+	outputFilepath, writeErr := WriteOutputFile(outputPath, stackInfo, options.Gzip)
+	if writeErr != nil {
+		return nil, errors.Wrap(writeErr, "Attempting to write output file")
+	}
+	result.OutputPath = outputFilepath
+	return result, nil
+}
+
+// DescribeStackToDir writes the JSON DescribeStacks response for stackName
+// to <stackName>.json in outputDir and returns the path of the created
+// file. An empty stackName describes every stack to stacks.json. The
+// outputDir must already exist. If no stack exists nothing is written and
+// the returned path is empty. Use DescribeStack for the other output
+// options.
+func DescribeStackToDir(svc StackDescriber, stackName string, outputDir string) (string, error) {
+	result, resultErr := DescribeStack(svc, stackName, outputDir, DescribeOptions{})
+	if resultErr != nil {
+		return "", resultErr
+	}
+	return result.OutputPath, nil
+}
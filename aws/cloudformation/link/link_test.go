@@ -0,0 +1,178 @@
+package link
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/pkg/errors"
+)
+
+// mockStackDescriber returns one page per stack name in pages
+type mockStackDescriber struct {
+	pages [][]string
+}
+
+func (mock *mockStackDescriber) DescribeStacks(input *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+	if aws.StringValue(input.StackName) == "MissingStack" {
+		return nil, errors.New("Stack with id MissingStack does not exist")
+	}
+	pageIndex := 0
+	if input.NextToken != nil {
+		fmt.Sscanf(aws.StringValue(input.NextToken), "page%d", &pageIndex)
+	}
+	response := &cloudformation.DescribeStacksOutput{}
+	for _, eachStackName := range mock.pages[pageIndex] {
+		response.Stacks = append(response.Stacks, &cloudformation.Stack{
+			StackName:   aws.String(eachStackName),
+			StackStatus: aws.String("CREATE_COMPLETE"),
+		})
+	}
+	if pageIndex+1 < len(mock.pages) {
+		response.NextToken = aws.String(fmt.Sprintf("page%d", pageIndex+1))
+	}
+	return response, nil
+}
+
+func TestDescribeStackToDir(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-library")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mockClient := &mockStackDescriber{
+		pages: [][]string{{"StackOne"}, {"StackTwo"}},
+	}
+	stackARN := "arn:aws:cloudformation:us-west-2:123412341234:stack/MyStack/f449b250-b969-11e0-a185-5081d0136786"
+	outputPath, describeErr := DescribeStackToDir(mockClient, stackARN, tempDir)
+	if describeErr != nil {
+		t.Fatalf("Failed to describe stack: %s", describeErr)
+	}
+	if outputPath != filepath.Join(tempDir, "MyStack.json") {
+		t.Fatalf("Unexpected output path: %s", outputPath)
+	}
+	outputBytes, outputErr := ioutil.ReadFile(outputPath)
+	if outputErr != nil {
+		t.Fatal(outputErr)
+	}
+	var response cloudformation.DescribeStacksOutput
+	unmarshalErr := json.Unmarshal(outputBytes, &response)
+	if unmarshalErr != nil {
+		t.Fatalf("Expected a JSON DescribeStacks response: %s", unmarshalErr)
+	}
+	if len(response.Stacks) != 2 {
+		t.Fatalf("Expected every page to be written. Found %d stacks", len(response.Stacks))
+	}
+
+	allStacksPath, allStacksErr := DescribeStackToDir(mockClient, "", tempDir)
+	if allStacksErr != nil {
+		t.Fatalf("Failed to describe all stacks: %s", allStacksErr)
+	}
+	if allStacksPath != filepath.Join(tempDir, AllStacksFileName+".json") {
+		t.Fatalf("Unexpected output path: %s", allStacksPath)
+	}
+
+	_, missingErr := DescribeStackToDir(mockClient, "MissingStack", tempDir)
+	if missingErr == nil {
+		t.Fatalf("Expected an error describing a missing stack")
+	}
+}
+
+func TestDescribeStackOptions(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-library")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mockClient := &mockStackDescriber{
+		pages: [][]string{{"StackOne", "StackTwo"}},
+	}
+	options := DescribeOptions{
+		Format:      FormatYAML,
+		Gzip:        true,
+		SummaryOnly: true,
+		DryRun:      true,
+	}
+	dryRunResult, dryRunErr := DescribeStack(mockClient, "MyStack", tempDir, options)
+	if dryRunErr != nil {
+		t.Fatalf("Failed to describe stack: %s", dryRunErr)
+	}
+	if dryRunResult.OutputPath != filepath.Join(tempDir, "MyStack.yaml.gz") {
+		t.Fatalf("Unexpected output path: %s", dryRunResult.OutputPath)
+	}
+	if _, statErr := os.Stat(dryRunResult.OutputPath); !os.IsNotExist(statErr) {
+		t.Fatalf("Expected DryRun not to write the output file")
+	}
+
+	options.DryRun = false
+	options.Gzip = false
+	result, resultErr := DescribeStack(mockClient, "MyStack", tempDir, options)
+	if resultErr != nil {
+		t.Fatalf("Failed to describe stack: %s", resultErr)
+	}
+	if len(result.Summaries) != 2 || len(result.Response.Stacks) != 2 {
+		t.Fatalf("Expected a summary per stack. Found: %#v", result)
+	}
+	outputBytes, outputErr := ioutil.ReadFile(result.OutputPath)
+	if outputErr != nil {
+		t.Fatal(outputErr)
+	}
+	expected, expectedErr := MarshalOutput(result.Summaries, FormatYAML)
+	if expectedErr != nil {
+		t.Fatal(expectedErr)
+	}
+	if string(outputBytes) != string(expected) {
+		t.Fatalf("Expected the YAML summaries.\nExpected: %s\nFound: %s", expected, outputBytes)
+	}
+}
+
+func TestDescribeStackNoMatchingStacks(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-library")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mockClient := &mockStackDescriber{
+		pages: [][]string{{"MyStack"}},
+	}
+	result, resultErr := DescribeStack(mockClient, "MyStack", tempDir, DescribeOptions{
+		Tags: map[string]string{"Team": "payments"},
+	})
+	if resultErr != nil {
+		t.Fatalf("Failed to describe stack: %s", resultErr)
+	}
+	if result.HasStacks() || result.OutputPath != "" {
+		t.Fatalf("Expected no matching stacks. Found: %#v", result)
+	}
+	entries, entriesErr := ioutil.ReadDir(tempDir)
+	if entriesErr != nil {
+		t.Fatal(entriesErr)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("Expected no output file. Found: %d files", len(entries))
+	}
+}
+
+func TestFilterStacksByStatus(t *testing.T) {
+	response := &cloudformation.DescribeStacksOutput{
+		Stacks: []*cloudformation.Stack{
+			{StackName: aws.String("Healthy"), StackStatus: aws.String("CREATE_COMPLETE")},
+			{StackName: aws.String("Failed"), StackStatus: aws.String("ROLLBACK_COMPLETE")},
+		},
+	}
+	if FilterStacksByStatus(response, nil) != response {
+		t.Fatalf("Expected no statuses to return the response unchanged")
+	}
+	filtered := FilterStacksByStatus(response, []string{"CREATE_COMPLETE"})
+	if len(filtered.Stacks) != 1 || aws.StringValue(filtered.Stacks[0].StackName) != "Healthy" {
+		t.Fatalf("Expected only the CREATE_COMPLETE stack. Found: %v", filtered.Stacks)
+	}
+}
@@ -0,0 +1,92 @@
+package link
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// FormatJSON writes the stack description as JSON
+	FormatJSON = "json"
+	// FormatYAML writes the stack description as YAML
+	FormatYAML = "yaml"
+)
+
+// MarshalOutput serializes v in the given format. YAML output uses the
+// same keys as the JSON output. An empty format is treated as JSON.
+func MarshalOutput(v interface{}, format string) ([]byte, error) {
+	jsonBytes, jsonErr := json.Marshal(v)
+	if jsonErr != nil || format != FormatYAML {
+		return jsonBytes, jsonErr
+	}
+	// Round trip through a generic value so that the YAML keys match the
+	// JSON field names rather than yaml.v2's lowercased defaults
+	var genericValue interface{}
+	unmarshalErr := json.Unmarshal(jsonBytes, &genericValue)
+	if unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	return yaml.Marshal(genericValue)
+}
+
+// OutputFileExtension returns the file extension, without the leading
+// period, for the given format
+func OutputFileExtension(format string) string {
+	if format == FormatYAML {
+		return FormatYAML
+	}
+	return FormatJSON
+}
+
+// OutputFilePath returns the path WriteOutputFile writes for outputPath,
+// which has the .gz extension appended when compress is true
+func OutputFilePath(outputPath string, compress bool) string {
+	if compress {
+		return outputPath + ".gz"
+	}
+	return outputPath
+}
+
+// WriteOutputFile writes data to outputPath. When compress is true the
+// data is gzip compressed and the .gz extension is appended to the
+// path. It returns the path of the file that was written.
+func WriteOutputFile(outputPath string, data []byte, compress bool) (string, error) {
+	return CopyOutputFile(outputPath, bytes.NewReader(data), compress)
+}
+
+// CopyOutputFile copies r to outputPath as WriteOutputFile does. The data
+// is streamed to the file, optionally through the gzip writer, rather
+// than buffered, so it's suitable for large values such as templates.
+func CopyOutputFile(outputPath string, r io.Reader, compress bool) (string, error) {
+	outputPath = OutputFilePath(outputPath, compress)
+	outputFile, outputFileErr := os.OpenFile(outputPath,
+		os.O_CREATE|os.O_WRONLY|os.O_TRUNC,
+		0644)
+	if outputFileErr != nil {
+		return "", outputFileErr
+	}
+	var writer io.Writer = outputFile
+	var gzipWriter *gzip.Writer
+	if compress {
+		gzipWriter = gzip.NewWriter(outputFile)
+		writer = gzipWriter
+	}
+	_, copyErr := io.Copy(writer, r)
+	// Always close both writers so the gzip footer is flushed
+	var gzipCloseErr error
+	if gzipWriter != nil {
+		gzipCloseErr = gzipWriter.Close()
+	}
+	fileCloseErr := outputFile.Close()
+	for _, eachErr := range []error{copyErr, gzipCloseErr, fileCloseErr} {
+		if eachErr != nil {
+			return "", eachErr
+		}
+	}
+	return outputPath, nil
+}
@@ -0,0 +1,88 @@
+package link
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestWriteOutputFileGzip(t *testing.T) {
+	tempDir, tempDirErr := ioutil.TempDir("", "link-output")
+	if tempDirErr != nil {
+		t.Fatal(tempDirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	response := &cloudformation.DescribeStacksOutput{
+		Stacks: []*cloudformation.Stack{{
+			StackName:   aws.String("MyStack"),
+			StackStatus: aws.String("CREATE_COMPLETE"),
+		}},
+	}
+	stackInfo, stackInfoErr := json.Marshal(response)
+	if stackInfoErr != nil {
+		t.Fatal(stackInfoErr)
+	}
+	outputPath, outputErr := WriteOutputFile(filepath.Join(tempDir, "MyStack.json"),
+		stackInfo,
+		true)
+	if outputErr != nil {
+		t.Fatalf("Failed to write output: %s", outputErr)
+	}
+	if filepath.Base(outputPath) != "MyStack.json.gz" {
+		t.Fatalf("Expected .json.gz output file. Found: %s", outputPath)
+	}
+	outputFile, outputFileErr := os.Open(outputPath)
+	if outputFileErr != nil {
+		t.Fatal(outputFileErr)
+	}
+	defer outputFile.Close()
+	gzipReader, gzipReaderErr := gzip.NewReader(outputFile)
+	if gzipReaderErr != nil {
+		t.Fatalf("Failed to open gzip output: %s", gzipReaderErr)
+	}
+	var decompressed cloudformation.DescribeStacksOutput
+	decodeErr := json.NewDecoder(gzipReader).Decode(&decompressed)
+	if decodeErr != nil {
+		t.Fatalf("Failed to decode gzip output: %s", decodeErr)
+	}
+	if len(decompressed.Stacks) != 1 ||
+		aws.StringValue(decompressed.Stacks[0].StackName) != "MyStack" {
+		t.Fatalf("Unexpected decompressed content: %#v", decompressed)
+	}
+}
+
+func TestMarshalOutputFormats(t *testing.T) {
+	response := &cloudformation.DescribeStacksOutput{
+		Stacks: []*cloudformation.Stack{{
+			StackName:   aws.String("MyStack"),
+			StackStatus: aws.String("CREATE_COMPLETE"),
+		}},
+	}
+	for _, eachFormat := range []string{FormatJSON, FormatYAML} {
+		outputBytes, outputErr := MarshalOutput(response, eachFormat)
+		if outputErr != nil {
+			t.Fatalf("Failed to marshal %s output: %s", eachFormat, outputErr)
+		}
+		var parsed map[string]interface{}
+		var parseErr error
+		if eachFormat == FormatYAML {
+			parseErr = yaml.Unmarshal(outputBytes, &parsed)
+		} else {
+			parseErr = json.Unmarshal(outputBytes, &parsed)
+		}
+		if parseErr != nil {
+			t.Fatalf("Failed to parse %s output: %s", eachFormat, parseErr)
+		}
+		if _, exists := parsed["Stacks"]; !exists {
+			t.Fatalf("Expected Stacks key in %s output. Found: %s", eachFormat, string(outputBytes))
+		}
+	}
+}
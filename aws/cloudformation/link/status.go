@@ -0,0 +1,27 @@
+package link
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// FilterStacksByStatus returns a copy of the DescribeStacks response that
+// only includes the stacks whose status is one of the statuses. An empty
+// statuses slice returns the response unchanged.
+func FilterStacksByStatus(response *cloudformation.DescribeStacksOutput,
+	statuses []string) *cloudformation.DescribeStacksOutput {
+	if len(statuses) == 0 {
+		return response
+	}
+	statusSet := make(map[string]bool, len(statuses))
+	for _, eachStatus := range statuses {
+		statusSet[eachStatus] = true
+	}
+	filtered := &cloudformation.DescribeStacksOutput{}
+	for _, eachStack := range response.Stacks {
+		if statusSet[aws.StringValue(eachStack.StackStatus)] {
+			filtered.Stacks = append(filtered.Stacks, eachStack)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,38 @@
+package link
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// StackSummary is the minimal stack representation written when
+// DescribeOptions.SummaryOnly is set
+type StackSummary struct {
+	StackName       string            `json:"StackName"`
+	StackStatus     string            `json:"StackStatus"`
+	CreationTime    *time.Time        `json:"CreationTime,omitempty"`
+	LastUpdatedTime *time.Time        `json:"LastUpdatedTime,omitempty"`
+	Outputs         map[string]string `json:"Outputs"`
+}
+
+// SummarizeStacks projects the DescribeStacks response onto the
+// summary fields
+func SummarizeStacks(describeStacksResponse *cloudformation.DescribeStacksOutput) []*StackSummary {
+	summaries := make([]*StackSummary, 0, len(describeStacksResponse.Stacks))
+	for _, eachStack := range describeStacksResponse.Stacks {
+		summary := &StackSummary{
+			StackName:       aws.StringValue(eachStack.StackName),
+			StackStatus:     aws.StringValue(eachStack.StackStatus),
+			CreationTime:    eachStack.CreationTime,
+			LastUpdatedTime: eachStack.LastUpdatedTime,
+			Outputs:         make(map[string]string),
+		}
+		for _, eachOutput := range eachStack.Outputs {
+			summary.Outputs[aws.StringValue(eachOutput.OutputKey)] = aws.StringValue(eachOutput.OutputValue)
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
@@ -0,0 +1,39 @@
+package link
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func TestSummarizeStacks(t *testing.T) {
+	creationTime := time.Date(2020, 2, 20, 3, 18, 19, 0, time.UTC)
+	response := &cloudformation.DescribeStacksOutput{
+		Stacks: []*cloudformation.Stack{{
+			StackName:    aws.String("MyStack"),
+			StackStatus:  aws.String("UPDATE_COMPLETE"),
+			Description:  aws.String("Not part of the summary"),
+			CreationTime: &creationTime,
+			Outputs: []*cloudformation.Output{{
+				OutputKey:   aws.String("BucketName"),
+				OutputValue: aws.String("my-bucket"),
+			}},
+		}},
+	}
+	summaries := SummarizeStacks(response)
+	if len(summaries) != 1 {
+		t.Fatalf("Expected a single summary. Found: %d", len(summaries))
+	}
+	summary := summaries[0]
+	if summary.StackName != "MyStack" || summary.StackStatus != "UPDATE_COMPLETE" {
+		t.Fatalf("Unexpected summary identity: %#v", summary)
+	}
+	if !summary.CreationTime.Equal(creationTime) || summary.LastUpdatedTime != nil {
+		t.Fatalf("Unexpected summary times: %#v", summary)
+	}
+	if summary.Outputs["BucketName"] != "my-bucket" {
+		t.Fatalf("Expected summary outputs. Found: %#v", summary.Outputs)
+	}
+}
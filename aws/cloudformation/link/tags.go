@@ -0,0 +1,37 @@
+package link
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// stackMatchesTags returns true if the stack defines every one of the tags
+func stackMatchesTags(stack *cloudformation.Stack, tags map[string]string) bool {
+	stackTags := make(map[string]string, len(stack.Tags))
+	for _, eachTag := range stack.Tags {
+		stackTags[aws.StringValue(eachTag.Key)] = aws.StringValue(eachTag.Value)
+	}
+	for eachKey, eachValue := range tags {
+		if stackValue, exists := stackTags[eachKey]; !exists || stackValue != eachValue {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterStacksByTags returns a copy of the DescribeStacks response that
+// only includes the stacks defining every one of the tags. An empty tags
+// map returns the response unchanged.
+func FilterStacksByTags(response *cloudformation.DescribeStacksOutput,
+	tags map[string]string) *cloudformation.DescribeStacksOutput {
+	if len(tags) == 0 {
+		return response
+	}
+	filtered := &cloudformation.DescribeStacksOutput{}
+	for _, eachStack := range response.Stacks {
+		if stackMatchesTags(eachStack, tags) {
+			filtered.Stacks = append(filtered.Stacks, eachStack)
+		}
+	}
+	return filtered
+}
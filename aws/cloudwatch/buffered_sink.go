@@ -0,0 +1,85 @@
+package cloudwatch
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultBufferedSinkThreshold is the number of buffered bytes that
+// triggers a BufferedSink flush if NewBufferedSink isn't given a positive
+// threshold
+const DefaultBufferedSinkThreshold = 64 * 1024
+
+// BufferedSink is an io.Writer that accumulates EMF records and writes
+// them to the underlying writer as newline delimited JSON. Each Write is
+// treated as a single record, which is how PublishToSink writes, and is
+// terminated with a newline if it doesn't already end with one. Records
+// are flushed when the buffered size reaches the threshold or Flush is
+// called. It's safe for concurrent use, so a single BufferedSink can be
+// shared by several EmbeddedMetrics.
+//
+// Buffered records are lost if they aren't flushed, so call Flush before
+// a Lambda function returns.
+type BufferedSink struct {
+	mu        sync.Mutex
+	sink      io.Writer
+	buffer    bytes.Buffer
+	threshold int
+}
+
+// NewBufferedSink returns a BufferedSink that flushes to sink once at
+// least threshold bytes are buffered. A threshold less than one uses
+// DefaultBufferedSinkThreshold.
+func NewBufferedSink(sink io.Writer, threshold int) *BufferedSink {
+	if threshold < 1 {
+		threshold = DefaultBufferedSinkThreshold
+	}
+	return &BufferedSink{
+		sink:      sink,
+		threshold: threshold,
+	}
+}
+
+// Write buffers the record and flushes the buffer if it has reached the
+// threshold
+func (bs *BufferedSink) Write(record []byte) (int, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if len(record) == 0 {
+		return 0, nil
+	}
+	bs.buffer.Write(record)
+	if record[len(record)-1] != '\n' {
+		bs.buffer.WriteByte('\n')
+	}
+	if bs.buffer.Len() >= bs.threshold {
+		flushErr := bs.flush()
+		if flushErr != nil {
+			return len(record), flushErr
+		}
+	}
+	return len(record), nil
+}
+
+// Flush writes every buffered record to the underlying writer
+func (bs *BufferedSink) Flush() error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return bs.flush()
+}
+
+// flush writes the buffer to the sink. Records that couldn't be written
+// remain buffered. Callers must hold the lock.
+func (bs *BufferedSink) flush() error {
+	if bs.buffer.Len() == 0 {
+		return nil
+	}
+	_, writeErr := bs.buffer.WriteTo(bs.sink)
+	if writeErr != nil {
+		return errors.Wrap(writeErr, "Failed to flush buffered metrics")
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package cloudwatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// countingWriter records the number of writes made to it
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.writes++
+	return cw.Buffer.Write(p)
+}
+
+func publishBuffered(t *testing.T, sink *BufferedSink, name string) {
+	emMetric, _ := NewEmbeddedMetric()
+	emMetric.NewMetricDirective("SpecialNamespace", nil).Count(name, 1)
+	publishErr := emMetric.PublishToSink(nil, sink)
+	if publishErr != nil {
+		t.Fatalf("Failed to publish: %s", publishErr)
+	}
+}
+
+func TestBufferedSinkManualFlush(t *testing.T) {
+	var output countingWriter
+	sink := NewBufferedSink(&output, 0)
+	for i := 0; i != 3; i++ {
+		publishBuffered(t, sink, fmt.Sprintf("metric%d", i))
+	}
+	if output.writes != 0 {
+		t.Fatalf("Expected records to be buffered. Found %d writes", output.writes)
+	}
+	flushErr := sink.Flush()
+	if flushErr != nil {
+		t.Fatalf("Failed to flush: %s", flushErr)
+	}
+	if output.writes != 1 {
+		t.Fatalf("Expected a single write. Found: %d", output.writes)
+	}
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 records. Found: %d", len(lines))
+	}
+	for _, eachLine := range lines {
+		var record emf
+		unmarshalErr := json.Unmarshal([]byte(eachLine), &record)
+		if unmarshalErr != nil {
+			t.Fatalf("Expected each line to be an EMF record: %s", unmarshalErr)
+		}
+	}
+}
+
+func TestBufferedSinkThresholdFlush(t *testing.T) {
+	var output countingWriter
+	sink := NewBufferedSink(&output, 1)
+	publishBuffered(t, sink, "first")
+	if output.writes != 1 || !strings.HasSuffix(output.String(), "\n") {
+		t.Fatalf("Expected the threshold to flush a newline terminated record. Found %d writes: %s",
+			output.writes,
+			output.String())
+	}
+	publishBuffered(t, sink, "second")
+	if output.writes != 2 {
+		t.Fatalf("Expected a flush per record. Found: %d", output.writes)
+	}
+}
+
+func TestBufferedSinkConcurrent(t *testing.T) {
+	var output bytes.Buffer
+	sink := NewBufferedSink(&output, 4096)
+	emMetric, _ := NewEmbeddedMetric()
+	emMetric.NewMetricDirective("SpecialNamespace", nil).Count("invocations", 1)
+	var wg sync.WaitGroup
+	for i := 0; i != 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			publishErr := emMetric.PublishToSink(nil, sink)
+			if publishErr != nil {
+				t.Errorf("Failed to publish: %s", publishErr)
+			}
+		}()
+	}
+	wg.Wait()
+	flushErr := sink.Flush()
+	if flushErr != nil {
+		t.Fatalf("Failed to flush: %s", flushErr)
+	}
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if len(lines) != 16 {
+		t.Fatalf("Expected 16 records. Found: %d", len(lines))
+	}
+}
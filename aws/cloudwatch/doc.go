@@ -1,5 +1,8 @@
 // Package cloudwatch scopes CloudWatch-specific utiltities for
 // Sparta
+//
+// NewEMFHandler integrates EMF publishing with log/slog and is only
+// available when building with Go 1.21 or later.
 package cloudwatch
 
 /*
@@ -74,7 +74,7 @@ type emfAWS struct {
 	CloudWatchMetrics []emfAWSCloudWatchMetricsElem `json:"CloudWatchMetrics"`
 
 	// Timestamp corresponds to the JSON schema field "Timestamp".
-	Timestamp int `json:"Timestamp"`
+	Timestamp int64 `json:"Timestamp"`
 }
 
 // UnmarshalJSON implements json.Unmarshaler.
@@ -102,6 +102,9 @@ type emfAWSCloudWatchMetricsElemMetricsElem struct {
 	// Name corresponds to the JSON schema field "Name".
 	Name string `json:"Name"`
 
+	// StorageResolution corresponds to the JSON schema field "StorageResolution".
+	StorageResolution *int `json:"StorageResolution,omitempty"`
+
 	// Unit corresponds to the JSON schema field "Unit".
 	Unit string `json:"Unit"`
 }
@@ -0,0 +1,138 @@
+package cloudwatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// parseMetricValue converts a decoded top level metric value back into
+// a MetricValue Value
+func parseMetricValue(name string, rawValue interface{}) (interface{}, error) {
+	switch typedValue := rawValue.(type) {
+	case float64:
+		return typedValue, nil
+	case []interface{}:
+		values := make([]float64, len(typedValue))
+		for eachIndex, eachValue := range typedValue {
+			floatValue, isFloat := eachValue.(float64)
+			if !isFloat {
+				return nil, errors.Errorf("Metric %s array must only contain numbers. Found: %T",
+					name,
+					eachValue)
+			}
+			values[eachIndex] = floatValue
+		}
+		return values, nil
+	case map[string]interface{}:
+		jsonBytes, jsonBytesErr := json.Marshal(typedValue)
+		if jsonBytesErr != nil {
+			return nil, errors.Wrapf(jsonBytesErr, "Failed to marshal metric %s", name)
+		}
+		var statisticSet StatisticSet
+		unmarshalErr := json.Unmarshal(jsonBytes, &statisticSet)
+		if unmarshalErr != nil {
+			return nil, errors.Wrapf(unmarshalErr, "Metric %s is not a StatisticSet", name)
+		}
+		return statisticSet, nil
+	}
+	return nil, errors.Errorf("Metric %s must be a number, a numeric array or a StatisticSet. Found: %T",
+		name,
+		rawValue)
+}
+
+// ParseEmbeddedMetric reconstructs an EmbeddedMetric from a marshalled EMF
+// record. It's intended for tests and for tools that replay metric logs.
+// Each CloudWatchMetrics element becomes a MetricDirective with the
+// element's explicit DimensionSets, and each metric's Value, Unit,
+// StorageResolution and Counts are restored. Scalar values are restored
+// as float64 and arrays as []float64. Every other top level key,
+// including properties that were scoped to a directive, is restored as an
+// EmbeddedMetric property. The log group, log stream and timestamp are
+// also restored.
+func ParseEmbeddedMetric(raw []byte) (*EmbeddedMetric, error) {
+	topLevel := make(map[string]interface{})
+	unmarshalErr := json.Unmarshal(raw, &topLevel)
+	if unmarshalErr != nil {
+		return nil, errors.Wrap(unmarshalErr, "EMF record must be a JSON object")
+	}
+	var record emf
+	unmarshalErr = json.Unmarshal(raw, &record)
+	if unmarshalErr != nil {
+		return nil, errors.Wrap(unmarshalErr, "Invalid _aws block")
+	}
+	em, _ := NewEmbeddedMetric()
+	em.timestamp = time.Unix(0, record.AWS.Timestamp*int64(time.Millisecond))
+	if logGroupName, isString := topLevel["log_group_name"].(string); isString {
+		em.logGroupName = logGroupName
+	}
+	if logStreamName, isString := topLevel["log_stream_name"].(string); isString {
+		em.logStreamName = logStreamName
+	}
+	reservedKeys := map[string]bool{
+		"_aws":            true,
+		"log_group_name":  true,
+		"log_stream_name": true,
+	}
+	for directiveIndex, eachElem := range record.AWS.CloudWatchMetrics {
+		md := newMetricDirective(eachElem.Namespace, nil)
+		for _, eachDimensionSet := range eachElem.Dimensions {
+			for _, eachKey := range eachDimensionSet {
+				rawDimension, exists := topLevel[eachKey]
+				if !exists {
+					return nil, errors.Errorf("_aws.CloudWatchMetrics[%d] references undefined dimension: %s",
+						directiveIndex,
+						eachKey)
+				}
+				dimensionValue, isString := rawDimension.(string)
+				if !isString {
+					dimensionValue = fmt.Sprint(rawDimension)
+				}
+				md.Dimensions[eachKey] = dimensionValue
+				reservedKeys[eachKey] = true
+			}
+			md.dimensionSets = append(md.dimensionSets,
+				append([]string{}, eachDimensionSet...))
+		}
+		for _, eachMetric := range eachElem.Metrics {
+			rawValue, exists := topLevel[eachMetric.Name]
+			if !exists {
+				return nil, errors.Errorf("_aws.CloudWatchMetrics[%d] references undefined metric: %s",
+					directiveIndex,
+					eachMetric.Name)
+			}
+			metricValue, metricValueErr := parseMetricValue(eachMetric.Name, rawValue)
+			if metricValueErr != nil {
+				return nil, metricValueErr
+			}
+			parsedMetric := MetricValue{
+				Value: metricValue,
+				Unit:  MetricUnit(eachMetric.Unit),
+			}
+			if eachMetric.StorageResolution != nil {
+				parsedMetric.StorageResolution = *eachMetric.StorageResolution
+			}
+			countsKey := metricCountsKey(eachMetric.Name)
+			if rawCounts, exists := topLevel[countsKey]; exists {
+				counts, countsErr := parseMetricValue(countsKey, rawCounts)
+				floatCounts, isArray := counts.([]float64)
+				if countsErr != nil || !isArray {
+					return nil, errors.Errorf("Metric %s Counts must be a numeric array", eachMetric.Name)
+				}
+				parsedMetric.Counts = floatCounts
+				reservedKeys[countsKey] = true
+			}
+			md.Metrics[eachMetric.Name] = parsedMetric
+			reservedKeys[eachMetric.Name] = true
+		}
+		em.metrics = append(em.metrics, md)
+	}
+	for eachKey, eachValue := range topLevel {
+		if !reservedKeys[eachKey] {
+			em.properties[eachKey] = eachValue
+		}
+	}
+	return em, nil
+}
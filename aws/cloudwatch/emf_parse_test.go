@@ -0,0 +1,80 @@
+package cloudwatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseEmbeddedMetric(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	emMetric.WithLogGroup("/aws/lambda/myFunction").
+		WithTimestamp(time.Unix(1600000000, 0)).
+		WithProperty("requestID", "96f98a63")
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace",
+		map[string]string{"Service": "sparta", "Stage": "prod"})
+	metricDirective.AddDimensionSet("Service", "Stage")
+	metricDirective.Count("invocations", 1)
+	metricDirective.Metrics["latency"] = MetricValue{
+		Value:             []float64{10, 20},
+		Counts:            []float64{3, 1},
+		Unit:              UnitMilliseconds,
+		StorageResolution: StorageResolutionHigh,
+	}
+	metricDirective.Metrics["payload"] = MetricValue{
+		Value: StatisticSet{SampleCount: 2, Sum: 30, Minimum: 10, Maximum: 20},
+		Unit:  UnitBytes,
+	}
+	emMetric.NewMetricDirective("OtherNamespace", nil).Count("heartbeat", 0)
+
+	rawJSON, rawJSONErr := json.Marshal(emMetric)
+	if rawJSONErr != nil {
+		t.Fatalf("Failed to marshal metric: %s", rawJSONErr)
+	}
+	parsed, parseErr := ParseEmbeddedMetric(rawJSON)
+	if parseErr != nil {
+		t.Fatalf("Failed to parse metric: %s", parseErr)
+	}
+	originalDirectives := emMetric.Directives()
+	parsedDirectives := parsed.Directives()
+	if len(parsedDirectives) != len(originalDirectives) {
+		t.Fatalf("Expected %d directives. Found: %d", len(originalDirectives), len(parsedDirectives))
+	}
+	for eachIndex, eachOriginal := range originalDirectives {
+		eachParsed := parsedDirectives[eachIndex]
+		if eachParsed.Namespace() != eachOriginal.Namespace() ||
+			!reflect.DeepEqual(eachParsed.Dimensions, eachOriginal.Dimensions) ||
+			!reflect.DeepEqual(eachParsed.Metrics, eachOriginal.Metrics) {
+			t.Fatalf("Expected parsed directive to equal original.\nOriginal: %#v\nParsed: %#v",
+				eachOriginal,
+				eachParsed)
+		}
+	}
+	if parsed.Properties()["requestID"] != "96f98a63" {
+		t.Fatalf("Expected properties to be restored. Found: %v", parsed.Properties())
+	}
+	// Publishing the parsed metric reproduces the original record
+	reparsedJSON, reparsedJSONErr := json.Marshal(parsed)
+	if reparsedJSONErr != nil {
+		t.Fatalf("Failed to marshal parsed metric: %s", reparsedJSONErr)
+	}
+	if string(reparsedJSON) != string(rawJSON) {
+		t.Fatalf("Expected identical records.\nOriginal: %s\nParsed: %s", rawJSON, reparsedJSON)
+	}
+}
+
+func TestParseEmbeddedMetricInvalid(t *testing.T) {
+	testCases := map[string]string{
+		"notAnObject":      `[]`,
+		"missingMetric":    `{"_aws":{"Timestamp":1,"CloudWatchMetrics":[{"Namespace":"ns","Dimensions":[],"Metrics":[{"Name":"count","Unit":"Count"}]}]}}`,
+		"missingDimension": `{"count":1,"_aws":{"Timestamp":1,"CloudWatchMetrics":[{"Namespace":"ns","Dimensions":[["Service"]],"Metrics":[{"Name":"count","Unit":"Count"}]}]}}`,
+		"stringMetric":     `{"count":"1","_aws":{"Timestamp":1,"CloudWatchMetrics":[{"Namespace":"ns","Dimensions":[],"Metrics":[{"Name":"count","Unit":"Count"}]}]}}`,
+	}
+	for eachName, eachRecord := range testCases {
+		_, parseErr := ParseEmbeddedMetric([]byte(eachRecord))
+		if parseErr == nil {
+			t.Fatalf("Expected an error parsing %s", eachName)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package cloudwatch
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// ValidateEMF verifies that raw is a well formed Embedded Metric Format
+// record. The _aws block is decoded into the emf type generated from
+// emf.schema.json, which enforces the schema's required fields; the
+// schema itself isn't evaluated at runtime. Every metric must have a
+// supported Unit and a corresponding top level property, and every
+// dimension referenced in a DimensionSet must exist as a top level key.
+// The returned error identifies the offending field.
+func ValidateEMF(raw []byte) error {
+	topLevel := make(map[string]json.RawMessage)
+	unmarshalErr := json.Unmarshal(raw, &topLevel)
+	if unmarshalErr != nil {
+		return errors.Wrap(unmarshalErr, "EMF record must be a JSON object")
+	}
+	var record emf
+	unmarshalErr = json.Unmarshal(raw, &record)
+	if unmarshalErr != nil {
+		return errors.Wrap(unmarshalErr, "Invalid _aws block")
+	}
+	for directiveIndex, eachDirective := range record.AWS.CloudWatchMetrics {
+		namespaceErr := ValidateNamespace(eachDirective.Namespace)
+		if namespaceErr != nil {
+			return errors.Wrapf(namespaceErr,
+				"_aws.CloudWatchMetrics[%d].Namespace",
+				directiveIndex)
+		}
+		for metricIndex, eachMetric := range eachDirective.Metrics {
+			if !validMetricUnits[MetricUnit(eachMetric.Unit)] {
+				return errors.Errorf("_aws.CloudWatchMetrics[%d].Metrics[%d].Unit: unsupported MetricUnit %s",
+					directiveIndex,
+					metricIndex,
+					eachMetric.Unit)
+			}
+			if _, exists := topLevel[eachMetric.Name]; !exists {
+				return errors.Errorf("_aws.CloudWatchMetrics[%d].Metrics[%d].Name: metric %s has no top level property",
+					directiveIndex,
+					metricIndex,
+					eachMetric.Name)
+			}
+		}
+		for setIndex, eachDimensionSet := range eachDirective.Dimensions {
+			for keyIndex, eachKey := range eachDimensionSet {
+				if _, exists := topLevel[eachKey]; !exists {
+					return errors.Errorf("_aws.CloudWatchMetrics[%d].Dimensions[%d][%d]: dimension %s has no top level property",
+						directiveIndex,
+						setIndex,
+						keyIndex,
+						eachKey)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package cloudwatch
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestValidateEMF(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	emMetric.WithValidation()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace",
+		map[string]string{"Service": "sparta"})
+	metricDirective.Metrics["invocations"] = MetricValue{
+		Unit:  UnitCount,
+		Value: 1,
+	}
+	var output bytes.Buffer
+	publishErr := emMetric.PublishToSink(nil, &output)
+	if publishErr != nil {
+		t.Fatalf("Failed to publish valid metric: %s", publishErr)
+	}
+	validateErr := ValidateEMF(output.Bytes())
+	if validateErr != nil {
+		t.Fatalf("Expected valid EMF record: %s", validateErr)
+	}
+}
+
+func TestValidateEMFEmptyUnit(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	metricDirective.Metrics["invocations"] = MetricValue{Value: 1}
+	rawJSON, rawJSONErr := emMetric.MarshalJSON()
+	if rawJSONErr != nil {
+		t.Fatalf("Failed to marshal metric without a unit: %s", rawJSONErr)
+	}
+	if !strings.Contains(string(rawJSON), `"Unit":"None"`) {
+		t.Fatalf("Expected missing unit to marshal as None: %s", string(rawJSON))
+	}
+	validateErr := ValidateEMF(rawJSON)
+	if validateErr != nil {
+		t.Fatalf("Expected marshalled metric without a unit to validate: %s", validateErr)
+	}
+}
+
+func TestValidateEMFInvalid(t *testing.T) {
+	testCases := map[string]struct {
+		record      string
+		errorSubstr string
+	}{
+		"notAnObject": {
+			record:      `[]`,
+			errorSubstr: "JSON object",
+		},
+		"missingAWS": {
+			record:      `{"invocations": 1}`,
+			errorSubstr: "_aws",
+		},
+		"missingTimestamp": {
+			record:      `{"_aws": {"CloudWatchMetrics": []}}`,
+			errorSubstr: "Timestamp",
+		},
+		"missingMetricProperty": {
+			record: `{"_aws": {"Timestamp": 1455938299356, "CloudWatchMetrics": [
+				{"Namespace": "SpecialNamespace", "Dimensions": [], "Metrics": [{"Name": "invocations", "Unit": "Count"}]}]}}`,
+			errorSubstr: "_aws.CloudWatchMetrics[0].Metrics[0].Name",
+		},
+		"missingDimensionProperty": {
+			record: `{"invocations": 1, "_aws": {"Timestamp": 1455938299356, "CloudWatchMetrics": [
+				{"Namespace": "SpecialNamespace", "Dimensions": [["Service"]], "Metrics": [{"Name": "invocations", "Unit": "Count"}]}]}}`,
+			errorSubstr: "_aws.CloudWatchMetrics[0].Dimensions[0][0]",
+		},
+		"invalidUnit": {
+			record: `{"invocations": 1, "_aws": {"Timestamp": 1455938299356, "CloudWatchMetrics": [
+				{"Namespace": "SpecialNamespace", "Dimensions": [], "Metrics": [{"Name": "invocations", "Unit": "Widgets"}]}]}}`,
+			errorSubstr: "_aws.CloudWatchMetrics[0].Metrics[0].Unit",
+		},
+		"emptyNamespace": {
+			record: `{"invocations": 1, "_aws": {"Timestamp": 1455938299356, "CloudWatchMetrics": [
+				{"Namespace": "", "Dimensions": [], "Metrics": [{"Name": "invocations", "Unit": "Count"}]}]}}`,
+			errorSubstr: "_aws.CloudWatchMetrics[0].Namespace",
+		},
+	}
+	for eachName, eachTestCase := range testCases {
+		validateErr := ValidateEMF([]byte(eachTestCase.record))
+		if validateErr == nil {
+			t.Errorf("%s: expected validation error", eachName)
+			continue
+		}
+		if !strings.Contains(validateErr.Error(), eachTestCase.errorSubstr) {
+			t.Errorf("%s: expected error to reference %s. Found: %s",
+				eachName,
+				eachTestCase.errorSubstr,
+				validateErr)
+		}
+	}
+}
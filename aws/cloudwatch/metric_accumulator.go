@@ -0,0 +1,124 @@
+package cloudwatch
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// accumulatorGroup is the set of metrics that share a namespace and
+// dimensions
+type accumulatorGroup struct {
+	namespace   string
+	dimensions  map[string]string
+	metricNames []string
+	metrics     map[string]MetricValue
+}
+
+// MetricAccumulator collects metrics over the lifetime of a request and
+// publishes them in as few EMF records as possible. Metrics that share
+// a namespace and dimensions are coalesced into a single record. Groups
+// with more than 100 metrics are split across multiple records. A
+// MetricAccumulator is safe for concurrent use.
+type MetricAccumulator struct {
+	mu         sync.Mutex
+	groupOrder []string
+	groups     map[string]*accumulatorGroup
+}
+
+// NewMetricAccumulator returns an empty MetricAccumulator
+func NewMetricAccumulator() *MetricAccumulator {
+	return &MetricAccumulator{
+		groups: make(map[string]*accumulatorGroup),
+	}
+}
+
+// accumulatorGroupKey returns the key that identifies the namespace and
+// dimensions, independent of map ordering
+func accumulatorGroupKey(namespace string, dimensions map[string]string) string {
+	keys := make([]string, 0, len(dimensions))
+	for eachKey := range dimensions {
+		keys = append(keys, eachKey)
+	}
+	sort.Strings(keys)
+	var groupKey strings.Builder
+	groupKey.WriteString(namespace)
+	for _, eachKey := range keys {
+		groupKey.WriteString("\x00")
+		groupKey.WriteString(eachKey)
+		groupKey.WriteString("\x00")
+		groupKey.WriteString(dimensions[eachKey])
+	}
+	return groupKey.String()
+}
+
+// Add records a metric. Adding a metric with the same namespace,
+// dimensions and name as an existing metric replaces the existing value.
+func (ma *MetricAccumulator) Add(namespace string,
+	dims map[string]string,
+	name string,
+	v MetricValue) {
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+
+	groupKey := accumulatorGroupKey(namespace, dims)
+	group, groupExists := ma.groups[groupKey]
+	if !groupExists {
+		dimensions := make(map[string]string)
+		for eachKey, eachValue := range dims {
+			dimensions[eachKey] = eachValue
+		}
+		group = &accumulatorGroup{
+			namespace:  namespace,
+			dimensions: dimensions,
+			metrics:    make(map[string]MetricValue),
+		}
+		ma.groups[groupKey] = group
+		ma.groupOrder = append(ma.groupOrder, groupKey)
+	}
+	if _, metricExists := group.metrics[name]; !metricExists {
+		group.metricNames = append(group.metricNames, name)
+	}
+	group.metrics[name] = v
+}
+
+// Flush publishes every accumulated metric to the sink, one EMF record per
+// line, and resets the accumulator. Records are published in the order
+// their namespace and dimensions were first added.
+func (ma *MetricAccumulator) Flush(sink io.Writer) error {
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+
+	for _, eachGroupKey := range ma.groupOrder {
+		group := ma.groups[eachGroupKey]
+		for start := 0; start < len(group.metricNames); start += maxDirectiveMetrics {
+			end := start + maxDirectiveMetrics
+			if end > len(group.metricNames) {
+				end = len(group.metricNames)
+			}
+			emMetric, emMetricErr := NewEmbeddedMetric()
+			if emMetricErr != nil {
+				return emMetricErr
+			}
+			metricDirective := emMetric.NewMetricDirective(group.namespace, group.dimensions)
+			for _, eachName := range group.metricNames[start:end] {
+				metricDirective.Metrics[eachName] = group.metrics[eachName]
+			}
+			publishErr := emMetric.PublishToSink(nil, sink)
+			if publishErr != nil {
+				return errors.Wrapf(publishErr, "Failed to flush metrics for namespace %s",
+					group.namespace)
+			}
+			_, writeErr := io.WriteString(sink, "\n")
+			if writeErr != nil {
+				return errors.Wrap(writeErr, "Failed to write metric")
+			}
+		}
+	}
+	ma.groupOrder = nil
+	ma.groups = make(map[string]*accumulatorGroup)
+	return nil
+}
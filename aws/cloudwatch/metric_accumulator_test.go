@@ -0,0 +1,100 @@
+package cloudwatch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func accumulatedRecords(t *testing.T, output *bytes.Buffer) []emf {
+	records := []emf{}
+	scanner := bufio.NewScanner(output)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record emf
+		unmarshalErr := json.Unmarshal(scanner.Bytes(), &record)
+		if unmarshalErr != nil {
+			t.Fatalf("Failed to unmarshal record: %s", unmarshalErr)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestMetricAccumulator(t *testing.T) {
+	accumulator := NewMetricAccumulator()
+	for i := 0; i != 3; i++ {
+		accumulator.Add("SpecialNamespace",
+			map[string]string{"Operation": "Get", "Service": "sparta"},
+			fmt.Sprintf("metric%d", i),
+			MetricValue{Unit: UnitCount, Value: i})
+	}
+	// Same dimensions in a different map literal order
+	accumulator.Add("SpecialNamespace",
+		map[string]string{"Service": "sparta", "Operation": "Get"},
+		"metric3",
+		MetricValue{Unit: UnitCount, Value: 3})
+	accumulator.Add("SpecialNamespace",
+		map[string]string{"Operation": "Put", "Service": "sparta"},
+		"metric0",
+		MetricValue{Unit: UnitCount, Value: 0})
+	accumulator.Add("OtherNamespace",
+		nil,
+		"metric0",
+		MetricValue{Unit: UnitCount, Value: 0})
+
+	var output bytes.Buffer
+	flushErr := accumulator.Flush(&output)
+	if flushErr != nil {
+		t.Fatalf("Failed to flush accumulator: %s", flushErr)
+	}
+	records := accumulatedRecords(t, &output)
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records. Found: %d", len(records))
+	}
+	if len(records[0].AWS.CloudWatchMetrics[0].Metrics) != 4 {
+		t.Fatalf("Expected 4 coalesced metrics. Found: %#v", records[0].AWS.CloudWatchMetrics[0].Metrics)
+	}
+
+	// Flush resets the accumulator
+	output.Reset()
+	flushErr = accumulator.Flush(&output)
+	if flushErr != nil {
+		t.Fatalf("Failed to flush empty accumulator: %s", flushErr)
+	}
+	if output.Len() != 0 {
+		t.Fatalf("Expected empty accumulator to write nothing. Found: %s", output.String())
+	}
+}
+
+func TestMetricAccumulatorSplit(t *testing.T) {
+	accumulator := NewMetricAccumulator()
+	metricCount := maxDirectiveMetrics*2 + 1
+	for i := 0; i != metricCount; i++ {
+		accumulator.Add("SpecialNamespace",
+			nil,
+			fmt.Sprintf("metric%d", i),
+			MetricValue{Unit: UnitCount, Value: i})
+	}
+	var output bytes.Buffer
+	flushErr := accumulator.Flush(&output)
+	if flushErr != nil {
+		t.Fatalf("Failed to flush accumulator: %s", flushErr)
+	}
+	records := accumulatedRecords(t, &output)
+	expectedCounts := []int{maxDirectiveMetrics, maxDirectiveMetrics, 1}
+	if len(records) != len(expectedCounts) {
+		t.Fatalf("Expected %d records. Found: %d", len(expectedCounts), len(records))
+	}
+	for eachIndex, eachCount := range expectedCounts {
+		actualCount := len(records[eachIndex].AWS.CloudWatchMetrics[0].Metrics)
+		if actualCount != eachCount {
+			t.Fatalf("Expected record %d to have %d metrics. Found: %d",
+				eachIndex,
+				eachCount,
+				actualCount)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package cloudwatch
+
+import (
+	"github.com/pkg/errors"
+)
+
+// unitScale describes a MetricUnit as a multiple of its family's base unit
+type unitScale struct {
+	family string
+	factor float64
+}
+
+// metricUnitScales are the MetricUnits that can be converted to other
+// units in the same family. Multiples use decimal (SI) prefixes, so a
+// Kilobyte is 1000 Bytes, and a Byte is 8 Bits.
+var metricUnitScales = map[MetricUnit]unitScale{
+	UnitMicroseconds: {"time", 1e-6},
+	UnitMilliseconds: {"time", 1e-3},
+	UnitSeconds:      {"time", 1},
+
+	UnitBits:      {"data", 1.0 / 8},
+	UnitKilobits:  {"data", 1e3 / 8},
+	UnitMegabits:  {"data", 1e6 / 8},
+	UnitGigabits:  {"data", 1e9 / 8},
+	UnitTerabits:  {"data", 1e12 / 8},
+	UnitBytes:     {"data", 1},
+	UnitKilobytes: {"data", 1e3},
+	UnitMegabytes: {"data", 1e6},
+	UnitGigabytes: {"data", 1e9},
+	UnitTerabytes: {"data", 1e12},
+
+	UnitBitsPerSecond:      {"dataRate", 1.0 / 8},
+	UnitKilobitsPerSecond:  {"dataRate", 1e3 / 8},
+	UnitMegabitsPerSecond:  {"dataRate", 1e6 / 8},
+	UnitGigabitsPerSecond:  {"dataRate", 1e9 / 8},
+	UnitTerabitsPerSecond:  {"dataRate", 1e12 / 8},
+	UnitBytesPerSecond:     {"dataRate", 1},
+	UnitKilobytesPerSecond: {"dataRate", 1e3},
+	UnitMegabytesPerSecond: {"dataRate", 1e6},
+	UnitGigabytesPerSecond: {"dataRate", 1e9},
+	UnitTerabytesPerSecond: {"dataRate", 1e12},
+}
+
+// ConvertTo returns a copy of the MetricValue expressed in the target
+// unit. Conversions are supported within the time (Seconds, Milliseconds,
+// Microseconds), data (Bits and Bytes multiples) and data rate families.
+// Converting to the same unit always succeeds. Scalar values and numeric
+// arrays are returned as float64 and []float64 respectively. A
+// StatisticSet's Sum, Minimum and Maximum are converted while its
+// SampleCount and any Counts are unchanged. An error is returned for a
+// conversion between families (eg, Seconds to Bytes).
+func (mv MetricValue) ConvertTo(target MetricUnit) (MetricValue, error) {
+	if !validMetricUnits[target] {
+		return MetricValue{}, errors.Errorf("Unsupported MetricUnit: %s", target)
+	}
+	if mv.Unit == target {
+		return mv, nil
+	}
+	sourceScale, sourceExists := metricUnitScales[mv.Unit]
+	targetScale, targetExists := metricUnitScales[target]
+	if !sourceExists || !targetExists || sourceScale.family != targetScale.family {
+		return MetricValue{}, errors.Errorf("Unable to convert MetricUnit %s to %s",
+			mv.Unit,
+			target)
+	}
+	factor := sourceScale.factor / targetScale.factor
+	converted := mv
+	converted.Unit = target
+	switch typedValue := mv.Value.(type) {
+	case nil:
+	case StatisticSet:
+		converted.Value = scaleStatisticSet(typedValue, factor)
+	case *StatisticSet:
+		if typedValue != nil {
+			scaled := scaleStatisticSet(*typedValue, factor)
+			converted.Value = &scaled
+		}
+	default:
+		if scalarValue, isScalar := numericFloat64(typedValue); isScalar {
+			converted.Value = scalarValue * factor
+			break
+		}
+		arrayValue, isArray := numericSliceFloat64(typedValue)
+		if !isArray {
+			return MetricValue{}, errors.Errorf("Metric Value must be numeric, a numeric array or a StatisticSet. Found: %T",
+				typedValue)
+		}
+		for eachIndex := range arrayValue {
+			arrayValue[eachIndex] *= factor
+		}
+		converted.Value = arrayValue
+	}
+	if mv.Counts != nil {
+		converted.Counts = append([]float64(nil), mv.Counts...)
+	}
+	return converted, nil
+}
+
+// scaleStatisticSet returns the StatisticSet with the observed values
+// multiplied by factor
+func scaleStatisticSet(statisticSet StatisticSet, factor float64) StatisticSet {
+	statisticSet.Sum *= factor
+	statisticSet.Minimum *= factor
+	statisticSet.Maximum *= factor
+	return statisticSet
+}
@@ -0,0 +1,96 @@
+package cloudwatch
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMetricValueConvertTo(t *testing.T) {
+	testCases := []struct {
+		source   MetricValue
+		target   MetricUnit
+		expected interface{}
+	}{
+		{MetricValue{Value: 1500, Unit: UnitMilliseconds}, UnitSeconds, 1.5},
+		{MetricValue{Value: 2.0, Unit: UnitSeconds}, UnitMicroseconds, 2e6},
+		{MetricValue{Value: int64(3e6), Unit: UnitBytes}, UnitMegabytes, 3.0},
+		{MetricValue{Value: 2.0, Unit: UnitKilobytes}, UnitBytes, 2000.0},
+		{MetricValue{Value: 16, Unit: UnitBits}, UnitBytes, 2.0},
+		{MetricValue{Value: 1.0, Unit: UnitMegabytes}, UnitMegabits, 8.0},
+		{MetricValue{Value: 8000, Unit: UnitKilobitsPerSecond}, UnitMegabytesPerSecond, 1.0},
+		{MetricValue{Value: []float64{1000, 2500}, Unit: UnitMilliseconds}, UnitSeconds, []float64{1, 2.5}},
+		{MetricValue{Value: 7, Unit: UnitCount}, UnitCount, 7},
+		{MetricValue{Value: StatisticSet{SampleCount: 2, Sum: 3000, Minimum: 1000, Maximum: 2000},
+			Unit: UnitMilliseconds},
+			UnitSeconds,
+			StatisticSet{SampleCount: 2, Sum: 3, Minimum: 1, Maximum: 2}},
+	}
+	for _, eachTest := range testCases {
+		converted, convertErr := eachTest.source.ConvertTo(eachTest.target)
+		if convertErr != nil {
+			t.Fatalf("Failed to convert %s to %s: %s", eachTest.source.Unit, eachTest.target, convertErr)
+		}
+		if converted.Unit != eachTest.target {
+			t.Fatalf("Expected unit %s. Found: %s", eachTest.target, converted.Unit)
+		}
+		if !approximatelyEqual(converted.Value, eachTest.expected) {
+			t.Fatalf("Expected %s to %s to be %v. Found: %v",
+				eachTest.source.Unit,
+				eachTest.target,
+				eachTest.expected,
+				converted.Value)
+		}
+	}
+}
+
+func TestMetricValueConvertToInvalid(t *testing.T) {
+	testCases := []struct {
+		source MetricValue
+		target MetricUnit
+	}{
+		{MetricValue{Value: 1, Unit: UnitSeconds}, UnitBytes},
+		{MetricValue{Value: 1, Unit: UnitBytes}, UnitBytesPerSecond},
+		{MetricValue{Value: 1, Unit: UnitCount}, UnitPercent},
+		{MetricValue{Value: 1, Unit: UnitSeconds}, MetricUnit("Fortnights")},
+		{MetricValue{Value: "1", Unit: UnitSeconds}, UnitMilliseconds},
+	}
+	for _, eachTest := range testCases {
+		_, convertErr := eachTest.source.ConvertTo(eachTest.target)
+		if convertErr == nil {
+			t.Fatalf("Expected an error converting %s to %s", eachTest.source.Unit, eachTest.target)
+		}
+	}
+}
+
+// approximatelyEqual compares the converted values with a tolerance for
+// floating point rounding
+func approximatelyEqual(actual interface{}, expected interface{}) bool {
+	closeEnough := func(lhs float64, rhs float64) bool {
+		return math.Abs(lhs-rhs) <= 1e-9*math.Max(1, math.Abs(rhs))
+	}
+	switch typedExpected := expected.(type) {
+	case float64:
+		typedActual, isFloat := actual.(float64)
+		return isFloat && closeEnough(typedActual, typedExpected)
+	case []float64:
+		typedActual, isSlice := actual.([]float64)
+		if !isSlice || len(typedActual) != len(typedExpected) {
+			return false
+		}
+		for eachIndex := range typedExpected {
+			if !closeEnough(typedActual[eachIndex], typedExpected[eachIndex]) {
+				return false
+			}
+		}
+		return true
+	case StatisticSet:
+		typedActual, isSet := actual.(StatisticSet)
+		return isSet &&
+			typedActual.SampleCount == typedExpected.SampleCount &&
+			closeEnough(typedActual.Sum, typedExpected.Sum) &&
+			closeEnough(typedActual.Minimum, typedExpected.Minimum) &&
+			closeEnough(typedActual.Maximum, typedExpected.Maximum)
+	}
+	return reflect.DeepEqual(actual, expected)
+}
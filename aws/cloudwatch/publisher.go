@@ -0,0 +1,297 @@
+package cloudwatch
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsCloudWatch "github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxPutMetricDataDatums is the maximum number of MetricDatum values
+	// in a single PutMetricData request
+	maxPutMetricDataDatums = 20
+	// maxPutMetricDataValues is the maximum number of values, summed
+	// across every MetricDatum, in a single PutMetricData request
+	maxPutMetricDataValues = 1000
+	// maxMetricDatumValues is the maximum number of Values in a single
+	// MetricDatum
+	maxMetricDatumValues = 150
+)
+
+// Publisher publishes the metrics defined by an EmbeddedMetric. The
+// context bounds any network calls made while publishing.
+type Publisher interface {
+	Publish(ctx context.Context,
+		em *EmbeddedMetric,
+		additionalProperties map[string]interface{}) error
+}
+
+// EMFPublisher publishes EmbeddedMetrics as EMF records to a log sink
+type EMFPublisher struct {
+	sink   io.Writer
+	indent bool
+}
+
+// WithIndent is a fluent builder that controls whether records are written
+// as indented, multi-line JSON to make them easier to read while debugging
+// locally. Enabling it formats every record as though the EmbeddedMetric
+// had been built with WithPrettyOutput. Disabling it doesn't override a
+// metric's own WithPrettyOutput setting.
+//
+// WARNING: This MUST NOT be enabled in Lambda or anywhere else the output
+// is consumed as EMF. The CloudWatch agent requires every record to be a
+// single line and silently drops metrics from multi-line records.
+func (publisher *EMFPublisher) WithIndent(indent bool) *EMFPublisher {
+	publisher.indent = indent
+	return publisher
+}
+
+// Publish writes the EmbeddedMetric to the sink as a single EMF record
+func (publisher *EMFPublisher) Publish(ctx context.Context,
+	em *EmbeddedMetric,
+	additionalProperties map[string]interface{}) error {
+	return em.publishToSink(additionalProperties,
+		publisher.sink,
+		publisher.indent)
+}
+
+// NewEMFPublisher returns a Publisher that writes each EmbeddedMetric as an
+// EMF record to sink. This is the behavior of EmbeddedMetric.PublishToSink
+// and the right choice for Lambda functions.
+func NewEMFPublisher(sink io.Writer) *EMFPublisher {
+	return &EMFPublisher{
+		sink: sink,
+	}
+}
+
+// putMetricDataPublisher publishes EmbeddedMetrics with the CloudWatch
+// PutMetricData API
+type putMetricDataPublisher struct {
+	svc cloudwatchiface.CloudWatchAPI
+}
+
+// Publish sends the metrics with PutMetricDataWithContext. If ctx is
+// canceled or its deadline passes, the remaining requests are abandoned
+// and ctx.Err() is returned unwrapped so that callers can test for
+// context.DeadlineExceeded.
+func (publisher *putMetricDataPublisher) Publish(ctx context.Context,
+	em *EmbeddedMetric,
+	additionalProperties map[string]interface{}) error {
+	if em == nil {
+		return ErrNilEmbeddedMetric
+	}
+	requests, requestsErr := em.putMetricDataInputs()
+	if requestsErr != nil {
+		return requestsErr
+	}
+	for _, eachRequest := range requests {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		_, putErr := publisher.svc.PutMetricDataWithContext(ctx, eachRequest)
+		if putErr != nil {
+			// The SDK reports cancellation as a RequestCanceled error
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return errors.Wrapf(putErr, "Failed to put metric data for namespace %s",
+				aws.StringValue(eachRequest.Namespace))
+		}
+	}
+	return nil
+}
+
+// NewPutMetricDataPublisher returns a Publisher that sends each
+// EmbeddedMetric directly to CloudWatch via PutMetricData. Use it where
+// log based metrics aren't available or desirable. Properties aren't
+// searchable metric data, so the EmbeddedMetric properties and any
+// additionalProperties are not published.
+func NewPutMetricDataPublisher(svc cloudwatchiface.CloudWatchAPI) Publisher {
+	return &putMetricDataPublisher{
+		svc: svc,
+	}
+}
+
+// numericSliceFloat64 returns the value as a []float64 if it's a slice or
+// array of numbers
+func numericSliceFloat64(value interface{}) ([]float64, bool) {
+	reflectValue := reflect.ValueOf(value)
+	switch reflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		return nil, false
+	}
+	values := make([]float64, reflectValue.Len())
+	for i := 0; i != reflectValue.Len(); i++ {
+		eachValue, isNumeric := numericFloat64(reflectValue.Index(i).Interface())
+		if !isNumeric {
+			return nil, false
+		}
+		values[i] = eachValue
+	}
+	return values, true
+}
+
+// metricData returns the MetricDatum values for the metric value. Arrays
+// with more than maxMetricDatumValues values are split across several
+// MetricDatum values, each with the matching Counts. An empty array
+// produces no MetricDatum.
+func metricData(name string,
+	metric MetricValue,
+	dimensions []*awsCloudWatch.Dimension,
+	timestamp time.Time) ([]*awsCloudWatch.MetricDatum, error) {
+	newDatum := func() *awsCloudWatch.MetricDatum {
+		datum := &awsCloudWatch.MetricDatum{
+			MetricName: aws.String(name),
+			Dimensions: dimensions,
+			Timestamp:  aws.Time(timestamp),
+			Unit:       aws.String(string(metric.Unit)),
+		}
+		if metric.StorageResolution != 0 {
+			datum.StorageResolution = aws.Int64(int64(metric.StorageResolution))
+		}
+		return datum
+	}
+	switch typedValue := metric.Value.(type) {
+	case StatisticSet:
+		datum := newDatum()
+		datum.StatisticValues = statisticValues(typedValue)
+		return []*awsCloudWatch.MetricDatum{datum}, nil
+	case *StatisticSet:
+		datum := newDatum()
+		datum.StatisticValues = statisticValues(*typedValue)
+		return []*awsCloudWatch.MetricDatum{datum}, nil
+	}
+	if scalarValue, isScalar := numericFloat64(metric.Value); isScalar {
+		datum := newDatum()
+		datum.Value = aws.Float64(scalarValue)
+		return []*awsCloudWatch.MetricDatum{datum}, nil
+	}
+	arrayValue, isArray := numericSliceFloat64(metric.Value)
+	if !isArray {
+		return nil, errors.Errorf("Metric %s has an unsupported Value: %T", name, metric.Value)
+	}
+	var data []*awsCloudWatch.MetricDatum
+	for start := 0; start < len(arrayValue); start += maxMetricDatumValues {
+		end := start + maxMetricDatumValues
+		if end > len(arrayValue) {
+			end = len(arrayValue)
+		}
+		datum := newDatum()
+		for eachIndex := start; eachIndex != end; eachIndex++ {
+			datum.Values = append(datum.Values, aws.Float64(arrayValue[eachIndex]))
+		}
+		if len(metric.Counts) == len(arrayValue) {
+			for eachIndex := start; eachIndex != end; eachIndex++ {
+				datum.Counts = append(datum.Counts, aws.Float64(metric.Counts[eachIndex]))
+			}
+		}
+		data = append(data, datum)
+	}
+	return data, nil
+}
+
+// metricDatumValueCount returns the number of values the MetricDatum
+// counts against the PutMetricData limit
+func metricDatumValueCount(datum *awsCloudWatch.MetricDatum) int {
+	if len(datum.Values) != 0 {
+		return len(datum.Values)
+	}
+	return 1
+}
+
+// statisticValues returns the PutMetricData representation of the
+// StatisticSet
+func statisticValues(statisticSet StatisticSet) *awsCloudWatch.StatisticSet {
+	return &awsCloudWatch.StatisticSet{
+		SampleCount: aws.Float64(statisticSet.SampleCount),
+		Sum:         aws.Float64(statisticSet.Sum),
+		Minimum:     aws.Float64(statisticSet.Minimum),
+		Maximum:     aws.Float64(statisticSet.Maximum),
+	}
+}
+
+// putMetricDataInputs converts the directives into PutMetricData requests.
+// Each metric produces one MetricDatum per DimensionSet, or a single
+// MetricDatum if the directive has no dimensions. Arrays are split into
+// MetricDatum values of at most maxMetricDatumValues values. Requests only
+// include a single namespace and are split so that each one satisfies the
+// PutMetricData datum and value limits.
+func (em *EmbeddedMetric) putMetricDataInputs() ([]*awsCloudWatch.PutMetricDataInput, error) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	if em.disabled {
+		return nil, nil
+	}
+	preconditionErr := em.validatePreconditions()
+	if preconditionErr != nil {
+		return nil, preconditionErr
+	}
+	timestamp := em.timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	var requests []*awsCloudWatch.PutMetricDataInput
+	for _, eachDirective := range em.metrics {
+		dimensions := em.directiveDimensions(eachDirective)
+		dimensionSets := em.directiveDimensionSets(eachDirective)
+		if len(dimensionSets) == 0 {
+			dimensionSets = [][]string{{}}
+		}
+		metricNames := make([]string, 0, len(eachDirective.Metrics))
+		for eachName := range eachDirective.Metrics {
+			metricNames = append(metricNames, eachName)
+		}
+		sort.Strings(metricNames)
+
+		// Requests are tracked by namespace since metrics may override
+		// the directive namespace
+		namespaceRequests := make(map[string]*awsCloudWatch.PutMetricDataInput)
+		namespaceValueCounts := make(map[string]int)
+		for _, eachName := range metricNames {
+			eachMetric := eachDirective.Metrics[eachName]
+			namespace := eachDirective.metricNamespace(eachMetric)
+			for _, eachDimensionSet := range dimensionSets {
+				datumDimensions := make([]*awsCloudWatch.Dimension, 0, len(eachDimensionSet))
+				for _, eachKey := range eachDimensionSet {
+					datumDimensions = append(datumDimensions, &awsCloudWatch.Dimension{
+						Name:  aws.String(eachKey),
+						Value: aws.String(dimensions[eachKey]),
+					})
+				}
+				data, dataErr := metricData(eachName,
+					eachMetric,
+					datumDimensions,
+					timestamp)
+				if dataErr != nil {
+					return nil, dataErr
+				}
+				for _, eachDatum := range data {
+					valueCount := metricDatumValueCount(eachDatum)
+					request := namespaceRequests[namespace]
+					if request == nil ||
+						len(request.MetricData) == maxPutMetricDataDatums ||
+						namespaceValueCounts[namespace]+valueCount > maxPutMetricDataValues {
+						request = &awsCloudWatch.PutMetricDataInput{
+							Namespace: aws.String(namespace),
+						}
+						requests = append(requests, request)
+						namespaceRequests[namespace] = request
+						namespaceValueCounts[namespace] = 0
+					}
+					request.MetricData = append(request.MetricData, eachDatum)
+					namespaceValueCounts[namespace] += valueCount
+				}
+			}
+		}
+	}
+	return requests, nil
+}
@@ -0,0 +1,277 @@
+package cloudwatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awsCloudWatch "github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+type mockCloudWatchClient struct {
+	cloudwatchiface.CloudWatchAPI
+	requests []*awsCloudWatch.PutMetricDataInput
+	onPut    func(ctx aws.Context) error
+}
+
+func (mock *mockCloudWatchClient) PutMetricDataWithContext(ctx aws.Context,
+	input *awsCloudWatch.PutMetricDataInput,
+	opts ...request.Option) (*awsCloudWatch.PutMetricDataOutput, error) {
+	mock.requests = append(mock.requests, input)
+	if mock.onPut != nil {
+		return nil, mock.onPut(ctx)
+	}
+	return &awsCloudWatch.PutMetricDataOutput{}, nil
+}
+
+func TestEMFPublisher(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	emMetric.NewMetricDirective("SpecialNamespace", nil).Count("invocations", 1)
+	var sink bytes.Buffer
+	publishErr := NewEMFPublisher(&sink).Publish(context.Background(), emMetric, nil)
+	if publishErr != nil {
+		t.Fatalf("Failed to publish: %s", publishErr)
+	}
+	var record emf
+	unmarshalErr := json.Unmarshal(sink.Bytes(), &record)
+	if unmarshalErr != nil {
+		t.Fatalf("Expected an EMF record: %s", unmarshalErr)
+	}
+}
+
+func TestPutMetricDataPublisherDatumBatches(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	metricDirective.Dimensions["Service"] = "sparta"
+	metricCount := 2*maxPutMetricDataDatums + 5
+	for i := 0; i != metricCount; i++ {
+		metricDirective.Count(fmt.Sprintf("metric%02d", i), float64(i))
+	}
+	mockClient := &mockCloudWatchClient{}
+	publishErr := NewPutMetricDataPublisher(mockClient).Publish(context.Background(), emMetric, nil)
+	if publishErr != nil {
+		t.Fatalf("Failed to publish: %s", publishErr)
+	}
+	expectedCounts := []int{maxPutMetricDataDatums, maxPutMetricDataDatums, 5}
+	if len(mockClient.requests) != len(expectedCounts) {
+		t.Fatalf("Expected %d requests. Found: %d", len(expectedCounts), len(mockClient.requests))
+	}
+	for eachIndex, eachCount := range expectedCounts {
+		eachRequest := mockClient.requests[eachIndex]
+		if len(eachRequest.MetricData) != eachCount {
+			t.Fatalf("Expected request %d to have %d datums. Found: %d",
+				eachIndex,
+				eachCount,
+				len(eachRequest.MetricData))
+		}
+		if aws.StringValue(eachRequest.Namespace) != "SpecialNamespace" {
+			t.Fatalf("Unexpected namespace: %s", aws.StringValue(eachRequest.Namespace))
+		}
+	}
+	firstDatum := mockClient.requests[0].MetricData[0]
+	if aws.StringValue(firstDatum.MetricName) != "metric00" ||
+		aws.StringValue(firstDatum.Unit) != string(UnitCount) ||
+		len(firstDatum.Dimensions) != 1 ||
+		aws.StringValue(firstDatum.Dimensions[0].Value) != "sparta" {
+		t.Fatalf("Unexpected datum: %#v", firstDatum)
+	}
+}
+
+func TestPutMetricDataPublisherValueBatches(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	values := make([]float64, 400)
+	for i := range values {
+		values[i] = float64(i)
+	}
+	for _, eachName := range []string{"first", "second", "third"} {
+		metricDirective.Metrics[eachName] = MetricValue{
+			Value: values,
+			Unit:  UnitMilliseconds,
+		}
+	}
+	mockClient := &mockCloudWatchClient{}
+	publishErr := NewPutMetricDataPublisher(mockClient).Publish(context.Background(), emMetric, nil)
+	if publishErr != nil {
+		t.Fatalf("Failed to publish: %s", publishErr)
+	}
+	// Each 400 value metric is split into datums of 150, 150 and 100
+	// values, and the 1200 values are split so that no request exceeds
+	// 1000
+	if len(mockClient.requests) != 2 ||
+		len(mockClient.requests[0].MetricData) != 7 ||
+		len(mockClient.requests[1].MetricData) != 2 {
+		t.Fatalf("Unexpected value batching: %d requests", len(mockClient.requests))
+	}
+	var firstValues []float64
+	for _, eachRequest := range mockClient.requests {
+		requestValueCount := 0
+		for _, eachDatum := range eachRequest.MetricData {
+			if len(eachDatum.Values) > maxMetricDatumValues {
+				t.Fatalf("Expected at most %d values per datum. Found: %d",
+					maxMetricDatumValues,
+					len(eachDatum.Values))
+			}
+			requestValueCount += len(eachDatum.Values)
+			if aws.StringValue(eachDatum.MetricName) == "first" {
+				firstValues = append(firstValues, aws.Float64ValueSlice(eachDatum.Values)...)
+			}
+		}
+		if requestValueCount > maxPutMetricDataValues {
+			t.Fatalf("Expected at most %d values per request. Found: %d",
+				maxPutMetricDataValues,
+				requestValueCount)
+		}
+	}
+	if !reflect.DeepEqual(firstValues, values) {
+		t.Fatalf("Expected the split datums to preserve every value in order")
+	}
+}
+
+func TestPutMetricDataPublisherSplitCounts(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	values := make([]float64, maxMetricDatumValues+1)
+	counts := make([]float64, len(values))
+	for i := range values {
+		values[i] = float64(i)
+		counts[i] = float64(i + 1)
+	}
+	emMetric.NewMetricDirective("SpecialNamespace", nil).Metrics["latency"] = MetricValue{
+		Value:  values,
+		Counts: counts,
+		Unit:   UnitMilliseconds,
+	}
+	mockClient := &mockCloudWatchClient{}
+	publishErr := NewPutMetricDataPublisher(mockClient).Publish(context.Background(), emMetric, nil)
+	if publishErr != nil {
+		t.Fatalf("Failed to publish: %s", publishErr)
+	}
+	if len(mockClient.requests) != 1 || len(mockClient.requests[0].MetricData) != 2 {
+		t.Fatalf("Expected the metric to be split into two datums")
+	}
+	lastDatum := mockClient.requests[0].MetricData[1]
+	if len(lastDatum.Values) != 1 ||
+		len(lastDatum.Counts) != 1 ||
+		aws.Float64Value(lastDatum.Values[0]) != values[maxMetricDatumValues] ||
+		aws.Float64Value(lastDatum.Counts[0]) != counts[maxMetricDatumValues] {
+		t.Fatalf("Expected the Counts to be split with the Values. Found: %#v", lastDatum)
+	}
+}
+
+func TestPutMetricDataPublisherNilMetric(t *testing.T) {
+	mockClient := &mockCloudWatchClient{}
+	publishErr := NewPutMetricDataPublisher(mockClient).Publish(context.Background(), nil, nil)
+	if publishErr != ErrNilEmbeddedMetric {
+		t.Fatalf("Expected ErrNilEmbeddedMetric. Found: %v", publishErr)
+	}
+	if len(mockClient.requests) != 0 {
+		t.Fatalf("Expected no PutMetricData requests. Found: %d", len(mockClient.requests))
+	}
+}
+
+func TestPutMetricDataPublisherCanceled(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	for i := 0; i != 2*maxPutMetricDataDatums; i++ {
+		metricDirective.Count(fmt.Sprintf("metric%02d", i), float64(i))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockClient := &mockCloudWatchClient{
+		// Cancel while the first request is in flight
+		onPut: func(ctx aws.Context) error {
+			cancel()
+			return awserr.New("RequestCanceled", "request context canceled", ctx.Err())
+		},
+	}
+	publishErr := NewPutMetricDataPublisher(mockClient).Publish(ctx, emMetric, nil)
+	if publishErr != context.Canceled {
+		t.Fatalf("Expected context.Canceled. Found: %v", publishErr)
+	}
+	if len(mockClient.requests) != 1 {
+		t.Fatalf("Expected remaining requests to be abandoned. Found %d requests",
+			len(mockClient.requests))
+	}
+}
+
+func TestEMFPublisherIndent(t *testing.T) {
+	for _, indent := range []bool{false, true} {
+		emMetric, _ := NewEmbeddedMetric()
+		emMetric.NewMetricDirective("SpecialNamespace", nil).Count("invocations", 1)
+		var sink bytes.Buffer
+		publishErr := NewEMFPublisher(&sink).WithIndent(indent).Publish(context.Background(), emMetric, nil)
+		if publishErr != nil {
+			t.Fatalf("Failed to publish: %s", publishErr)
+		}
+		if bytes.Contains(sink.Bytes(), []byte("\n")) != indent {
+			t.Fatalf("Expected newlines only when indented (indent: %t). Found: %s",
+				indent,
+				sink.String())
+		}
+		var record emf
+		unmarshalErr := json.Unmarshal(sink.Bytes(), &record)
+		if unmarshalErr != nil {
+			t.Fatalf("Expected an EMF record (indent: %t): %s", indent, unmarshalErr)
+		}
+	}
+}
+
+func TestEMFPublisherIndentPrettyOutput(t *testing.T) {
+	// The publisher shares the metric's pretty output formatting, so a
+	// metric built WithPrettyOutput is indented exactly once either way
+	var prettyRecord bytes.Buffer
+	emMetric, _ := NewEmbeddedMetric()
+	emMetric.WithTimestamp(time.Unix(1600000000, 0)).WithPrettyOutput()
+	emMetric.NewMetricDirective("SpecialNamespace", nil).Count("invocations", 1)
+	publishErr := emMetric.PublishToSink(nil, &prettyRecord)
+	if publishErr != nil {
+		t.Fatalf("Failed to publish: %s", publishErr)
+	}
+	for _, indent := range []bool{false, true} {
+		var sink bytes.Buffer
+		publishErr = NewEMFPublisher(&sink).WithIndent(indent).Publish(context.Background(), emMetric, nil)
+		if publishErr != nil {
+			t.Fatalf("Failed to publish: %s", publishErr)
+		}
+		if sink.String() != prettyRecord.String() {
+			t.Fatalf("Expected the metric's pretty output (indent: %t). Found: %s",
+				indent,
+				sink.String())
+		}
+	}
+}
+
+func TestPutMetricDataPublisherNamespaceOverride(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	metricDirective.Count("invocations", 1)
+	metricDirective.Metrics["billedDuration"] = MetricValue{
+		Value:     100,
+		Unit:      UnitMilliseconds,
+		Namespace: "BillingNamespace",
+	}
+	mockClient := &mockCloudWatchClient{}
+	publishErr := NewPutMetricDataPublisher(mockClient).Publish(context.Background(), emMetric, nil)
+	if publishErr != nil {
+		t.Fatalf("Failed to publish: %s", publishErr)
+	}
+	namespaces := make(map[string]string)
+	for _, eachRequest := range mockClient.requests {
+		for _, eachDatum := range eachRequest.MetricData {
+			namespaces[aws.StringValue(eachDatum.MetricName)] = aws.StringValue(eachRequest.Namespace)
+		}
+	}
+	if len(mockClient.requests) != 2 ||
+		namespaces["invocations"] != "SpecialNamespace" ||
+		namespaces["billedDuration"] != "BillingNamespace" {
+		t.Fatalf("Expected a request per namespace. Found: %v", namespaces)
+	}
+}
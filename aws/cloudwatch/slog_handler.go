@@ -0,0 +1,227 @@
+//go:build go1.21
+// +build go1.21
+
+package cloudwatch
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// EMFMetricGroupKey is the slog group key that identifies the attributes
+	// that NewEMFHandler publishes as EMF metrics
+	EMFMetricGroupKey = "metric"
+	// EMFNamespaceKey is the attribute key in the EMFMetricGroupKey group
+	// that defines the metric namespace
+	EMFNamespaceKey = "namespace"
+	// EMFDimensionsGroupKey is the group key in the EMFMetricGroupKey
+	// group whose string attributes are the metric dimensions
+	EMFDimensionsGroupKey = "dimensions"
+	// EMFValueKey is the attribute key of a metric value when the metric
+	// is expressed as a group
+	EMFValueKey = "value"
+	// EMFUnitKey is the attribute key of a metric unit when the metric
+	// is expressed as a group
+	EMFUnitKey = "unit"
+)
+
+// emfHandler is the slog.Handler that publishes metric groups as
+// EmbeddedMetric records
+type emfHandler struct {
+	// mu serializes the EMF record writes of the handler and every
+	// handler derived from it by WithAttrs and WithGroup
+	mu     *sync.Mutex
+	writer io.Writer
+	next   slog.Handler
+}
+
+// EMFHandlerOption configures the handler returned by NewEMFHandler
+type EMFHandlerOption func(*emfHandler)
+
+// WithEMFWriter is an EMFHandlerOption that writes the EMF records to w
+// rather than os.Stdout. Each record is a single newline terminated
+// line, so w can be the writer that the next handler logs to.
+func WithEMFWriter(w io.Writer) EMFHandlerOption {
+	return func(eh *emfHandler) {
+		if w != nil {
+			eh.writer = w
+		}
+	}
+}
+
+// NewEMFHandler returns a slog.Handler that writes the EMFMetricGroupKey
+// group of each record as an EmbeddedMetric and passes the remaining
+// attributes to next. EMF records are written to os.Stdout, where the
+// Lambda runtime forwards them to CloudWatch Logs, unless WithEMFWriter
+// supplies another writer. The metric group uses the following
+// convention:
+//
+//	logger.InfoContext(ctx, "request complete",
+//		slog.Group("metric",
+//			slog.String("namespace", "MyService"),
+//			slog.Group("dimensions", slog.String("Operation", "Get")),
+//			slog.Int("invocations", 1),
+//			slog.Group("latency",
+//				slog.Float64("value", 12.5),
+//				slog.String("unit", "Milliseconds"))))
+//
+// Numeric attributes are published with UnitNone and time.Duration
+// attributes as fractional UnitMilliseconds. Group attributes define
+// both the value and the MetricUnit. Records without a metric group are
+// passed through unchanged. The record is passed to next even if the
+// metric group can't be published, in which case Handle returns the
+// publishing error.
+func NewEMFHandler(next slog.Handler, options ...EMFHandlerOption) slog.Handler {
+	eh := &emfHandler{
+		mu:     &sync.Mutex{},
+		writer: os.Stdout,
+		next:   next,
+	}
+	for _, eachOption := range options {
+		eachOption(eh)
+	}
+	return eh
+}
+
+// Enabled defers to the next handler
+func (eh *emfHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return eh.next.Enabled(ctx, level)
+}
+
+// Handle publishes the metric group and passes everything else through
+func (eh *emfHandler) Handle(ctx context.Context, record slog.Record) error {
+	var metricAttrs []slog.Attr
+	passthrough := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	record.Attrs(func(eachAttr slog.Attr) bool {
+		if eachAttr.Key == EMFMetricGroupKey &&
+			eachAttr.Value.Kind() == slog.KindGroup {
+			metricAttrs = append(metricAttrs, eachAttr.Value.Group()...)
+		} else {
+			passthrough.AddAttrs(eachAttr)
+		}
+		return true
+	})
+	if metricAttrs == nil {
+		return eh.next.Handle(ctx, record)
+	}
+	publishErr := eh.publish(metricAttrs)
+	handleErr := eh.next.Handle(ctx, passthrough)
+	if publishErr != nil {
+		return errors.Wrap(publishErr, "Failed to publish slog metric group")
+	}
+	return handleErr
+}
+
+// publish writes the EmbeddedMetric for the metric group attributes to the
+// handler's writer as a single newline terminated line
+func (eh *emfHandler) publish(metricAttrs []slog.Attr) error {
+	emMetric, emMetricErr := embeddedMetricFromAttrs(metricAttrs)
+	if emMetricErr != nil {
+		return emMetricErr
+	}
+	var record bytes.Buffer
+	publishErr := emMetric.PublishToSink(nil, &record)
+	if publishErr != nil {
+		return publishErr
+	}
+	record.WriteByte('\n')
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	_, writeErr := eh.writer.Write(record.Bytes())
+	return writeErr
+}
+
+// WithAttrs returns an EMF handler that wraps the next handler's WithAttrs
+func (eh *emfHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &emfHandler{
+		mu:     eh.mu,
+		writer: eh.writer,
+		next:   eh.next.WithAttrs(attrs),
+	}
+}
+
+// WithGroup returns an EMF handler that wraps the next handler's WithGroup
+func (eh *emfHandler) WithGroup(name string) slog.Handler {
+	return &emfHandler{
+		mu:     eh.mu,
+		writer: eh.writer,
+		next:   eh.next.WithGroup(name),
+	}
+}
+
+// embeddedMetricFromAttrs returns the EmbeddedMetric for the attributes
+// of the metric group
+func embeddedMetricFromAttrs(attrs []slog.Attr) (*EmbeddedMetric, error) {
+	namespace := ""
+	dimensions := make(map[string]string)
+	metrics := make(map[string]MetricValue)
+	for _, eachAttr := range attrs {
+		switch eachAttr.Key {
+		case EMFNamespaceKey:
+			namespace = eachAttr.Value.String()
+		case EMFDimensionsGroupKey:
+			for _, eachDimension := range eachAttr.Value.Group() {
+				dimensions[eachDimension.Key] = eachDimension.Value.String()
+			}
+		default:
+			metricValue, metricValueErr := metricValueFromSlog(eachAttr)
+			if metricValueErr != nil {
+				return nil, metricValueErr
+			}
+			metrics[eachAttr.Key] = metricValue
+		}
+	}
+	emMetric, emMetricErr := NewEmbeddedMetric()
+	if emMetricErr != nil {
+		return nil, emMetricErr
+	}
+	metricDirective := emMetric.NewMetricDirective(namespace, dimensions)
+	for eachName, eachValue := range metrics {
+		metricDirective.Metrics[eachName] = eachValue
+	}
+	return emMetric, nil
+}
+
+// metricValueFromSlog returns the MetricValue for a single metric attribute
+func metricValueFromSlog(attr slog.Attr) (MetricValue, error) {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindInt64:
+		return NewMetricValue(value.Int64(), UnitNone)
+	case slog.KindUint64:
+		return NewMetricValue(value.Uint64(), UnitNone)
+	case slog.KindFloat64:
+		return NewMetricValue(value.Float64(), UnitNone)
+	case slog.KindDuration:
+		return NewMetricValue(float64(value.Duration())/float64(time.Millisecond),
+			UnitMilliseconds)
+	case slog.KindGroup:
+		var metricValue interface{}
+		metricUnit := UnitNone
+		for _, eachAttr := range value.Group() {
+			switch eachAttr.Key {
+			case EMFValueKey:
+				metricValue = eachAttr.Value.Resolve().Any()
+			case EMFUnitKey:
+				metricUnit = MetricUnit(eachAttr.Value.String())
+			}
+		}
+		if metricValue == nil {
+			return MetricValue{}, errors.Errorf("Metric %s group must define a %s attribute",
+				attr.Key,
+				EMFValueKey)
+		}
+		return NewMetricValue(metricValue, metricUnit)
+	}
+	return MetricValue{}, errors.Errorf("Metric %s must be a numeric value or a group. Found: %s",
+		attr.Key,
+		value.Kind())
+}
@@ -0,0 +1,100 @@
+//go:build go1.21
+// +build go1.21
+
+package cloudwatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEMFHandler(t *testing.T) {
+	// The EMF records and the log output share a writer, as they do when
+	// both are written to stdout in Lambda
+	var output bytes.Buffer
+	logger := slog.New(NewEMFHandler(slog.NewTextHandler(&output, nil), WithEMFWriter(&output)))
+	ctx := context.Background()
+
+	logger.InfoContext(ctx, "request complete",
+		slog.String("requestID", "96f98a63-d780-11e5-ab78-69015eb2dceb"),
+		slog.Group(EMFMetricGroupKey,
+			slog.String(EMFNamespaceKey, "SpecialNamespace"),
+			slog.Group(EMFDimensionsGroupKey, slog.String("Operation", "Get")),
+			slog.Int("invocations", 1),
+			slog.Group("latency",
+				slog.Float64(EMFValueKey, 12.5),
+				slog.String(EMFUnitKey, string(UnitMilliseconds)))))
+
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected an EMF line and a log line. Found: %q", output.String())
+	}
+	// The EMF record
+	var published emf
+	unmarshalErr := json.Unmarshal([]byte(lines[0]), &published)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal EMF record: %s\n%s", unmarshalErr, lines[0])
+	}
+	directive := published.AWS.CloudWatchMetrics[0]
+	if directive.Namespace != "SpecialNamespace" {
+		t.Fatalf("Expected SpecialNamespace. Found: %s", directive.Namespace)
+	}
+	units := make(map[string]string)
+	for _, eachMetric := range directive.Metrics {
+		units[eachMetric.Name] = eachMetric.Unit
+	}
+	if units["invocations"] != string(UnitNone) ||
+		units["latency"] != string(UnitMilliseconds) {
+		t.Fatalf("Unexpected metric definitions: %#v", directive.Metrics)
+	}
+	// The normal log output
+	if !strings.Contains(lines[1], "request complete") ||
+		!strings.Contains(lines[1], "96f98a63-d780-11e5-ab78-69015eb2dceb") {
+		t.Fatalf("Expected log output. Found: %s", lines[1])
+	}
+	if strings.Contains(lines[1], "invocations") {
+		t.Fatalf("Expected metric group to be removed from log output. Found: %s", lines[1])
+	}
+
+	// Records without metrics only produce log output
+	output.Reset()
+	logger.With(slog.String("stage", "prod")).InfoContext(ctx, "no metrics")
+	if strings.Count(output.String(), "\n") != 1 ||
+		!strings.Contains(output.String(), "no metrics") {
+		t.Fatalf("Expected only a log line. Found: %s", output.String())
+	}
+}
+
+func TestEMFHandlerPublishError(t *testing.T) {
+	var output bytes.Buffer
+	handler := NewEMFHandler(slog.NewTextHandler(&output, nil), WithEMFWriter(&output))
+	record := slog.NewRecord(time.Now(), slog.LevelInfo, "bad metric", 0)
+	record.AddAttrs(slog.Group(EMFMetricGroupKey,
+		slog.String(EMFNamespaceKey, "SpecialNamespace"),
+		slog.String("invocations", "one")))
+	handleErr := handler.Handle(context.Background(), record)
+	if handleErr == nil {
+		t.Fatalf("Expected an error for a non-numeric metric")
+	}
+	// The log record is still written
+	if !strings.Contains(output.String(), "bad metric") ||
+		strings.Contains(output.String(), "_aws") {
+		t.Fatalf("Expected only the log line. Found: %s", output.String())
+	}
+}
+
+func TestEMFHandlerDuration(t *testing.T) {
+	metricValue, metricValueErr := metricValueFromSlog(slog.Duration("elapsed",
+		1500*time.Microsecond))
+	if metricValueErr != nil {
+		t.Fatalf("Failed to convert duration: %s", metricValueErr)
+	}
+	if metricValue.Value != 1.5 || metricValue.Unit != UnitMilliseconds {
+		t.Fatalf("Expected 1.5 fractional milliseconds. Found: %#v", metricValue)
+	}
+}
@@ -4,29 +4,93 @@ package cloudwatch
 // info in the serialization layer. So we need a map of names to their
 // info. And we can map the rest in the log/publish statement...
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/pkg/errors"
 )
 
-var envMap map[string]string
+type contextKey int
 
-func init() {
-	// Get them all and turn it into a map...
-	// Ref: https://docs.aws.amazon.com/lambda/latest/dg/lambda-environment-variables.html
-	envMap = make(map[string]string)
-	envVars := os.Environ()
-	for _, eachValue := range envVars {
-		parts := strings.Split(eachValue, "=")
-		if len(parts) == 2 {
-			envMap[parts[0]] = parts[1]
-		}
+const (
+	// ContextKeyMetricSink is the context key for the io.Writer that
+	// PublishToContext writes to. Store a request scoped writer with
+	// context.WithValue(ctx, ContextKeyMetricSink, writer).
+	ContextKeyMetricSink contextKey = iota
+)
+
+const (
+	// maxDirectiveMetrics is the maximum number of metrics CloudWatch
+	// accepts in a single MetricDirective
+	maxDirectiveMetrics = 100
+	// maxDirectiveDimensions is the maximum number of dimensions CloudWatch
+	// accepts in a single MetricDirective
+	maxDirectiveDimensions = 9
+	// maxNamespaceLength is the maximum length of a CloudWatch namespace
+	maxNamespaceLength = 255
+	// maxDimensionNameLength is the maximum length of a dimension name
+	maxDimensionNameLength = 255
+	// maxDimensionValueLength is the maximum length of a dimension value
+	maxDimensionValueLength = 1024
+)
+
+// reNamespace matches the characters CloudWatch allows in a namespace
+// Ref: https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/cloudwatch_concepts.html#Namespace
+var reNamespace = regexp.MustCompile(`^[0-9A-Za-z.\-_/#: ]+$`)
+
+// ValidateNamespace returns an error if namespace isn't a valid CloudWatch
+// namespace. Namespaces must be non-empty, at most 255 characters, contain
+// at least one non-space character and only include alphanumerics and
+// the characters . - _ / # : and space. CloudWatch silently discards EMF
+// records with an invalid namespace, so PublishToSink also applies
+// this check.
+func ValidateNamespace(namespace string) error {
+	if strings.TrimSpace(namespace) == "" {
+		return errors.New("Namespace must not be empty")
 	}
+	if len(namespace) > maxNamespaceLength {
+		return errors.Errorf("Namespace must not exceed %d characters. Found: %d",
+			maxNamespaceLength,
+			len(namespace))
+	}
+	if !reNamespace.MatchString(namespace) {
+		return errors.Errorf("Namespace %s contains invalid characters", namespace)
+	}
+	return nil
 }
 
+// ErrTooManyMetrics is the error cause when a MetricDirective defines more
+// than 100 metrics. Use errors.Cause to test for it.
+var ErrTooManyMetrics = errors.New("MetricDirective must not define more than 100 metrics")
+
+// ErrTooManyDimensions is the error cause when a MetricDirective defines
+// more than 9 dimensions, including any EmbeddedMetric default dimensions.
+// Use errors.Cause to test for it.
+var ErrTooManyDimensions = errors.New("MetricDirective must not define more than 9 dimensions")
+
+// ErrNilEmbeddedMetric is returned when publishing a nil *EmbeddedMetric,
+// typically the result of ignoring a constructor error
+var ErrNilEmbeddedMetric = errors.New("EmbeddedMetric must not be nil")
+
+// ErrNonFiniteValue is the error cause when a metric Value, Counts or
+// StatisticSet includes NaN or an infinity, which can't be represented in
+// JSON. Use errors.Cause to test for it.
+var ErrNonFiniteValue = errors.New("Metric values must be finite")
+
 // MetricDirective represents an element in the array
 
 // MetricUnit Represents a MetricUnit type
@@ -89,10 +153,227 @@ const (
 	UnitNone MetricUnit = "None"
 )
 
-// MetricValue represents a metric value
+// validMetricUnits is the set of MetricUnit values CloudWatch accepts
+var validMetricUnits = map[MetricUnit]bool{
+	UnitSeconds:            true,
+	UnitMicroseconds:       true,
+	UnitMilliseconds:       true,
+	UnitBytes:              true,
+	UnitKilobytes:          true,
+	UnitMegabytes:          true,
+	UnitGigabytes:          true,
+	UnitTerabytes:          true,
+	UnitBits:               true,
+	UnitKilobits:           true,
+	UnitMegabits:           true,
+	UnitGigabits:           true,
+	UnitTerabits:           true,
+	UnitPercent:            true,
+	UnitCount:              true,
+	UnitBytesPerSecond:     true,
+	UnitKilobytesPerSecond: true,
+	UnitMegabytesPerSecond: true,
+	UnitGigabytesPerSecond: true,
+	UnitTerabytesPerSecond: true,
+	UnitBitsPerSecond:      true,
+	UnitKilobitsPerSecond:  true,
+	UnitMegabitsPerSecond:  true,
+	UnitGigabitsPerSecond:  true,
+	UnitTerabitsPerSecond:  true,
+	UnitCountPerSecond:     true,
+	UnitNone:               true,
+}
+
+const (
+	// StorageResolutionHigh publishes a high-resolution (1 second) metric
+	StorageResolutionHigh = 1
+	// StorageResolutionStandard publishes a standard (60 second) metric
+	StorageResolutionStandard = 60
+)
+
+// StatisticSet is a pre-aggregated set of observations. Use it as a
+// MetricValue Value to publish a single record that represents many
+// samples.
+type StatisticSet struct {
+	SampleCount float64 `json:"SampleCount"`
+	Sum         float64 `json:"Sum"`
+	Minimum     float64 `json:"Minimum"`
+	Maximum     float64 `json:"Maximum"`
+}
+
+// Validate returns an error if the StatisticSet isn't internally
+// consistent
+func (ss StatisticSet) Validate() error {
+	if ss.SampleCount < 0 {
+		return errors.Errorf("StatisticSet SampleCount must not be negative. Found: %f",
+			ss.SampleCount)
+	}
+	if ss.Minimum > ss.Maximum {
+		return errors.Errorf("StatisticSet Minimum (%f) must not be greater than Maximum (%f)",
+			ss.Minimum,
+			ss.Maximum)
+	}
+	if ss.SampleCount == 0 && ss.Sum != 0 {
+		return errors.Errorf("StatisticSet with zero SampleCount must have zero Sum. Found: %f",
+			ss.Sum)
+	}
+	return nil
+}
+
+// addSample returns a copy of the StatisticSet that includes value as
+// another sample
+func (ss StatisticSet) addSample(value float64) StatisticSet {
+	if ss.SampleCount == 0 || value < ss.Minimum {
+		ss.Minimum = value
+	}
+	if ss.SampleCount == 0 || value > ss.Maximum {
+		ss.Maximum = value
+	}
+	ss.SampleCount++
+	ss.Sum += value
+	return ss
+}
+
+// MetricValue represents a metric value. Value must be numeric; strings,
+// bools and other types are rejected at publish time. Zero and negative
+// numeric values are valid observations and are emitted as-is. Only a nil
+// Value is treated as unset and rejected at publish time.
+//
+// Value may also be a []float64 to publish many observations in a single
+// record. In that case Counts may optionally provide the number of times
+// each value was observed. Counts must be the same length as Value and
+// is emitted as the top level property named "<MetricName>Counts".
+//
+// Value may also be a StatisticSet (or *StatisticSet) for pre-aggregated
+// metrics. The SampleCount, Sum, Minimum and Maximum fields are emitted
+// as an object under the metric's property key.
+//
+// Namespace optionally publishes the metric to a different namespace than
+// its MetricDirective. The metric keeps the directive's dimensions. See
+// EmbeddedMetric.MarshalJSON for how metrics are grouped.
 type MetricValue struct {
-	Value interface{}
-	Unit  MetricUnit
+	Value     interface{}
+	Unit      MetricUnit
+	Counts    []float64
+	Namespace string
+	// StorageResolution is either StorageResolutionHigh (1) or
+	// StorageResolutionStandard (60). The zero value is treated as
+	// StorageResolutionStandard.
+	StorageResolution int
+}
+
+// NewMetricValue returns a MetricValue after verifying that unit is one
+// of the declared MetricUnit constants and that a non-nil value is
+// numeric. CloudWatch rejects the entire record for an unknown unit, so
+// prefer this to a MetricValue struct literal.
+func NewMetricValue(value interface{}, unit MetricUnit) (MetricValue, error) {
+	if !validMetricUnits[unit] {
+		return MetricValue{}, errors.Errorf("Unsupported MetricUnit: %s", unit)
+	}
+	if value != nil {
+		valueErr := validateMetricValue(value)
+		if valueErr != nil {
+			return MetricValue{}, valueErr
+		}
+	}
+	return MetricValue{
+		Value: value,
+		Unit:  unit,
+	}, nil
+}
+
+// isNumericKind returns true if the kind is an integer or float kind
+func isNumericKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// validateMetricValue returns an error if the value isn't one that
+// CloudWatch treats as a metric: a number, a slice or array of numbers,
+// or a StatisticSet. Strings are rejected even if they contain a number.
+func validateMetricValue(value interface{}) error {
+	switch value.(type) {
+	case StatisticSet, *StatisticSet:
+		return nil
+	}
+	valueType := reflect.TypeOf(value)
+	if isNumericKind(valueType.Kind()) {
+		return nil
+	}
+	switch valueType.Kind() {
+	case reflect.Slice, reflect.Array:
+		if isNumericKind(valueType.Elem().Kind()) {
+			return nil
+		}
+	}
+	return errors.Errorf("Metric Value must be numeric, a numeric array or a StatisticSet. Found: %T",
+		value)
+}
+
+// numericFloat64 returns the value as a float64 if it's a scalar number
+func numericFloat64(value interface{}) (float64, bool) {
+	if value == nil {
+		return 0, false
+	}
+	reflectValue := reflect.ValueOf(value)
+	switch reflectValue.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(reflectValue.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(reflectValue.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return reflectValue.Float(), true
+	}
+	return 0, false
+}
+
+// isFinite returns true if value is neither NaN nor an infinity
+func isFinite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+// isFiniteMetricValue returns true if every number in the metric's Value
+// and Counts is finite
+func isFiniteMetricValue(metric MetricValue) bool {
+	var values []float64
+	switch typedValue := metric.Value.(type) {
+	case StatisticSet:
+		values = []float64{typedValue.SampleCount,
+			typedValue.Sum,
+			typedValue.Minimum,
+			typedValue.Maximum}
+	case *StatisticSet:
+		if typedValue != nil {
+			values = []float64{typedValue.SampleCount,
+				typedValue.Sum,
+				typedValue.Minimum,
+				typedValue.Maximum}
+		}
+	default:
+		if scalarValue, isScalar := numericFloat64(typedValue); isScalar {
+			values = []float64{scalarValue}
+		} else if arrayValue, isArray := numericSliceFloat64(typedValue); isArray {
+			values = arrayValue
+		}
+	}
+	values = append(values, metric.Counts...)
+	for _, eachValue := range values {
+		if !isFinite(eachValue) {
+			return false
+		}
+	}
+	return true
+}
+
+// metricCountsKey returns the top level property name for the Counts of an
+// array valued metric
+func metricCountsKey(metricName string) string {
+	return metricName + "Counts"
 }
 
 // MetricDirective is the directive that encapsulates a metric
@@ -105,28 +386,476 @@ type MetricDirective struct {
 
 	// namespace corresponds to the JSON schema field "Namespace".
 	namespace string
+
+	// properties are the directive scoped properties
+	properties map[string]interface{}
+
+	// dimensionOrder is the insertion order of dimensions added
+	// via Dimension
+	dimensionOrder []string
+
+	// dimensionSets are the explicit DimensionSets added via
+	// AddDimensionSet
+	dimensionSets [][]string
+}
+
+// Namespace returns the CloudWatch namespace of the directive
+func (md *MetricDirective) Namespace() string {
+	return md.namespace
+}
+
+// metricNamespace returns the namespace the metric is published to, which
+// is the directive namespace unless the metric overrides it
+func (md *MetricDirective) metricNamespace(metric MetricValue) string {
+	if metric.Namespace != "" {
+		return metric.Namespace
+	}
+	return md.namespace
+}
+
+// SetNamespace is a fluent builder that changes the CloudWatch namespace
+// of the directive. Use it when the namespace depends on configuration
+// that isn't available when the directive is created. As with the
+// namespace supplied to NewMetricDirective, the value is checked with
+// ValidateNamespace when the metric is published. Call ValidateNamespace
+// first to detect an invalid namespace earlier.
+func (md *MetricDirective) SetNamespace(ns string) *MetricDirective {
+	md.namespace = ns
+	return md
+}
+
+// AddDimensionSet is a fluent builder that defines a DimensionSet that
+// groups the given dimension keys, eg ["Service", "Operation"]. When a
+// directive defines at least one DimensionSet only the explicit sets
+// are published. Otherwise each dimension is published as its own
+// DimensionSet. Every key must be defined in the directive Dimensions
+// (or the EmbeddedMetric default dimensions) when the metric is published.
+func (md *MetricDirective) AddDimensionSet(keys ...string) *MetricDirective {
+	dimensionSet := make([]string, len(keys))
+	copy(dimensionSet, keys)
+	md.dimensionSets = append(md.dimensionSets, dimensionSet)
+	return md
+}
+
+// Dimension adds a dimension to the directive after verifying that the name
+// and value satisfy the CloudWatch constraints. Names must be 1-255 ASCII
+// characters and values 1-1024 characters, each with at least one
+// non-whitespace character. Dimensions added with Dimension are emitted
+// in insertion order, ahead of any dimensions added directly to the
+// Dimensions map.
+func (md *MetricDirective) Dimension(name string, value string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("Dimension name must not be empty")
+	}
+	if len(name) > maxDimensionNameLength {
+		return errors.Errorf("Dimension name %s must not exceed %d characters",
+			name,
+			maxDimensionNameLength)
+	}
+	for _, eachRune := range name {
+		if eachRune > unicode.MaxASCII {
+			return errors.Errorf("Dimension name %s must only contain ASCII characters",
+				name)
+		}
+	}
+	if strings.TrimSpace(value) == "" {
+		return errors.Errorf("Dimension %s value must not be empty", name)
+	}
+	if len(value) > maxDimensionValueLength {
+		return errors.Errorf("Dimension %s value must not exceed %d characters",
+			name,
+			maxDimensionValueLength)
+	}
+	if md.Dimensions == nil {
+		md.Dimensions = make(map[string]string)
+	}
+	if _, exists := md.Dimensions[name]; !exists {
+		md.dimensionOrder = append(md.dimensionOrder, name)
+	}
+	md.Dimensions[name] = value
+	return nil
+}
+
+// WithProperty is a fluent builder to add a property that is only
+// published alongside this directive's metrics. Directive properties are
+// merged into the same top level JSON object as the EmbeddedMetric
+// properties. When keys clash, directive properties override
+// EmbeddedMetric properties and directives created later override
+// directives created earlier. Metric values and dimension values
+// override properties with the same key.
+func (md *MetricDirective) WithProperty(key string, value interface{}) *MetricDirective {
+	if md.properties == nil {
+		md.properties = make(map[string]interface{})
+	}
+	md.properties[key] = value
+	return md
+}
+
+// WithDimensionValue is a fluent builder that adds a dimension whose value
+// is formatted from a typed input. Formatting rules are:
+//   - string values are used as-is
+//   - bool values are "true" or "false"
+//   - integer values use base 10
+//   - float values use the shortest decimal representation without an
+//     exponent (1.5, 100, 0.25)
+//   - fmt.Stringer values use String()
+//   - all other values use fmt.Sprintf("%v")
+func (md *MetricDirective) WithDimensionValue(key string, value interface{}) *MetricDirective {
+	var dimensionValue string
+	switch typedValue := value.(type) {
+	case string:
+		dimensionValue = typedValue
+	case bool:
+		dimensionValue = strconv.FormatBool(typedValue)
+	case int:
+		dimensionValue = strconv.FormatInt(int64(typedValue), 10)
+	case int8:
+		dimensionValue = strconv.FormatInt(int64(typedValue), 10)
+	case int16:
+		dimensionValue = strconv.FormatInt(int64(typedValue), 10)
+	case int32:
+		dimensionValue = strconv.FormatInt(int64(typedValue), 10)
+	case int64:
+		dimensionValue = strconv.FormatInt(typedValue, 10)
+	case uint:
+		dimensionValue = strconv.FormatUint(uint64(typedValue), 10)
+	case uint8:
+		dimensionValue = strconv.FormatUint(uint64(typedValue), 10)
+	case uint16:
+		dimensionValue = strconv.FormatUint(uint64(typedValue), 10)
+	case uint32:
+		dimensionValue = strconv.FormatUint(uint64(typedValue), 10)
+	case uint64:
+		dimensionValue = strconv.FormatUint(typedValue, 10)
+	case float32:
+		dimensionValue = strconv.FormatFloat(float64(typedValue), 'f', -1, 32)
+	case float64:
+		dimensionValue = strconv.FormatFloat(typedValue, 'f', -1, 64)
+	case fmt.Stringer:
+		dimensionValue = typedValue.String()
+	default:
+		dimensionValue = fmt.Sprintf("%v", typedValue)
+	}
+	if md.Dimensions == nil {
+		md.Dimensions = make(map[string]string)
+	}
+	md.Dimensions[key] = dimensionValue
+	return md
+}
+
+// setMetric records the metric value, initializing the Metrics map if needed
+func (md *MetricDirective) setMetric(name string, value MetricValue) *MetricDirective {
+	if md.Metrics == nil {
+		md.Metrics = make(map[string]MetricValue)
+	}
+	md.Metrics[name] = value
+	return md
+}
+
+// Count is a fluent builder that records n as a UnitCount metric
+func (md *MetricDirective) Count(name string, n float64) *MetricDirective {
+	return md.setMetric(name, MetricValue{Value: n, Unit: UnitCount})
+}
+
+// Milliseconds is a fluent builder that records the duration d as a
+// UnitMilliseconds metric. Fractional milliseconds are preserved.
+func (md *MetricDirective) Milliseconds(name string, d time.Duration) *MetricDirective {
+	return md.setMetric(name, MetricValue{
+		Value: float64(d) / float64(time.Millisecond),
+		Unit:  UnitMilliseconds,
+	})
+}
+
+// Add accumulates delta into the named metric. When the metric already
+// has a scalar numeric value the delta is added to it. An array value has
+// delta appended as another observation, with a Count of 1 if the metric
+// defines Counts, and a StatisticSet has delta added as another sample.
+// The existing unit is preserved. Otherwise the metric is set to delta.
+// The first Add for a metric establishes its unit: Add uses UnitCount,
+// use AddWithUnit to establish a different unit.
+func (md *MetricDirective) Add(name string, delta float64) *MetricDirective {
+	unit := UnitCount
+	if existing, exists := md.Metrics[name]; exists && existing.Unit != "" {
+		unit = existing.Unit
+	}
+	return md.AddWithUnit(name, delta, unit)
+}
+
+// AddWithUnit accumulates delta into the named metric, as Add does, and
+// sets its unit. It's typically used for the first observation, with
+// subsequent observations recorded via Add.
+func (md *MetricDirective) AddWithUnit(name string, delta float64, unit MetricUnit) *MetricDirective {
+	metricValue := md.Metrics[name]
+	if existingValues, isArray := numericSliceFloat64(metricValue.Value); isArray {
+		if metricValue.Counts != nil && len(metricValue.Counts) == len(existingValues) {
+			metricValue.Counts = append(append([]float64{}, metricValue.Counts...), 1)
+		}
+		metricValue.Value = append(existingValues, delta)
+	} else {
+		metricValue.Value = accumulateMetricValue(metricValue.Value, delta)
+	}
+	metricValue.Unit = unit
+	return md.setMetric(name, metricValue)
+}
+
+// accumulateMetricValue returns the non-array metric value with delta
+// folded in. Scalars are summed and StatisticSets have delta added as
+// another sample. Any other value, including nil, is replaced by delta.
+func accumulateMetricValue(value interface{}, delta float64) interface{} {
+	switch typedValue := value.(type) {
+	case StatisticSet:
+		return typedValue.addSample(delta)
+	case *StatisticSet:
+		if typedValue != nil {
+			return typedValue.addSample(delta)
+		}
+	}
+	if existingValue, isNumeric := numericFloat64(value); isNumeric {
+		return existingValue + delta
+	}
+	return delta
+}
+
+// Increment adds one to the named metric, creating it as a UnitCount
+// metric if it doesn't exist. It's shorthand for Add(name, 1) and is
+// intended for counting records in a loop body.
+func (md *MetricDirective) Increment(name string) *MetricDirective {
+	return md.Add(name, 1)
+}
+
+// IncrementBy adds n to the named metric, creating it as a UnitCount
+// metric if it doesn't exist. It's shorthand for Add(name, n).
+func (md *MetricDirective) IncrementBy(name string, n float64) *MetricDirective {
+	return md.Add(name, n)
+}
+
+// StartTimer starts measuring elapsed time and returns a function that
+// records the elapsed duration as a UnitMilliseconds metric when called.
+// Typical usage is:
+//
+//	defer metricDirective.StartTimer("latency")()
+func (md *MetricDirective) StartTimer(name string) func() {
+	start := time.Now()
+	return func() {
+		md.Milliseconds(name, time.Since(start))
+	}
 }
 
-// EmbeddedMetric represents an embedded metric that should be published
+// Bytes is a fluent builder that records b as a UnitBytes metric
+func (md *MetricDirective) Bytes(name string, b int64) *MetricDirective {
+	return md.setMetric(name, MetricValue{Value: b, Unit: UnitBytes})
+}
+
+// EMFMarshaler is implemented by property values that control their own
+// EMF representation. MarshalEMF is consulted before falling back to the
+// default JSON marshalling of the value.
+type EMFMarshaler interface {
+	MarshalEMF() (interface{}, error)
+}
+
+// EmbeddedMetric represents an embedded metric that should be published.
+// An EmbeddedMetric may be shared across goroutines: creating directives,
+// setting properties and publishing are safe for concurrent use. The
+// Dimensions and Metrics maps of an individual MetricDirective are not
+// guarded and should only be modified by a single goroutine.
+//
+// Every method is safe to call on a nil *EmbeddedMetric, typically the
+// result of ignoring a constructor error. Fluent builders return nil,
+// accessors return zero values, NewMetricDirective and Heartbeat return a
+// directive that isn't included in any EmbeddedMetric, and publishing or
+// marshalling returns ErrNilEmbeddedMetric.
 type EmbeddedMetric struct {
-	metrics    []*MetricDirective
-	properties map[string]interface{}
+	mu                sync.Mutex
+	metrics           []*MetricDirective
+	properties        map[string]interface{}
+	defaultDimensions map[string]string
+	prettyOutput      bool
+	timestamp         time.Time
+	disabled          bool
+	logGroupName      string
+	logStreamName     string
+	validateOutput    bool
+}
+
+// WithValidation is a fluent builder that verifies each record with
+// ValidateEMF before it's written. Validation adds a JSON round trip to
+// every publish, so it's intended for tests and local development.
+func (em *EmbeddedMetric) WithValidation() *EmbeddedMetric {
+	if em == nil {
+		return nil
+	}
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	em.validateOutput = true
+	return em
+}
+
+// WithLogGroup is a fluent builder that overrides the log_group_name
+// value, which otherwise defaults to AWS_LAMBDA_LOG_GROUP_NAME. Use it
+// when the CloudWatch agent is configured to read a custom log group.
+func (em *EmbeddedMetric) WithLogGroup(name string) *EmbeddedMetric {
+	if em == nil {
+		return nil
+	}
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	em.logGroupName = name
+	return em
+}
+
+// WithLogStream is a fluent builder that overrides the log_stream_name
+// value, which otherwise defaults to AWS_LAMBDA_LOG_STREAM_NAME
+func (em *EmbeddedMetric) WithLogStream(name string) *EmbeddedMetric {
+	if em == nil {
+		return nil
+	}
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	em.logStreamName = name
+	return em
+}
+
+const (
+	// AWSRegionProperty is the property set by WithAWSContext to the AWS
+	// region
+	AWSRegionProperty = "awsRegion"
+	// AWSAccountIDProperty is the property set by WithAWSContext to the
+	// AWS account ID
+	AWSAccountIDProperty = "awsAccountID"
+)
+
+// WithAWSContext is a fluent builder that adds the AWS region and account
+// ID as searchable properties, which is useful for cross-account
+// dashboards. The region is read from AWS_REGION. The account ID is
+// parsed from the invoked function ARN in the Lambda context, which also
+// provides the region if AWS_REGION is unset. Values that can't be
+// determined, for instance when running outside Lambda, are omitted.
+func (em *EmbeddedMetric) WithAWSContext(ctx context.Context) *EmbeddedMetric {
+	if em == nil {
+		return nil
+	}
+	region := os.Getenv("AWS_REGION")
+	accountID := ""
+	if ctx != nil {
+		if lambdaContext, lambdaContextOk := lambdacontext.FromContext(ctx); lambdaContextOk {
+			// arn:aws:lambda:us-east-1:123456789012:function:name
+			arnParts := strings.Split(lambdaContext.InvokedFunctionArn, ":")
+			if len(arnParts) >= 6 && arnParts[0] == "arn" {
+				accountID = arnParts[4]
+				if region == "" {
+					region = arnParts[3]
+				}
+			}
+		}
+	}
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	if region != "" {
+		em.setProperty(AWSRegionProperty, region)
+	}
+	if accountID != "" {
+		em.setProperty(AWSAccountIDProperty, accountID)
+	}
+	return em
+}
+
+// WithTimestamp is a fluent builder to set the EMF Timestamp. Use it when
+// replaying or backfilling historical events. When unset the current
+// time is used.
+func (em *EmbeddedMetric) WithTimestamp(t time.Time) *EmbeddedMetric {
+	if em == nil {
+		return nil
+	}
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	em.timestamp = t
+	return em
+}
+
+// WithPrettyOutput is a fluent builder that publishes the EmbeddedMetric as
+// indented, multi-line JSON. This is only intended for local debugging.
+// It MUST NOT be used in production since EMF requires every log event
+// to be a single line.
+func (em *EmbeddedMetric) WithPrettyOutput() *EmbeddedMetric {
+	if em == nil {
+		return nil
+	}
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	em.prettyOutput = true
+	return em
+}
+
+// WithDefaultDimensions is a fluent builder to define dimensions that are
+// applied to every MetricDirective in the EmbeddedMetric. Directive
+// dimensions take precedence when a key is defined in both. The merged
+// dimensions are subject to the same limit as directive dimensions.
+func (em *EmbeddedMetric) WithDefaultDimensions(dimensions map[string]string) *EmbeddedMetric {
+	if em == nil {
+		return nil
+	}
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	em.defaultDimensions = dimensions
+	return em
+}
+
+// directiveDimensions returns the directive's dimensions merged with
+// the EmbeddedMetric default dimensions
+func (em *EmbeddedMetric) directiveDimensions(md *MetricDirective) map[string]string {
+	if len(em.defaultDimensions) == 0 {
+		return md.Dimensions
+	}
+	dimensions := make(map[string]string)
+	for eachKey, eachValue := range em.defaultDimensions {
+		dimensions[eachKey] = eachValue
+	}
+	for eachKey, eachValue := range md.Dimensions {
+		dimensions[eachKey] = eachValue
+	}
+	return dimensions
 }
 
 // WithProperty is a fluent builder to add property to the EmbeddedMetric state.
 // Properties should be used for high cardintality values that need to be
 // searchable, but not treated as independent metrics
 func (em *EmbeddedMetric) WithProperty(key string, value interface{}) *EmbeddedMetric {
+	if em == nil {
+		return nil
+	}
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	em.setProperty(key, value)
+	return em
+}
+
+// WithProperties is a fluent builder that merges every entry in props into
+// the EmbeddedMetric properties. Keys that already exist, whether set by
+// WithProperty or an earlier WithProperties call, are overwritten by the
+// values in props.
+func (em *EmbeddedMetric) WithProperties(props map[string]interface{}) *EmbeddedMetric {
+	if em == nil {
+		return nil
+	}
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	for eachKey, eachValue := range props {
+		em.setProperty(eachKey, eachValue)
+	}
+	return em
+}
+
+// setProperty updates the properties. Callers must hold the lock.
+func (em *EmbeddedMetric) setProperty(key string, value interface{}) {
 	if em.properties == nil {
 		em.properties = make(map[string]interface{})
 	}
 	em.properties[key] = value
-	return em
 }
 
-// NewMetricDirective returns an initialized MetricDirective
-// that's included in the EmbeddedMetric instance
-func (em *EmbeddedMetric) NewMetricDirective(namespace string,
+// newMetricDirective returns an initialized MetricDirective that isn't yet
+// included in any EmbeddedMetric
+func newMetricDirective(namespace string,
 	dimensions map[string]string) *MetricDirective {
 	md := &MetricDirective{
 		namespace:  namespace,
@@ -136,86 +865,513 @@ func (em *EmbeddedMetric) NewMetricDirective(namespace string,
 	if md.Dimensions == nil {
 		md.Dimensions = make(map[string]string)
 	}
+	return md
+}
+
+// appendDirective includes the MetricDirective in the EmbeddedMetric. A
+// nil EmbeddedMetric returns the directive without including it.
+func (em *EmbeddedMetric) appendDirective(md *MetricDirective) *MetricDirective {
+	if em == nil {
+		return md
+	}
+	em.mu.Lock()
+	defer em.mu.Unlock()
 	em.metrics = append(em.metrics, md)
 	return md
 }
 
-// PublishToSink writes the EmbeddedMetric info to the provided writer
-func (em *EmbeddedMetric) PublishToSink(additionalProperties map[string]interface{},
-	sink io.Writer) {
-	// BEGIN - Preconditions
+// NewMetricDirective returns an initialized MetricDirective
+// that's included in the EmbeddedMetric instance. A nil EmbeddedMetric
+// returns a directive that isn't included in any EmbeddedMetric so that
+// callers can populate it without panicking. Publishing the nil
+// EmbeddedMetric returns ErrNilEmbeddedMetric.
+func (em *EmbeddedMetric) NewMetricDirective(namespace string,
+	dimensions map[string]string) *MetricDirective {
+	return em.appendDirective(newMetricDirective(namespace, dimensions))
+}
+
+// directiveDimensionSets returns the DimensionSets emitted for the
+// directive. Explicit DimensionSets are returned as-is. Otherwise each
+// dimension is published as its own DimensionSet. Dimensions added via
+// MetricDirective.Dimension are emitted first, in insertion order,
+// followed by the remaining dimensions sorted by name.
+func (em *EmbeddedMetric) directiveDimensionSets(md *MetricDirective) [][]string {
+	dimensionSets := [][]string{}
+	if len(md.dimensionSets) != 0 {
+		return append(dimensionSets, md.dimensionSets...)
+	}
+	dimensions := em.directiveDimensions(md)
+	ordered := make(map[string]bool)
+	for _, eachKey := range md.dimensionOrder {
+		if _, exists := dimensions[eachKey]; exists && !ordered[eachKey] {
+			ordered[eachKey] = true
+			dimensionSets = append(dimensionSets, []string{eachKey})
+		}
+	}
+	unorderedKeys := []string{}
+	for eachKey := range dimensions {
+		if !ordered[eachKey] {
+			unorderedKeys = append(unorderedKeys, eachKey)
+		}
+	}
+	sort.Strings(unorderedKeys)
+	for _, eachKey := range unorderedKeys {
+		dimensionSets = append(dimensionSets, []string{eachKey})
+	}
+	return dimensionSets
+}
+
+// EstimatedDataPoints returns the number of distinct CloudWatch metric data
+// points that publishing the EmbeddedMetric produces. Each metric is
+// recorded once per DimensionSet in its directive, or once if the
+// directive has no dimensions. Use this to budget custom metric costs.
+func (em *EmbeddedMetric) EstimatedDataPoints() int {
+	if em == nil {
+		return 0
+	}
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	dataPoints := 0
+	for _, eachDirective := range em.metrics {
+		dimensionSetCount := len(em.directiveDimensionSets(eachDirective))
+		if dimensionSetCount == 0 {
+			dimensionSetCount = 1
+		}
+		dataPoints += len(eachDirective.Metrics) * dimensionSetCount
+	}
+	return dataPoints
+}
+
+// Directives returns the MetricDirectives in the order they were created.
+// The returned slice is a copy, but the directives themselves are shared.
+func (em *EmbeddedMetric) Directives() []*MetricDirective {
+	if em == nil {
+		return nil
+	}
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	directives := make([]*MetricDirective, len(em.metrics))
+	copy(directives, em.metrics)
+	return directives
+}
+
+// Properties returns a copy of the EmbeddedMetric properties. Directive
+// scoped properties aren't included.
+func (em *EmbeddedMetric) Properties() map[string]interface{} {
+	if em == nil {
+		return nil
+	}
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	properties := make(map[string]interface{}, len(em.properties))
+	for eachKey, eachValue := range em.properties {
+		properties[eachKey] = eachValue
+	}
+	return properties
+}
+
+// Heartbeat adds a MetricDirective with a single zero valued UnitCount
+// metric. Publishing heartbeats during idle periods keeps the metric
+// alive in CloudWatch.
+func (em *EmbeddedMetric) Heartbeat(namespace string,
+	dimensions map[string]string,
+	metricName string) *MetricDirective {
+	md := newMetricDirective(namespace, dimensions)
+	md.Metrics[metricName] = MetricValue{
+		Unit:  UnitCount,
+		Value: 0,
+	}
+	return em.appendDirective(md)
+}
+
+// Validate returns every problem that would prevent the EmbeddedMetric
+// from being published, rather than only the first one. It's intended as
+// a dry run for tests and local development. The result is empty if the
+// EmbeddedMetric can be published.
+func (em *EmbeddedMetric) Validate() []error {
+	if em == nil {
+		return []error{ErrNilEmbeddedMetric}
+	}
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	return em.validationErrors()
+}
+
+// validatePreconditions returns the first error that prevents a directive
+// from being published. Callers must hold the lock.
+func (em *EmbeddedMetric) validatePreconditions() error {
+	validationErrs := em.validationErrors()
+	if len(validationErrs) != 0 {
+		return validationErrs[0]
+	}
+	return nil
+}
+
+// validationErrors returns every error that prevents a directive from
+// being published. Metrics are checked in name order so that the result
+// is stable. Callers must hold the lock.
+func (em *EmbeddedMetric) validationErrors() []error {
+	var validationErrs []error
+	nilValueMetrics := []string{}
 	for _, eachDirective := range em.metrics {
 		// Precondition...
-		if len(eachDirective.Dimensions) > 9 {
-			fmt.Printf("DimensionSet for structured metric must not have more than 9 elements. Count: %d",
-				len(eachDirective.Dimensions))
+		namespaceErr := ValidateNamespace(eachDirective.namespace)
+		if namespaceErr != nil {
+			validationErrs = append(validationErrs, namespaceErr)
+		}
+		dimensions := em.directiveDimensions(eachDirective)
+		if len(dimensions) > maxDirectiveDimensions {
+			validationErrs = append(validationErrs, errors.Wrapf(ErrTooManyDimensions,
+				"Namespace %s defines %d dimensions",
+				eachDirective.namespace,
+				len(dimensions)))
+		}
+		for _, eachDimensionSet := range eachDirective.dimensionSets {
+			if len(eachDimensionSet) == 0 {
+				validationErrs = append(validationErrs,
+					errors.Errorf("Namespace %s defines an empty DimensionSet",
+						eachDirective.namespace))
+			}
+			for _, eachKey := range eachDimensionSet {
+				if _, exists := dimensions[eachKey]; !exists {
+					validationErrs = append(validationErrs,
+						errors.Errorf("Namespace %s DimensionSet references undefined dimension: %s",
+							eachDirective.namespace,
+							eachKey))
+				}
+			}
+		}
+		if len(eachDirective.Metrics) > maxDirectiveMetrics {
+			validationErrs = append(validationErrs, errors.Wrapf(ErrTooManyMetrics,
+				"Namespace %s defines %d metrics",
+				eachDirective.namespace,
+				len(eachDirective.Metrics)))
+		}
+		metricNames := make([]string, 0, len(eachDirective.Metrics))
+		for eachName := range eachDirective.Metrics {
+			metricNames = append(metricNames, eachName)
+		}
+		sort.Strings(metricNames)
+		for _, eachName := range metricNames {
+			eachMetric := eachDirective.Metrics[eachName]
+			metricErr := validateMetric(eachName, eachMetric)
+			if metricErr != nil {
+				validationErrs = append(validationErrs, metricErr)
+			}
+			if eachMetric.Value == nil {
+				nilValueMetrics = append(nilValueMetrics, eachName)
+			} else if statisticSet, isPointer := eachMetric.Value.(*StatisticSet); isPointer && statisticSet == nil {
+				nilValueMetrics = append(nilValueMetrics, eachName)
+			}
+		}
+	}
+	if len(nilValueMetrics) != 0 {
+		sort.Strings(nilValueMetrics)
+		validationErrs = append(validationErrs,
+			errors.Errorf("Metric Value must not be nil. Metrics: %s",
+				strings.Join(nilValueMetrics, ", ")))
+	}
+	return validationErrs
+}
+
+// validateMetric returns the first problem with a metric that has a
+// non-nil Value. Nil values are reported together by validationErrors.
+func validateMetric(name string, metric MetricValue) error {
+	if metric.Namespace != "" {
+		namespaceErr := ValidateNamespace(metric.Namespace)
+		if namespaceErr != nil {
+			return errors.Wrapf(namespaceErr, "Metric %s has an invalid Namespace", name)
+		}
+	}
+	if metric.Unit != "" && !validMetricUnits[metric.Unit] {
+		return errors.Errorf("Metric %s has an unsupported MetricUnit: %s", name, metric.Unit)
+	}
+	if metric.Value != nil {
+		valueErr := validateMetricValue(metric.Value)
+		if valueErr != nil {
+			return errors.Wrapf(valueErr, "Metric %s has an invalid Value", name)
+		}
+		if !isFiniteMetricValue(metric) {
+			return errors.Wrapf(ErrNonFiniteValue,
+				"Metric %s has a NaN or infinite value",
+				name)
+		}
+	}
+	switch typedValue := metric.Value.(type) {
+	case StatisticSet:
+		validateErr := typedValue.Validate()
+		if validateErr != nil {
+			return errors.Wrapf(validateErr, "Metric %s has invalid StatisticSet", name)
+		}
+	case *StatisticSet:
+		if typedValue == nil {
+			return nil
+		}
+		validateErr := typedValue.Validate()
+		if validateErr != nil {
+			return errors.Wrapf(validateErr, "Metric %s has invalid StatisticSet", name)
+		}
+	}
+	if metric.Counts != nil {
+		arrayValue, isArrayValue := metric.Value.([]float64)
+		if !isArrayValue {
+			return errors.Errorf("Metric %s Counts requires a []float64 Value", name)
+		}
+		if len(arrayValue) != len(metric.Counts) {
+			return errors.Errorf("Metric %s Counts length (%d) must match Value length (%d)",
+				name,
+				len(metric.Counts),
+				len(arrayValue))
 		}
 	}
-	// END - Preconditions
+	switch metric.StorageResolution {
+	case 0, StorageResolutionHigh, StorageResolutionStandard:
+	default:
+		return errors.Errorf("Metric %s StorageResolution must be %d or %d. Found: %d",
+			name,
+			StorageResolutionHigh,
+			StorageResolutionStandard,
+			metric.StorageResolution)
+	}
+	return nil
+}
+
+// PublishToSink writes the EmbeddedMetric info to the provided writer.
+// It returns an error without writing anything if a directive has an
+// invalid namespace, if a metric doesn't define a Value, has Counts
+// that don't match its Value, has a NaN or infinite value
+// (ErrNonFiniteValue), has an unsupported Unit or invalid
+// StorageResolution, if a directive defines too many metrics or
+// dimensions, has a DimensionSet that references an undefined
+// dimension, or if the EmbeddedMetric can't be marshalled. Errors
+// writing to the sink are also returned so that callers can react to
+// failed metric emission (eg, a closed file). A disabled EmbeddedMetric
+// never writes to the sink and always returns nil. A nil EmbeddedMetric
+// returns ErrNilEmbeddedMetric. Only the first validation error is
+// returned; use Validate to report all of them.
+func (em *EmbeddedMetric) PublishToSink(additionalProperties map[string]interface{},
+	sink io.Writer) error {
+	return em.publishToSink(additionalProperties, sink, false)
+}
+
+// publishToSink implements PublishToSink. The record is indented if either
+// the EmbeddedMetric was built WithPrettyOutput or prettyOutput is true.
+func (em *EmbeddedMetric) publishToSink(additionalProperties map[string]interface{},
+	sink io.Writer,
+	prettyOutput bool) error {
+	if em == nil {
+		return ErrNilEmbeddedMetric
+	}
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	if em.disabled {
+		return nil
+	}
+
+	preconditionErr := em.validatePreconditions()
+	if preconditionErr != nil {
+		return preconditionErr
+	}
 	for eachKey, eachValue := range additionalProperties {
-		em = em.WithProperty(eachKey, eachValue)
+		em.setProperty(eachKey, eachValue)
 	}
-	rawJSON, rawJSONErr := json.Marshal(em)
-	var writtenErr error
-	if rawJSONErr == nil {
-		_, writtenErr = io.WriteString(sink, (string)(rawJSON))
-	} else {
-		_, writtenErr = io.WriteString(sink, fmt.Sprintf("Error publishing metric: %v", rawJSONErr))
+	rawJSON, rawJSONErr := em.marshalJSON()
+	if rawJSONErr != nil {
+		return errors.Wrap(rawJSONErr, "Failed to marshal metric")
+	}
+	if em.validateOutput {
+		validateErr := ValidateEMF(rawJSON)
+		if validateErr != nil {
+			return errors.Wrap(validateErr, "Failed to validate metric")
+		}
 	}
+	if em.prettyOutput || prettyOutput {
+		var indented bytes.Buffer
+		indentErr := json.Indent(&indented, rawJSON, "", "  ")
+		if indentErr != nil {
+			return errors.Wrap(indentErr, "Failed to indent metric")
+		}
+		rawJSON = indented.Bytes()
+	}
+	_, writtenErr := io.WriteString(sink, (string)(rawJSON))
 	if writtenErr != nil {
-		fmt.Printf("ERROR: %#v", writtenErr)
+		return errors.Wrap(writtenErr, "Failed to write metric")
 	}
+	return nil
 }
 
-// Publish the metric to the logfile
-func (em *EmbeddedMetric) Publish(additionalProperties map[string]interface{}) {
-	em.PublishToSink(additionalProperties, os.Stdout)
+// Publish the metric to the logfile. See PublishToSink for the
+// conditions under which an error is returned.
+func (em *EmbeddedMetric) Publish(additionalProperties map[string]interface{}) error {
+	if em == nil {
+		return ErrNilEmbeddedMetric
+	}
+	return em.PublishToSink(additionalProperties, os.Stdout)
+}
+
+// PublishToContext publishes the metric to the io.Writer stored in the
+// context under ContextKeyMetricSink. If the context is nil or doesn't
+// provide a writer the metric is published to os.Stdout.
+func (em *EmbeddedMetric) PublishToContext(ctx context.Context,
+	additionalProperties map[string]interface{}) error {
+	var sink io.Writer = os.Stdout
+	if ctx != nil {
+		contextSink, contextSinkOk := ctx.Value(ContextKeyMetricSink).(io.Writer)
+		if contextSinkOk && contextSink != nil {
+			sink = contextSink
+		}
+	}
+	return em.PublishToSink(additionalProperties, sink)
+}
+
+// epochMillis returns the number of milliseconds since the Unix epoch,
+// which is the EMF Timestamp representation. The value is always 64 bits
+// so that it doesn't overflow on 32 bit platforms.
+func epochMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// emfPropertyValue returns the value to publish for a property, consulting
+// the EMFMarshaler interface if the value implements it
+func emfPropertyValue(key string, value interface{}) (interface{}, error) {
+	emfMarshaler, isEMFMarshaler := value.(EMFMarshaler)
+	if !isEMFMarshaler {
+		return value, nil
+	}
+	emfValue, emfValueErr := emfMarshaler.MarshalEMF()
+	if emfValueErr != nil {
+		return nil, errors.Wrapf(emfValueErr, "Failed to marshal property %s", key)
+	}
+	return emfValue, nil
 }
 
 // MarshalJSON is a custom marshaller to ensure that the marshalled
-// headers are always lowercase
+// headers are always lowercase.
+//
+// Each MetricDirective produces one CloudWatchMetrics element per
+// namespace. Metrics without a Namespace are grouped under the
+// directive's namespace, which is always the directive's first element.
+// Metrics that override the Namespace are grouped into additional
+// elements, sorted by namespace. Every element for a directive shares the
+// directive's DimensionSets.
 func (em *EmbeddedMetric) MarshalJSON() ([]byte, error) {
+	if em == nil {
+		return nil, ErrNilEmbeddedMetric
+	}
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	return em.marshalJSON()
+}
+
+// marshalJSON marshals the EmbeddedMetric. Callers must hold the lock.
+func (em *EmbeddedMetric) marshalJSON() ([]byte, error) {
 	/* From: https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/CloudWatch_Embedded_Metric_Format_Generation_CloudWatch_Agent.html
 
 	The logs must contain a log_group_name key that tells the agent which log group to use.
 
 	Each log event must be on a single line. In other words, a log event cannot contain the newline (\n) character.
 	*/
+	// The environment is read each time so that values set after the
+	// package is initialized are honored.
+	// Ref: https://docs.aws.amazon.com/lambda/latest/dg/lambda-environment-variables.html
 	jsonMap := map[string]interface{}{
-		"log_group_name": envMap["AWS_LAMBDA_LOG_GROUP_NAME"],
-		"log_steam_name": envMap["AWS_LAMBDA_LOG_STREAM_NAME"],
+		"log_group_name":  os.Getenv("AWS_LAMBDA_LOG_GROUP_NAME"),
+		"log_stream_name": os.Getenv("AWS_LAMBDA_LOG_STREAM_NAME"),
+	}
+	if em.logGroupName != "" {
+		jsonMap["log_group_name"] = em.logGroupName
+	}
+	if em.logStreamName != "" {
+		jsonMap["log_stream_name"] = em.logStreamName
 	}
 	for eachKey, eachValue := range em.properties {
-		jsonMap[eachKey] = eachValue
+		propertyValue, propertyValueErr := emfPropertyValue(eachKey, eachValue)
+		if propertyValueErr != nil {
+			return nil, propertyValueErr
+		}
+		jsonMap[eachKey] = propertyValue
+	}
+	for _, eachDirective := range em.metrics {
+		for eachKey, eachValue := range eachDirective.properties {
+			propertyValue, propertyValueErr := emfPropertyValue(eachKey, eachValue)
+			if propertyValueErr != nil {
+				return nil, propertyValueErr
+			}
+			jsonMap[eachKey] = propertyValue
+		}
 	}
 	// Walk everything and create the references...
+	timestamp := em.timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
 	cwMetrics := &emfAWS{
-		Timestamp:         int((time.Now().UnixNano() / int64(time.Millisecond))),
+		Timestamp:         epochMillis(timestamp),
 		CloudWatchMetrics: []emfAWSCloudWatchMetricsElem{},
 	}
 	for _, eachDirective := range em.metrics {
-		metricsElem := emfAWSCloudWatchMetricsElem{
-			Dimensions: [][]string{},
-			Namespace:  eachDirective.namespace,
-			Metrics:    []emfAWSCloudWatchMetricsElemMetricsElem{},
+		dimensionSets := em.directiveDimensionSets(eachDirective)
+		namespaceElems := make(map[string]*emfAWSCloudWatchMetricsElem)
+		namespaceElem := func(namespace string) *emfAWSCloudWatchMetricsElem {
+			metricsElem, exists := namespaceElems[namespace]
+			if !exists {
+				metricsElem = &emfAWSCloudWatchMetricsElem{
+					Dimensions: append([][]string{}, dimensionSets...),
+					Namespace:  namespace,
+					Metrics:    []emfAWSCloudWatchMetricsElemMetricsElem{},
+				}
+				namespaceElems[namespace] = metricsElem
+			}
+			return metricsElem
 		}
+		// The directive namespace is always emitted, even without metrics
+		namespaceElem(eachDirective.namespace)
 
-		// Create the references and update the metrics...
-		for eachKey, eachMetric := range eachDirective.Metrics {
+		// Create the references and update the metrics. The metric
+		// definitions are sorted by name so that the output is stable.
+		metricNames := make([]string, 0, len(eachDirective.Metrics))
+		for eachKey := range eachDirective.Metrics {
+			metricNames = append(metricNames, eachKey)
+		}
+		sort.Strings(metricNames)
+		for _, eachKey := range metricNames {
+			eachMetric := eachDirective.Metrics[eachKey]
+			metricsElem := namespaceElem(eachDirective.metricNamespace(eachMetric))
 			jsonMap[eachKey] = eachMetric.Value
-			metricsElem.Metrics = append(metricsElem.Metrics,
-				emfAWSCloudWatchMetricsElemMetricsElem{
-					Name: eachKey,
-					Unit: string(eachMetric.Unit),
-				})
+			if eachMetric.Counts != nil {
+				jsonMap[metricCountsKey(eachKey)] = eachMetric.Counts
+			}
+			// CloudWatch treats a missing unit as None, so say so
+			// explicitly rather than emitting an empty Unit
+			metricUnit := eachMetric.Unit
+			if metricUnit == "" {
+				metricUnit = UnitNone
+			}
+			metricDefinition := emfAWSCloudWatchMetricsElemMetricsElem{
+				Name: eachKey,
+				Unit: string(metricUnit),
+			}
+			if eachMetric.StorageResolution != 0 {
+				storageResolution := eachMetric.StorageResolution
+				metricDefinition.StorageResolution = &storageResolution
+			}
+			metricsElem.Metrics = append(metricsElem.Metrics, metricDefinition)
 		}
-		for eachKey, eachValue := range eachDirective.Dimensions {
+		for eachKey, eachValue := range em.directiveDimensions(eachDirective) {
 			jsonMap[eachKey] = eachValue
-			metricsElem.Dimensions = append(metricsElem.Dimensions,
-				[]string{eachKey})
 		}
 		cwMetrics.CloudWatchMetrics = append(cwMetrics.CloudWatchMetrics,
-			metricsElem)
+			*namespaceElems[eachDirective.namespace])
+		delete(namespaceElems, eachDirective.namespace)
+		overrideNamespaces := make([]string, 0, len(namespaceElems))
+		for eachNamespace := range namespaceElems {
+			overrideNamespaces = append(overrideNamespaces, eachNamespace)
+		}
+		sort.Strings(overrideNamespaces)
+		for _, eachNamespace := range overrideNamespaces {
+			cwMetrics.CloudWatchMetrics = append(cwMetrics.CloudWatchMetrics,
+				*namespaceElems[eachNamespace])
+		}
 	}
 	jsonMap["_aws"] = cwMetrics
 	return json.Marshal(jsonMap)
@@ -247,3 +1403,114 @@ func NewEmbeddedMetricWithProperties(props map[string]interface{}) (*EmbeddedMet
 	}
 	return embeddedMetric, nil
 }
+
+// NewDisabledEmbeddedMetric returns an EmbeddedMetric whose Publish,
+// PublishToSink and PublishToContext functions are no-ops. The fluent
+// builders behave as usual so that instrumented code can run in unit
+// tests or local development without emitting EMF records.
+func NewDisabledEmbeddedMetric() (*EmbeddedMetric, error) {
+	embeddedMetric, embeddedMetricErr := NewEmbeddedMetric()
+	if embeddedMetricErr != nil {
+		return nil, embeddedMetricErr
+	}
+	embeddedMetric.disabled = true
+	return embeddedMetric, nil
+}
+
+// Gauge records the most recent reading of a value, such as a queue
+// depth, and publishes it each time it's flushed. A TTL can be set so
+// that a reading isn't reported indefinitely after updates stop. A Gauge
+// is safe for concurrent use.
+type Gauge struct {
+	mu         sync.Mutex
+	namespace  string
+	dimensions map[string]string
+	name       string
+	unit       MetricUnit
+	ttl        time.Duration
+	now        func() time.Time
+	value      float64
+	lastSet    time.Time
+	hasValue   bool
+}
+
+// NewGauge returns a Gauge that publishes the named metric in the
+// namespace with the given dimensions and unit
+func NewGauge(namespace string,
+	dims map[string]string,
+	name string,
+	unit MetricUnit) *Gauge {
+	dimensions := make(map[string]string)
+	for eachKey, eachValue := range dims {
+		dimensions[eachKey] = eachValue
+	}
+	return &Gauge{
+		namespace:  namespace,
+		dimensions: dimensions,
+		name:       name,
+		unit:       unit,
+		now:        time.Now,
+	}
+}
+
+// WithTTL suppresses publishing a reading once ttl has elapsed since the
+// last Set. A zero ttl, the default, publishes the last reading forever.
+func (g *Gauge) WithTTL(ttl time.Duration) *Gauge {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.ttl = ttl
+	return g
+}
+
+// WithClock replaces the time source used to track Set calls and to
+// timestamp published records. It's intended for tests.
+func (g *Gauge) WithClock(now func() time.Time) *Gauge {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.now = now
+	return g
+}
+
+// Set records the current reading
+func (g *Gauge) Set(value float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.value = value
+	g.lastSet = g.now()
+	g.hasValue = true
+}
+
+// Flush publishes the last reading to the sink as a single EMF record
+// followed by a newline. Nothing is written if Set hasn't been called or
+// if the reading is older than the TTL.
+func (g *Gauge) Flush(sink io.Writer) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.hasValue {
+		return nil
+	}
+	flushTime := g.now()
+	if g.ttl > 0 && flushTime.Sub(g.lastSet) > g.ttl {
+		return nil
+	}
+	emMetric, emMetricErr := NewEmbeddedMetric()
+	if emMetricErr != nil {
+		return emMetricErr
+	}
+	emMetric.WithTimestamp(flushTime)
+	metricDirective := emMetric.NewMetricDirective(g.namespace, g.dimensions)
+	metricDirective.Metrics[g.name] = MetricValue{
+		Value: g.value,
+		Unit:  g.unit,
+	}
+	publishErr := emMetric.PublishToSink(nil, sink)
+	if publishErr != nil {
+		return errors.Wrapf(publishErr, "Failed to flush gauge %s", g.name)
+	}
+	_, writeErr := io.WriteString(sink, "\n")
+	if writeErr != nil {
+		return errors.Wrap(writeErr, "Failed to write metric")
+	}
+	return nil
+}
@@ -2,10 +2,21 @@ package cloudwatch
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/pkg/errors"
 	"github.com/xeipuuv/gojsonschema"
 )
 
@@ -41,3 +52,1456 @@ func TestStructuredMetric(t *testing.T) {
 	})
 	ensureValidMetric(t, emMetric)
 }
+
+func TestStructuredMetricNilValue(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	metricDirective.Metrics["unset"] = MetricValue{
+		Unit: UnitCount,
+	}
+	sink := &bytes.Buffer{}
+	publishErr := emMetric.PublishToSink(nil, sink)
+	if publishErr == nil {
+		t.Fatalf("Expected error for nil metric Value")
+	}
+	if !strings.Contains(publishErr.Error(), "unset") {
+		t.Fatalf("Expected error to name the nil metric: %s", publishErr)
+	}
+	if sink.Len() != 0 {
+		t.Fatalf("Expected no output for invalid metric. Found: %s", sink.String())
+	}
+}
+
+func TestStructuredMetricZeroAndNegativeValues(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	metricDirective.Metrics["zero"] = MetricValue{
+		Unit:  UnitCount,
+		Value: 0,
+	}
+	metricDirective.Metrics["negative"] = MetricValue{
+		Unit:  UnitNone,
+		Value: -5,
+	}
+	sink := &bytes.Buffer{}
+	publishErr := emMetric.PublishToSink(nil, sink)
+	if publishErr != nil {
+		t.Fatalf("Failed to publish metric: %s", publishErr)
+	}
+	var published map[string]interface{}
+	unmarshalErr := json.Unmarshal(sink.Bytes(), &published)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal published metric: %s", unmarshalErr)
+	}
+	expected := map[string]float64{
+		"zero":     0,
+		"negative": -5,
+	}
+	for eachName, eachValue := range expected {
+		publishedValue, exists := published[eachName]
+		if !exists {
+			t.Fatalf("Expected metric %s to be emitted", eachName)
+		}
+		if publishedValue != eachValue {
+			t.Fatalf("Expected metric %s value %v, got %v", eachName, eachValue, publishedValue)
+		}
+	}
+}
+
+type testEMFOrder struct {
+	OrderID  string
+	Customer string
+	Secret   string
+}
+
+func (order *testEMFOrder) MarshalEMF() (interface{}, error) {
+	return fmt.Sprintf("%s/%s", order.Customer, order.OrderID), nil
+}
+
+func TestStructuredMetricEMFMarshaler(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	emMetric.WithProperty("order", &testEMFOrder{
+		OrderID:  "42",
+		Customer: "acme",
+		Secret:   "hidden",
+	})
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	metricDirective.Metrics["orders"] = MetricValue{
+		Unit:  UnitCount,
+		Value: 1,
+	}
+	sink := &bytes.Buffer{}
+	publishErr := emMetric.PublishToSink(nil, sink)
+	if publishErr != nil {
+		t.Fatalf("Failed to publish metric: %s", publishErr)
+	}
+	var published map[string]interface{}
+	unmarshalErr := json.Unmarshal(sink.Bytes(), &published)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal published metric: %s", unmarshalErr)
+	}
+	if published["order"] != "acme/42" {
+		t.Fatalf("Expected MarshalEMF representation. Found: %v", published["order"])
+	}
+}
+
+func TestStructuredMetricDefaultDimensions(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	emMetric.WithDefaultDimensions(map[string]string{
+		"Environment": "prod",
+		"Service":     "default",
+	})
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace",
+		map[string]string{"Service": "orders"})
+	metricDirective.Metrics["orders"] = MetricValue{
+		Unit:  UnitCount,
+		Value: 1,
+	}
+	rawJSON, rawJSONErr := json.Marshal(emMetric)
+	if rawJSONErr != nil {
+		t.Fatalf("Failed to marshal metric: %s", rawJSONErr)
+	}
+	var published struct {
+		Environment string
+		Service     string
+		AWS         emfAWS `json:"_aws"`
+	}
+	unmarshalErr := json.Unmarshal(rawJSON, &published)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal metric: %s", unmarshalErr)
+	}
+	if published.Environment != "prod" {
+		t.Fatalf("Expected inherited Environment dimension. Found: %s", published.Environment)
+	}
+	if published.Service != "orders" {
+		t.Fatalf("Expected directive Service dimension to override default. Found: %s", published.Service)
+	}
+	dimensionCount := 0
+	for _, eachSet := range published.AWS.CloudWatchMetrics[0].Dimensions {
+		dimensionCount += len(eachSet)
+	}
+	if dimensionCount != 2 {
+		t.Fatalf("Expected 2 merged dimensions. Found: %d", dimensionCount)
+	}
+}
+
+func TestStructuredMetricHeartbeat(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	emMetric.Heartbeat("SpecialNamespace",
+		map[string]string{"functionVersion": "23"},
+		"alive")
+	rawJSON, rawJSONErr := json.Marshal(emMetric)
+	if rawJSONErr != nil {
+		t.Fatalf("Failed to marshal metric: %s", rawJSONErr)
+	}
+	var published struct {
+		Alive *float64 `json:"alive"`
+		AWS   emfAWS   `json:"_aws"`
+	}
+	unmarshalErr := json.Unmarshal(rawJSON, &published)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal metric: %s", unmarshalErr)
+	}
+	if published.Alive == nil || *published.Alive != 0 {
+		t.Fatalf("Expected zero valued heartbeat metric: %s", string(rawJSON))
+	}
+	metricDefinitions := published.AWS.CloudWatchMetrics[0].Metrics
+	if len(metricDefinitions) != 1 ||
+		metricDefinitions[0].Name != "alive" ||
+		metricDefinitions[0].Unit != string(UnitCount) {
+		t.Fatalf("Expected heartbeat Count metric definition. Found: %#v", metricDefinitions)
+	}
+	ensureValidMetric(t, emMetric)
+}
+
+func TestStructuredMetricPrettyOutput(t *testing.T) {
+	for _, eachPretty := range []bool{false, true} {
+		emMetric, _ := NewEmbeddedMetric()
+		if eachPretty {
+			emMetric.WithPrettyOutput()
+		}
+		metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+		metricDirective.Metrics["invocations"] = MetricValue{
+			Unit:  UnitCount,
+			Value: 1,
+		}
+		sink := &bytes.Buffer{}
+		publishErr := emMetric.PublishToSink(nil, sink)
+		if publishErr != nil {
+			t.Fatalf("Failed to publish metric: %s", publishErr)
+		}
+		isIndented := strings.Contains(sink.String(), "\n  ")
+		if isIndented != eachPretty {
+			t.Fatalf("Expected indented output: %t. Found: %s", eachPretty, sink.String())
+		}
+	}
+}
+
+func TestStructuredMetricEstimatedDataPoints(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	multiDimension := emMetric.NewMetricDirective("SpecialNamespace",
+		map[string]string{
+			"Service":   "orders",
+			"Operation": "create",
+			"Region":    "us-west-2",
+		})
+	multiDimension.Metrics["latency"] = MetricValue{Unit: UnitMilliseconds, Value: 12}
+	multiDimension.Metrics["errors"] = MetricValue{Unit: UnitCount, Value: 0}
+
+	noDimensions := emMetric.NewMetricDirective("OtherNamespace", nil)
+	noDimensions.Metrics["invocations"] = MetricValue{Unit: UnitCount, Value: 1}
+
+	// 2 metrics * 3 dimension sets + 1 metric * 1 implicit set
+	if dataPoints := emMetric.EstimatedDataPoints(); dataPoints != 7 {
+		t.Fatalf("Expected 7 estimated data points. Found: %d", dataPoints)
+	}
+}
+
+func TestStructuredMetricDimensionValue(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil).
+		WithDimensionValue("functionVersion", 23).
+		WithDimensionValue("coldStart", true).
+		WithDimensionValue("memoryRatio", 0.25).
+		WithDimensionValue("timeout", time.Second)
+	expected := map[string]string{
+		"functionVersion": "23",
+		"coldStart":       "true",
+		"memoryRatio":     "0.25",
+		"timeout":         "1s",
+	}
+	for eachKey, eachValue := range expected {
+		if metricDirective.Dimensions[eachKey] != eachValue {
+			t.Errorf("Expected dimension %s to be %s. Found: %s",
+				eachKey,
+				eachValue,
+				metricDirective.Dimensions[eachKey])
+		}
+	}
+}
+
+type testFailingSink struct{}
+
+func (sink *testFailingSink) Write(p []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestStructuredMetricPublishErrors(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	metricDirective.Metrics["invocations"] = MetricValue{
+		Unit:  UnitCount,
+		Value: 1,
+	}
+	writeErr := emMetric.PublishToSink(nil, &testFailingSink{})
+	if writeErr == nil || !strings.Contains(writeErr.Error(), io.ErrClosedPipe.Error()) {
+		t.Fatalf("Expected sink write error to be returned. Found: %v", writeErr)
+	}
+	sink := &bytes.Buffer{}
+	marshalErr := emMetric.PublishToSink(map[string]interface{}{
+		"unsupported": make(chan int),
+	}, sink)
+	if marshalErr == nil {
+		t.Fatalf("Expected marshal error to be returned")
+	}
+	if sink.Len() != 0 {
+		t.Fatalf("Expected no output for marshal failure. Found: %s", sink.String())
+	}
+}
+
+// setTestEnv sets the environment variable and returns a func that
+// restores the original value
+func setTestEnv(t *testing.T, key string, value string) func() {
+	originalValue, originalExists := os.LookupEnv(key)
+	setErr := os.Setenv(key, value)
+	if setErr != nil {
+		t.Fatalf("Failed to set %s: %s", key, setErr)
+	}
+	return func() {
+		if originalExists {
+			os.Setenv(key, originalValue)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestStructuredMetricLogNames(t *testing.T) {
+	// Set after the package is initialized
+	defer setTestEnv(t, "AWS_LAMBDA_LOG_GROUP_NAME", "/aws/lambda/versions")()
+	defer setTestEnv(t,
+		"AWS_LAMBDA_LOG_STREAM_NAME",
+		"2016/02/20/[$LATEST]5efb6fc38f214f89827218367e12b37b")()
+
+	emMetric, _ := NewEmbeddedMetric()
+	rawJSON, rawJSONErr := json.Marshal(emMetric)
+	if rawJSONErr != nil {
+		t.Fatalf("Failed to marshal metric: %s", rawJSONErr)
+	}
+	var published map[string]interface{}
+	unmarshalErr := json.Unmarshal(rawJSON, &published)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal metric: %s", unmarshalErr)
+	}
+	if published["log_group_name"] != "/aws/lambda/versions" {
+		t.Fatalf("Expected log_group_name. Found: %v", published["log_group_name"])
+	}
+	if published["log_stream_name"] != "2016/02/20/[$LATEST]5efb6fc38f214f89827218367e12b37b" {
+		t.Fatalf("Expected log_stream_name. Found: %v", published["log_stream_name"])
+	}
+}
+
+func publishedProperties(t *testing.T, emMetric *EmbeddedMetric) map[string]interface{} {
+	rawJSON, rawJSONErr := json.Marshal(emMetric)
+	if rawJSONErr != nil {
+		t.Fatalf("Failed to marshal metric: %s", rawJSONErr)
+	}
+	var published map[string]interface{}
+	unmarshalErr := json.Unmarshal(rawJSON, &published)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal metric: %s", unmarshalErr)
+	}
+	return published
+}
+
+func TestStructuredMetricAWSContext(t *testing.T) {
+	defer setTestEnv(t, "AWS_REGION", "eu-west-1")()
+
+	ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{
+		InvokedFunctionArn: "arn:aws:lambda:eu-west-1:123456789012:function:myFunction",
+	})
+	emMetric, _ := NewEmbeddedMetric()
+	published := publishedProperties(t, emMetric.WithAWSContext(ctx))
+	if published[AWSRegionProperty] != "eu-west-1" {
+		t.Fatalf("Expected %s property. Found: %v", AWSRegionProperty, published[AWSRegionProperty])
+	}
+	if published[AWSAccountIDProperty] != "123456789012" {
+		t.Fatalf("Expected %s property. Found: %v", AWSAccountIDProperty, published[AWSAccountIDProperty])
+	}
+}
+
+func TestStructuredMetricAWSContextOutsideLambda(t *testing.T) {
+	defer setTestEnv(t, "AWS_REGION", "")()
+
+	emMetric, _ := NewEmbeddedMetric()
+	published := publishedProperties(t, emMetric.WithAWSContext(context.Background()))
+	for _, eachProperty := range []string{AWSRegionProperty, AWSAccountIDProperty} {
+		if _, exists := published[eachProperty]; exists {
+			t.Fatalf("Expected %s to be omitted outside Lambda. Found: %v",
+				eachProperty,
+				published[eachProperty])
+		}
+	}
+}
+
+func TestMetricDirectiveTypedHelpers(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil).
+		Count("invocations", 3).
+		Milliseconds("latency", 1500*time.Microsecond).
+		Bytes("payload", 2048)
+
+	expected := map[string]MetricValue{
+		"invocations": {Value: float64(3), Unit: UnitCount},
+		"latency":     {Value: 1.5, Unit: UnitMilliseconds},
+		"payload":     {Value: int64(2048), Unit: UnitBytes},
+	}
+	for eachName, eachExpected := range expected {
+		actual := metricDirective.Metrics[eachName]
+		if actual.Unit != eachExpected.Unit || actual.Value != eachExpected.Value {
+			t.Fatalf("Expected %s to be %#v. Found: %#v", eachName, eachExpected, actual)
+		}
+	}
+	ensureValidMetric(t, emMetric)
+}
+
+func TestMetricDirectiveStartTimer(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	stopTimer := metricDirective.StartTimer("latency")
+	if _, exists := metricDirective.Metrics["latency"]; exists {
+		t.Fatalf("Expected latency to be recorded only when the timer is stopped")
+	}
+	sleepDuration := 50 * time.Millisecond
+	time.Sleep(sleepDuration)
+	stopTimer()
+
+	latency := metricDirective.Metrics["latency"]
+	if latency.Unit != UnitMilliseconds {
+		t.Fatalf("Expected %s unit. Found: %s", UnitMilliseconds, latency.Unit)
+	}
+	elapsed, elapsedOk := latency.Value.(float64)
+	if !elapsedOk {
+		t.Fatalf("Expected float64 latency. Found: %#v", latency.Value)
+	}
+	// Allow generous scheduling slack on busy CI hosts
+	if elapsed < 50 || elapsed > 1000 {
+		t.Fatalf("Expected latency of ~50ms. Found: %fms", elapsed)
+	}
+}
+
+func TestMetricDirectiveAdd(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	metricDirective.Add("retries", 1).
+		Add("retries", 2).
+		Add("retries", 3)
+	metricDirective.AddWithUnit("bytesRead", 512, UnitBytes).
+		Add("bytesRead", 256)
+
+	published := publishedProperties(t, emMetric)
+	if published["retries"] != float64(6) {
+		t.Fatalf("Expected summed retries of 6. Found: %v", published["retries"])
+	}
+	if published["bytesRead"] != float64(768) {
+		t.Fatalf("Expected summed bytesRead of 768. Found: %v", published["bytesRead"])
+	}
+	if metricDirective.Metrics["bytesRead"].Unit != UnitBytes {
+		t.Fatalf("Expected Add to preserve the unit. Found: %s",
+			metricDirective.Metrics["bytesRead"].Unit)
+	}
+	ensureValidMetric(t, emMetric)
+}
+
+func TestMetricDirectiveAddAggregates(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	metricDirective.Metrics["latency"] = MetricValue{
+		Value:  []float64{10, 20},
+		Counts: []float64{3, 1},
+		Unit:   UnitMilliseconds,
+	}
+	metricDirective.Metrics["payload"] = MetricValue{
+		Value: StatisticSet{SampleCount: 2, Sum: 30, Minimum: 10, Maximum: 20},
+		Unit:  UnitBytes,
+	}
+	metricDirective.Metrics["emptySet"] = MetricValue{
+		Value: &StatisticSet{},
+		Unit:  UnitBytes,
+	}
+	metricDirective.Add("latency", 5).
+		Add("payload", 40).
+		Add("payload", 5).
+		Add("emptySet", 7)
+
+	latency := metricDirective.Metrics["latency"]
+	if !reflect.DeepEqual(latency.Value, []float64{10, 20, 5}) ||
+		!reflect.DeepEqual(latency.Counts, []float64{3, 1, 1}) {
+		t.Fatalf("Expected Add to append to the array. Found: %#v", latency)
+	}
+	expectedPayload := StatisticSet{SampleCount: 4, Sum: 75, Minimum: 5, Maximum: 40}
+	if metricDirective.Metrics["payload"].Value != expectedPayload {
+		t.Fatalf("Expected Add to fold into the StatisticSet. Found: %#v",
+			metricDirective.Metrics["payload"].Value)
+	}
+	expectedEmptySet := StatisticSet{SampleCount: 1, Sum: 7, Minimum: 7, Maximum: 7}
+	if metricDirective.Metrics["emptySet"].Value != expectedEmptySet {
+		t.Fatalf("Expected Add to fold into the empty StatisticSet. Found: %#v",
+			metricDirective.Metrics["emptySet"].Value)
+	}
+	if metricDirective.Metrics["payload"].Unit != UnitBytes {
+		t.Fatalf("Expected Add to preserve the unit. Found: %s",
+			metricDirective.Metrics["payload"].Unit)
+	}
+	ensureValidMetric(t, emMetric)
+}
+
+func TestMetricDirectiveIncrement(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	records := []string{"ok", "failed", "ok", "ok", "failed"}
+	for _, eachRecord := range records {
+		metricDirective.Increment("processed")
+		if eachRecord == "failed" {
+			metricDirective.IncrementBy("failed", 1)
+		}
+	}
+	metricDirective.IncrementBy("processed", 0.5)
+
+	published := publishedProperties(t, emMetric)
+	if published["processed"] != float64(5.5) {
+		t.Fatalf("Expected processed count of 5.5. Found: %v", published["processed"])
+	}
+	if published["failed"] != float64(2) {
+		t.Fatalf("Expected failed count of 2. Found: %v", published["failed"])
+	}
+	for _, eachName := range []string{"processed", "failed"} {
+		if metricDirective.Metrics[eachName].Unit != UnitCount {
+			t.Fatalf("Expected %s to be a UnitCount metric. Found: %s",
+				eachName,
+				metricDirective.Metrics[eachName].Unit)
+		}
+	}
+	ensureValidMetric(t, emMetric)
+}
+
+func TestEmbeddedMetricAccessors(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	emMetric.WithProperty("requestID", "96f98a63")
+	firstDirective := emMetric.NewMetricDirective("FirstNamespace", nil)
+	secondDirective := emMetric.NewMetricDirective("SecondNamespace", nil)
+
+	directives := emMetric.Directives()
+	if len(directives) != 2 ||
+		directives[0] != firstDirective ||
+		directives[1] != secondDirective {
+		t.Fatalf("Expected directives in creation order. Found: %#v", directives)
+	}
+	if directives[0].Namespace() != "FirstNamespace" ||
+		directives[1].Namespace() != "SecondNamespace" {
+		t.Fatalf("Unexpected namespaces: %s, %s",
+			directives[0].Namespace(),
+			directives[1].Namespace())
+	}
+	// Modifying the returned slice doesn't change the EmbeddedMetric
+	directives[0] = nil
+	if emMetric.Directives()[0] != firstDirective {
+		t.Fatalf("Expected Directives to return a copy")
+	}
+
+	properties := emMetric.Properties()
+	if properties["requestID"] != "96f98a63" {
+		t.Fatalf("Expected requestID property. Found: %v", properties["requestID"])
+	}
+	// Modifying the returned map doesn't change the EmbeddedMetric
+	properties["requestID"] = "modified"
+	if emMetric.Properties()["requestID"] != "96f98a63" {
+		t.Fatalf("Expected Properties to return a copy")
+	}
+}
+
+func TestMetricDirectiveSetNamespace(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("PendingNamespace", nil).
+		Count("invocations", 1).
+		SetNamespace("ConfiguredNamespace")
+	if metricDirective.Namespace() != "ConfiguredNamespace" {
+		t.Fatalf("Expected updated namespace. Found: %s", metricDirective.Namespace())
+	}
+	var sink bytes.Buffer
+	publishErr := emMetric.PublishToSink(nil, &sink)
+	if publishErr != nil {
+		t.Fatalf("Failed to publish: %s", publishErr)
+	}
+	var record emf
+	unmarshalErr := json.Unmarshal(sink.Bytes(), &record)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal metric: %s", unmarshalErr)
+	}
+	if record.AWS.CloudWatchMetrics[0].Namespace != "ConfiguredNamespace" {
+		t.Fatalf("Expected marshalled namespace ConfiguredNamespace. Found: %s",
+			record.AWS.CloudWatchMetrics[0].Namespace)
+	}
+
+	// Invalid namespaces are rejected at publish time
+	metricDirective.SetNamespace("Invalid Namespace!")
+	if publishErr := emMetric.PublishToSink(nil, &sink); publishErr == nil {
+		t.Fatalf("Expected invalid namespace to be rejected")
+	}
+}
+
+func TestEmbeddedMetricWithProperties(t *testing.T) {
+	emMetric := &EmbeddedMetric{}
+	emMetric.WithProperty("override", "single").
+		WithProperties(map[string]interface{}{
+			"override":  "firstBulk",
+			"requestID": "96f98a63",
+		}).
+		WithProperties(map[string]interface{}{
+			"override": "secondBulk",
+			"stage":    "prod",
+		})
+	expected := map[string]interface{}{
+		"override":  "secondBulk",
+		"requestID": "96f98a63",
+		"stage":     "prod",
+	}
+	properties := emMetric.Properties()
+	if len(properties) != len(expected) {
+		t.Fatalf("Expected %d properties. Found: %#v", len(expected), properties)
+	}
+	for eachKey, eachValue := range expected {
+		if properties[eachKey] != eachValue {
+			t.Fatalf("Expected %s=%v. Found: %v", eachKey, eachValue, properties[eachKey])
+		}
+	}
+}
+
+func TestNilEmbeddedMetric(t *testing.T) {
+	var emMetric *EmbeddedMetric
+	if emMetric.WithProperty("requestID", "96f98a63") != nil {
+		t.Fatalf("Expected WithProperty on nil EmbeddedMetric to return nil")
+	}
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	if metricDirective == nil {
+		t.Fatalf("Expected NewMetricDirective on nil EmbeddedMetric to return a directive")
+	}
+	metricDirective.Count("invocations", 1)
+
+	if publishErr := emMetric.Publish(nil); publishErr != ErrNilEmbeddedMetric {
+		t.Fatalf("Expected ErrNilEmbeddedMetric from Publish. Found: %v", publishErr)
+	}
+	var sink bytes.Buffer
+	if publishErr := emMetric.PublishToSink(nil, &sink); publishErr != ErrNilEmbeddedMetric {
+		t.Fatalf("Expected ErrNilEmbeddedMetric from PublishToSink. Found: %v", publishErr)
+	}
+	if publishErr := emMetric.PublishToContext(context.Background(), nil); publishErr != ErrNilEmbeddedMetric {
+		t.Fatalf("Expected ErrNilEmbeddedMetric from PublishToContext. Found: %v", publishErr)
+	}
+	if sink.Len() != 0 {
+		t.Fatalf("Expected nothing to be written. Found: %s", sink.String())
+	}
+}
+
+func TestNilEmbeddedMetricMethods(t *testing.T) {
+	var emMetric *EmbeddedMetric
+	builders := map[string]func() *EmbeddedMetric{
+		"WithValidation":        emMetric.WithValidation,
+		"WithLogGroup":          func() *EmbeddedMetric { return emMetric.WithLogGroup("group") },
+		"WithLogStream":         func() *EmbeddedMetric { return emMetric.WithLogStream("stream") },
+		"WithAWSContext":        func() *EmbeddedMetric { return emMetric.WithAWSContext(context.Background()) },
+		"WithTimestamp":         func() *EmbeddedMetric { return emMetric.WithTimestamp(time.Now()) },
+		"WithPrettyOutput":      emMetric.WithPrettyOutput,
+		"WithDefaultDimensions": func() *EmbeddedMetric { return emMetric.WithDefaultDimensions(map[string]string{"Stage": "prod"}) },
+		"WithProperty":          func() *EmbeddedMetric { return emMetric.WithProperty("key", "value") },
+		"WithProperties":        func() *EmbeddedMetric { return emMetric.WithProperties(map[string]interface{}{"key": "value"}) },
+	}
+	for eachName, eachBuilder := range builders {
+		if eachBuilder() != nil {
+			t.Fatalf("Expected %s on nil EmbeddedMetric to return nil", eachName)
+		}
+	}
+	if emMetric.Heartbeat("SpecialNamespace", nil, "heartbeat") == nil {
+		t.Fatalf("Expected Heartbeat on nil EmbeddedMetric to return a directive")
+	}
+	if emMetric.EstimatedDataPoints() != 0 {
+		t.Fatalf("Expected no data points for nil EmbeddedMetric")
+	}
+	if len(emMetric.Directives()) != 0 {
+		t.Fatalf("Expected no directives for nil EmbeddedMetric")
+	}
+	if len(emMetric.Properties()) != 0 {
+		t.Fatalf("Expected no properties for nil EmbeddedMetric")
+	}
+	if validateErrs := emMetric.Validate(); len(validateErrs) != 1 || validateErrs[0] != ErrNilEmbeddedMetric {
+		t.Fatalf("Expected ErrNilEmbeddedMetric from Validate. Found: %v", validateErrs)
+	}
+	if _, marshalErr := emMetric.MarshalJSON(); marshalErr != ErrNilEmbeddedMetric {
+		t.Fatalf("Expected ErrNilEmbeddedMetric from MarshalJSON. Found: %v", marshalErr)
+	}
+}
+
+func TestStructuredMetricStorageResolution(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	metricDirective.Metrics["latency"] = MetricValue{
+		Unit:              UnitMilliseconds,
+		Value:             12,
+		StorageResolution: StorageResolutionHigh,
+	}
+	metricDirective.Metrics["invocations"] = MetricValue{
+		Unit:  UnitCount,
+		Value: 1,
+	}
+	sink := &bytes.Buffer{}
+	publishErr := emMetric.PublishToSink(nil, sink)
+	if publishErr != nil {
+		t.Fatalf("Failed to publish metric: %s", publishErr)
+	}
+	var published emf
+	unmarshalErr := json.Unmarshal(sink.Bytes(), &published)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal metric: %s", unmarshalErr)
+	}
+	for _, eachDefinition := range published.AWS.CloudWatchMetrics[0].Metrics {
+		switch eachDefinition.Name {
+		case "latency":
+			if eachDefinition.StorageResolution == nil || *eachDefinition.StorageResolution != 1 {
+				t.Fatalf("Expected high resolution latency metric: %s", sink.String())
+			}
+		case "invocations":
+			if eachDefinition.StorageResolution != nil {
+				t.Fatalf("Expected default resolution to be omitted: %s", sink.String())
+			}
+		}
+	}
+	ensureValidMetric(t, emMetric)
+
+	metricDirective.Metrics["latency"] = MetricValue{
+		Unit:              UnitMilliseconds,
+		Value:             12,
+		StorageResolution: 30,
+	}
+	invalidErr := emMetric.PublishToSink(nil, &bytes.Buffer{})
+	if invalidErr == nil {
+		t.Fatalf("Expected invalid StorageResolution to be rejected")
+	}
+}
+
+func TestStructuredMetricArrayValues(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	metricDirective.Metrics["latency"] = MetricValue{
+		Unit:   UnitMilliseconds,
+		Value:  []float64{10, 20, 30},
+		Counts: []float64{5, 1, 2},
+	}
+	sink := &bytes.Buffer{}
+	publishErr := emMetric.PublishToSink(nil, sink)
+	if publishErr != nil {
+		t.Fatalf("Failed to publish metric: %s", publishErr)
+	}
+	var published struct {
+		Latency       []float64 `json:"latency"`
+		LatencyCounts []float64 `json:"latencyCounts"`
+	}
+	unmarshalErr := json.Unmarshal(sink.Bytes(), &published)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal metric: %s", unmarshalErr)
+	}
+	if len(published.Latency) != 3 || len(published.LatencyCounts) != 3 ||
+		published.LatencyCounts[0] != 5 {
+		t.Fatalf("Expected array values and counts: %s", sink.String())
+	}
+	ensureValidMetric(t, emMetric)
+
+	metricDirective.Metrics["latency"] = MetricValue{
+		Unit:   UnitMilliseconds,
+		Value:  []float64{10, 20, 30},
+		Counts: []float64{5, 1},
+	}
+	mismatchErr := emMetric.PublishToSink(nil, &bytes.Buffer{})
+	if mismatchErr == nil {
+		t.Fatalf("Expected mismatched Counts length to be rejected")
+	}
+}
+
+func TestStructuredMetricTooManyMetrics(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	for i := 0; i != 101; i++ {
+		metricDirective.Metrics[fmt.Sprintf("metric%d", i)] = MetricValue{
+			Unit:  UnitCount,
+			Value: i,
+		}
+	}
+	publishErr := emMetric.PublishToSink(nil, &bytes.Buffer{})
+	if errors.Cause(publishErr) != ErrTooManyMetrics {
+		t.Fatalf("Expected ErrTooManyMetrics. Found: %v", publishErr)
+	}
+	delete(metricDirective.Metrics, "metric100")
+	publishErr = emMetric.PublishToSink(nil, &bytes.Buffer{})
+	if publishErr != nil {
+		t.Fatalf("Expected 100 metrics to be accepted. Found: %v", publishErr)
+	}
+}
+
+func TestStructuredMetricTooManyDimensions(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	emMetric.WithDefaultDimensions(map[string]string{"Environment": "prod"})
+	dimensions := make(map[string]string)
+	for i := 0; i != 9; i++ {
+		dimensions[fmt.Sprintf("dimension%d", i)] = "value"
+	}
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", dimensions)
+	metricDirective.Metrics["invocations"] = MetricValue{
+		Unit:  UnitCount,
+		Value: 1,
+	}
+	sink := &bytes.Buffer{}
+	publishErr := emMetric.PublishToSink(nil, sink)
+	if errors.Cause(publishErr) != ErrTooManyDimensions {
+		t.Fatalf("Expected ErrTooManyDimensions. Found: %v", publishErr)
+	}
+	if sink.Len() != 0 {
+		t.Fatalf("Expected no output for invalid metric. Found: %s", sink.String())
+	}
+}
+
+func TestStructuredMetricTimestamp(t *testing.T) {
+	replayTime := time.Date(2016, 2, 20, 3, 18, 19, 356000000, time.UTC)
+	emMetric, _ := NewEmbeddedMetric()
+	emMetric.WithTimestamp(replayTime)
+	rawJSON, rawJSONErr := json.Marshal(emMetric)
+	if rawJSONErr != nil {
+		t.Fatalf("Failed to marshal metric: %s", rawJSONErr)
+	}
+	var published emf
+	unmarshalErr := json.Unmarshal(rawJSON, &published)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal metric: %s", unmarshalErr)
+	}
+	if published.AWS.Timestamp != 1455938299356 {
+		t.Fatalf("Expected supplied timestamp. Found: %d", published.AWS.Timestamp)
+	}
+}
+
+func TestStructuredMetricConcurrentUse(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	var wg sync.WaitGroup
+	for i := 0; i != 50; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			emMetric.WithProperty(fmt.Sprintf("property%d", index), index)
+			emMetric.Heartbeat("SpecialNamespace",
+				map[string]string{"worker": fmt.Sprintf("%d", index)},
+				fmt.Sprintf("alive%d", index))
+			publishErr := emMetric.PublishToSink(nil, ioutil.Discard)
+			if publishErr != nil {
+				t.Errorf("Failed to publish metric: %s", publishErr)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if dataPoints := emMetric.EstimatedDataPoints(); dataPoints != 50 {
+		t.Fatalf("Expected 50 heartbeat data points. Found: %d", dataPoints)
+	}
+}
+
+func TestNewMetricValue(t *testing.T) {
+	metricValue, metricValueErr := NewMetricValue(42, UnitMilliseconds)
+	if metricValueErr != nil {
+		t.Fatalf("Expected valid unit to be accepted: %s", metricValueErr)
+	}
+	if metricValue.Value != 42 || metricValue.Unit != UnitMilliseconds {
+		t.Fatalf("Unexpected MetricValue: %#v", metricValue)
+	}
+	_, invalidErr := NewMetricValue(42, MetricUnit("Furlongs"))
+	if invalidErr == nil {
+		t.Fatalf("Expected invalid unit to be rejected")
+	}
+}
+
+func TestStructuredMetricPublishToContext(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	metricDirective.Metrics["invocations"] = MetricValue{
+		Unit:  UnitCount,
+		Value: 1,
+	}
+	sink := &bytes.Buffer{}
+	ctx := context.WithValue(context.Background(), ContextKeyMetricSink, sink)
+	publishErr := emMetric.PublishToContext(ctx, map[string]interface{}{
+		"requestID": "96f98a63-d780-11e5-ab78-69015eb2dceb",
+	})
+	if publishErr != nil {
+		t.Fatalf("Failed to publish metric: %s", publishErr)
+	}
+	if !strings.Contains(sink.String(), "96f98a63-d780-11e5-ab78-69015eb2dceb") {
+		t.Fatalf("Expected metric to be published to context sink. Found: %s", sink.String())
+	}
+}
+
+func TestStructuredMetricPublishToNilContext(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	emMetric.NewMetricDirective("SpecialNamespace", nil).Count("invocations", 1)
+	// A nil context falls back to os.Stdout rather than panicking
+	//lint:ignore SA1012 verifying the nil context fallback
+	publishErr := emMetric.PublishToContext(nil, nil)
+	if publishErr != nil {
+		t.Fatalf("Failed to publish metric: %s", publishErr)
+	}
+}
+
+func TestValidateNamespace(t *testing.T) {
+	testCases := []struct {
+		namespace string
+		valid     bool
+	}{
+		{"SpecialNamespace", true},
+		{"My/Service-Name_1.0 #prod:us-east-1", true},
+		{strings.Repeat("N", maxNamespaceLength), true},
+		{"", false},
+		{"   ", false},
+		{strings.Repeat("N", maxNamespaceLength+1), false},
+		{"Invalid*Namespace", false},
+		{"Namespäce", false},
+	}
+	for _, eachTestCase := range testCases {
+		validateErr := ValidateNamespace(eachTestCase.namespace)
+		if eachTestCase.valid && validateErr != nil {
+			t.Errorf("Expected namespace %q to be valid: %s", eachTestCase.namespace, validateErr)
+		}
+		if !eachTestCase.valid && validateErr == nil {
+			t.Errorf("Expected namespace %q to be invalid", eachTestCase.namespace)
+		}
+	}
+	// And the publish time check
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("", nil)
+	metricDirective.Metrics["invocations"] = MetricValue{
+		Unit:  UnitCount,
+		Value: 1,
+	}
+	var output bytes.Buffer
+	publishErr := emMetric.PublishToSink(nil, &output)
+	if publishErr == nil {
+		t.Fatalf("Expected error publishing metric with empty namespace")
+	}
+	if output.Len() != 0 {
+		t.Fatalf("Expected no output for invalid namespace. Found: %s", output.String())
+	}
+}
+
+func TestStructuredMetricStatisticSet(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	metricDirective.Metrics["latency"] = MetricValue{
+		Unit: UnitMilliseconds,
+		Value: StatisticSet{
+			SampleCount: 4,
+			Sum:         100,
+			Minimum:     10,
+			Maximum:     40,
+		},
+	}
+	var output bytes.Buffer
+	publishErr := emMetric.PublishToSink(nil, &output)
+	if publishErr != nil {
+		t.Fatalf("Failed to publish StatisticSet: %s", publishErr)
+	}
+	published := struct {
+		Latency map[string]float64 `json:"latency"`
+	}{}
+	unmarshalErr := json.Unmarshal(output.Bytes(), &published)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal metric: %s", unmarshalErr)
+	}
+	expected := map[string]float64{
+		"SampleCount": 4,
+		"Sum":         100,
+		"Minimum":     10,
+		"Maximum":     40,
+	}
+	for eachKey, eachValue := range expected {
+		if published.Latency[eachKey] != eachValue {
+			t.Fatalf("Expected StatisticSet %s to be %f. Found: %v",
+				eachKey,
+				eachValue,
+				published.Latency)
+		}
+	}
+
+	invalidSets := []StatisticSet{
+		{SampleCount: -1},
+		{SampleCount: 2, Sum: 10, Minimum: 8, Maximum: 2},
+		{SampleCount: 0, Sum: 10},
+	}
+	for _, eachSet := range invalidSets {
+		invalidMetric, _ := NewEmbeddedMetric()
+		invalidDirective := invalidMetric.NewMetricDirective("SpecialNamespace", nil)
+		invalidDirective.Metrics["latency"] = MetricValue{
+			Unit:  UnitMilliseconds,
+			Value: &eachSet,
+		}
+		invalidErr := invalidMetric.PublishToSink(nil, ioutil.Discard)
+		if invalidErr == nil {
+			t.Fatalf("Expected error for invalid StatisticSet: %#v", eachSet)
+		}
+	}
+}
+
+func TestEpochMillis(t *testing.T) {
+	testCases := []struct {
+		time     time.Time
+		expected int64
+	}{
+		{time.Unix(0, 0), 0},
+		{time.Unix(0, 999999), 0},
+		{time.Unix(1, 0), 1000},
+		{time.Date(2016, 2, 20, 3, 18, 19, 356000000, time.UTC), 1455938299356},
+		// Past the 32 bit signed millisecond range
+		{time.Date(2038, 1, 19, 3, 14, 8, 0, time.UTC), 2147483648000},
+	}
+	for _, eachTestCase := range testCases {
+		actual := epochMillis(eachTestCase.time)
+		if actual != eachTestCase.expected {
+			t.Errorf("Expected epochMillis(%s) to be %d. Found: %d",
+				eachTestCase.time,
+				eachTestCase.expected,
+				actual)
+		}
+	}
+}
+
+func TestStructuredMetricDirectiveProperties(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetricWithProperties(map[string]interface{}{
+		"shared":   "metric",
+		"override": "metric",
+	})
+	first := emMetric.NewMetricDirective("FirstNamespace", nil).
+		WithProperty("override", "first").
+		WithProperty("firstOnly", "first")
+	first.Metrics["invocations"] = MetricValue{
+		Unit:  UnitCount,
+		Value: 1,
+	}
+	second := emMetric.NewMetricDirective("SecondNamespace", nil).
+		WithProperty("override", "second")
+	second.Metrics["errors"] = MetricValue{
+		Unit:  UnitCount,
+		Value: 0,
+	}
+	var output bytes.Buffer
+	publishErr := emMetric.PublishToSink(nil, &output)
+	if publishErr != nil {
+		t.Fatalf("Failed to publish metric: %s", publishErr)
+	}
+	published := make(map[string]interface{})
+	unmarshalErr := json.Unmarshal(output.Bytes(), &published)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal metric: %s", unmarshalErr)
+	}
+	expected := map[string]string{
+		"shared":    "metric",
+		"firstOnly": "first",
+		"override":  "second",
+	}
+	for eachKey, eachValue := range expected {
+		if published[eachKey] != eachValue {
+			t.Fatalf("Expected property %s to be %s. Found: %v",
+				eachKey,
+				eachValue,
+				published[eachKey])
+		}
+	}
+}
+
+func TestStructuredMetricDimension(t *testing.T) {
+	dimensionNames := []string{"Service", "Operation", "Stage", "Region", "Account"}
+	for i := 0; i != 10; i++ {
+		emMetric, _ := NewEmbeddedMetric()
+		metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+		metricDirective.Metrics["invocations"] = MetricValue{
+			Unit:  UnitCount,
+			Value: 1,
+		}
+		for _, eachName := range dimensionNames {
+			dimensionErr := metricDirective.Dimension(eachName, "value")
+			if dimensionErr != nil {
+				t.Fatalf("Failed to add dimension %s: %s", eachName, dimensionErr)
+			}
+		}
+		rawJSON, rawJSONErr := json.Marshal(emMetric)
+		if rawJSONErr != nil {
+			t.Fatalf("Failed to marshal metric: %s", rawJSONErr)
+		}
+		var published emf
+		unmarshalErr := json.Unmarshal(rawJSON, &published)
+		if unmarshalErr != nil {
+			t.Fatalf("Failed to unmarshal metric: %s", unmarshalErr)
+		}
+		dimensionSets := published.AWS.CloudWatchMetrics[0].Dimensions
+		if len(dimensionSets) != len(dimensionNames) {
+			t.Fatalf("Expected %d DimensionSets. Found: %v", len(dimensionNames), dimensionSets)
+		}
+		for eachIndex, eachName := range dimensionNames {
+			if dimensionSets[eachIndex][0] != eachName {
+				t.Fatalf("Expected DimensionSet %d to be %s. Found: %v",
+					eachIndex,
+					eachName,
+					dimensionSets)
+			}
+		}
+	}
+
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	invalidDimensions := [][]string{
+		{"", "value"},
+		{"Service", ""},
+		{strings.Repeat("N", maxDimensionNameLength+1), "value"},
+		{"Service", strings.Repeat("V", maxDimensionValueLength+1)},
+		{"Sérvice", "value"},
+	}
+	for _, eachDimension := range invalidDimensions {
+		dimensionErr := metricDirective.Dimension(eachDimension[0], eachDimension[1])
+		if dimensionErr == nil {
+			t.Fatalf("Expected error for invalid dimension: %v", eachDimension)
+		}
+	}
+	if len(metricDirective.Dimensions) != 0 {
+		t.Fatalf("Expected invalid dimensions to be rejected. Found: %v", metricDirective.Dimensions)
+	}
+}
+
+func TestStructuredMetricDeterministicOutput(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	emMetric.WithTimestamp(time.Date(2016, 2, 20, 3, 18, 19, 356000000, time.UTC)).
+		WithDefaultDimensions(map[string]string{
+			"Stage":  "prod",
+			"Region": "us-east-1",
+		})
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", map[string]string{
+		"Service":   "sparta",
+		"Operation": "publish",
+		"Account":   "123456789012",
+	})
+	for _, eachName := range []string{"latency", "invocations", "errors", "throttles", "bytes"} {
+		metricDirective.Metrics[eachName] = MetricValue{
+			Unit:  UnitCount,
+			Value: 1,
+		}
+	}
+	firstJSON, firstJSONErr := json.Marshal(emMetric)
+	if firstJSONErr != nil {
+		t.Fatalf("Failed to marshal metric: %s", firstJSONErr)
+	}
+	for i := 0; i != 10; i++ {
+		nextJSON, nextJSONErr := json.Marshal(emMetric)
+		if nextJSONErr != nil {
+			t.Fatalf("Failed to marshal metric: %s", nextJSONErr)
+		}
+		if !bytes.Equal(firstJSON, nextJSON) {
+			t.Fatalf("Expected identical output.\nFirst: %s\nNext: %s", firstJSON, nextJSON)
+		}
+	}
+}
+
+func TestStructuredMetricDimensionSets(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", map[string]string{
+		"Service":   "sparta",
+		"Operation": "publish",
+		"Stage":     "prod",
+	}).
+		AddDimensionSet("Service", "Operation").
+		AddDimensionSet("Service", "Stage")
+	metricDirective.Metrics["invocations"] = MetricValue{
+		Unit:  UnitCount,
+		Value: 1,
+	}
+	var output bytes.Buffer
+	publishErr := emMetric.PublishToSink(nil, &output)
+	if publishErr != nil {
+		t.Fatalf("Failed to publish metric: %s", publishErr)
+	}
+	var published emf
+	unmarshalErr := json.Unmarshal(output.Bytes(), &published)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal metric: %s", unmarshalErr)
+	}
+	expected := [][]string{
+		{"Service", "Operation"},
+		{"Service", "Stage"},
+	}
+	actual := published.AWS.CloudWatchMetrics[0].Dimensions
+	if fmt.Sprintf("%v", actual) != fmt.Sprintf("%v", expected) {
+		t.Fatalf("Expected DimensionSets %v. Found: %v", expected, actual)
+	}
+	if emMetric.EstimatedDataPoints() != 2 {
+		t.Fatalf("Expected 2 data points. Found: %d", emMetric.EstimatedDataPoints())
+	}
+
+	// Undefined keys are rejected
+	undefinedMetric, _ := NewEmbeddedMetric()
+	undefinedDirective := undefinedMetric.NewMetricDirective("SpecialNamespace", map[string]string{
+		"Service": "sparta",
+	}).AddDimensionSet("Service", "Operation")
+	undefinedDirective.Metrics["invocations"] = MetricValue{
+		Unit:  UnitCount,
+		Value: 1,
+	}
+	undefinedErr := undefinedMetric.PublishToSink(nil, ioutil.Discard)
+	if undefinedErr == nil {
+		t.Fatalf("Expected error for DimensionSet with undefined key")
+	}
+}
+
+func TestStructuredMetricDisabled(t *testing.T) {
+	emMetric, emMetricErr := NewDisabledEmbeddedMetric()
+	if emMetricErr != nil {
+		t.Fatalf("Failed to create disabled metric: %s", emMetricErr)
+	}
+	metricDirective := emMetric.WithProperty("requestID", "96f98a63").
+		NewMetricDirective("SpecialNamespace", nil).
+		WithDimensionValue("Service", "sparta")
+	metricDirective.Metrics["invocations"] = MetricValue{
+		Unit:  UnitCount,
+		Value: 1,
+	}
+	var output bytes.Buffer
+	publishErr := emMetric.PublishToSink(nil, &output)
+	if publishErr != nil {
+		t.Fatalf("Expected disabled metric to not return an error: %s", publishErr)
+	}
+	if output.Len() != 0 {
+		t.Fatalf("Expected disabled metric to not write output. Found: %s", output.String())
+	}
+}
+
+func TestStructuredMetricSingleLine(t *testing.T) {
+	multilineValue := "line1\nline2\r\n\ttabbed\x00"
+	emMetric, _ := NewEmbeddedMetricWithProperties(map[string]interface{}{
+		"multiline":     multilineValue,
+		"preSerialized": "{\n  \"nested\": true\n}",
+	})
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	metricDirective.Metrics["invocations"] = MetricValue{
+		Unit:  UnitCount,
+		Value: 1,
+	}
+	var output bytes.Buffer
+	publishErr := emMetric.PublishToSink(nil, &output)
+	if publishErr != nil {
+		t.Fatalf("Failed to publish metric: %s", publishErr)
+	}
+	if strings.ContainsAny(output.String(), "\r\n") {
+		t.Fatalf("Expected single line output. Found: %s", output.String())
+	}
+	published := make(map[string]interface{})
+	unmarshalErr := json.Unmarshal(output.Bytes(), &published)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal metric: %s", unmarshalErr)
+	}
+	if published["multiline"] != multilineValue {
+		t.Fatalf("Expected multiline property to round trip. Found: %q", published["multiline"])
+	}
+}
+
+func TestStructuredMetricLogGroupOverride(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	emMetric.WithLogGroup("/custom/log-group").
+		WithLogStream("custom-stream")
+	rawJSON, rawJSONErr := json.Marshal(emMetric)
+	if rawJSONErr != nil {
+		t.Fatalf("Failed to marshal metric: %s", rawJSONErr)
+	}
+	published := make(map[string]interface{})
+	unmarshalErr := json.Unmarshal(rawJSON, &published)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal metric: %s", unmarshalErr)
+	}
+	if published["log_group_name"] != "/custom/log-group" {
+		t.Fatalf("Expected custom log group. Found: %v", published["log_group_name"])
+	}
+	if published["log_stream_name"] != "custom-stream" {
+		t.Fatalf("Expected custom log stream. Found: %v", published["log_stream_name"])
+	}
+}
+
+func TestStructuredMetricEnvValueWithEquals(t *testing.T) {
+	defer setTestEnv(t, "AWS_LAMBDA_LOG_GROUP_NAME", "a=b=c")()
+
+	emMetric, _ := NewEmbeddedMetric()
+	rawJSON, rawJSONErr := json.Marshal(emMetric)
+	if rawJSONErr != nil {
+		t.Fatalf("Failed to marshal metric: %s", rawJSONErr)
+	}
+	published := make(map[string]interface{})
+	unmarshalErr := json.Unmarshal(rawJSON, &published)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal metric: %s", unmarshalErr)
+	}
+	if published["log_group_name"] != "a=b=c" {
+		t.Fatalf("Expected log_group_name a=b=c. Found: %v", published["log_group_name"])
+	}
+}
+
+func TestStructuredMetricNumericValues(t *testing.T) {
+	testCases := []struct {
+		value interface{}
+		valid bool
+	}{
+		{42, true},
+		{int64(-42), true},
+		{uint8(7), true},
+		{float32(1.5), true},
+		{42.5, true},
+		{[]float64{10, 20, 30}, true},
+		{[]int{1, 2}, true},
+		{StatisticSet{SampleCount: 1, Sum: 1, Minimum: 1, Maximum: 1}, true},
+		{"42", false},
+		{fmt.Sprintf("%d", 42), false},
+		{true, false},
+		{[]string{"42"}, false},
+		{struct{ Value int }{42}, false},
+	}
+	for _, eachTestCase := range testCases {
+		emMetric, _ := NewEmbeddedMetric()
+		metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+		metricDirective.Metrics["value"] = MetricValue{
+			Unit:  UnitCount,
+			Value: eachTestCase.value,
+		}
+		publishErr := emMetric.PublishToSink(nil, ioutil.Discard)
+		_, constructorErr := NewMetricValue(eachTestCase.value, UnitCount)
+		if eachTestCase.valid && (publishErr != nil || constructorErr != nil) {
+			t.Errorf("Expected %#v to be valid. Publish: %v, NewMetricValue: %v",
+				eachTestCase.value,
+				publishErr,
+				constructorErr)
+		}
+		if !eachTestCase.valid && (publishErr == nil || constructorErr == nil) {
+			t.Errorf("Expected %#v to be rejected", eachTestCase.value)
+		}
+	}
+}
+
+func TestNonFiniteMetricValue(t *testing.T) {
+	testValues := map[string]MetricValue{
+		"nan":          {Value: math.NaN()},
+		"positiveInf":  {Value: math.Inf(1)},
+		"negativeInf":  {Value: math.Inf(-1)},
+		"arrayNaN":     {Value: []float64{1, math.NaN()}},
+		"countsInf":    {Value: []float64{1, 2}, Counts: []float64{1, math.Inf(1)}},
+		"statisticNaN": {Value: StatisticSet{SampleCount: 1, Sum: math.NaN()}},
+	}
+	for eachName, eachValue := range testValues {
+		emMetric, _ := NewEmbeddedMetric()
+		metricDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+		metricDirective.Count("invocations", 1)
+		metricDirective.Metrics[eachName] = eachValue
+		var sink bytes.Buffer
+		publishErr := emMetric.PublishToSink(nil, &sink)
+		if errors.Cause(publishErr) != ErrNonFiniteValue {
+			t.Fatalf("Expected ErrNonFiniteValue for %s. Found: %v", eachName, publishErr)
+		}
+		if !strings.Contains(publishErr.Error(), eachName) {
+			t.Fatalf("Expected error to identify metric %s. Found: %s", eachName, publishErr)
+		}
+		if sink.Len() != 0 {
+			t.Fatalf("Expected nothing to be written for %s. Found: %s", eachName, sink.String())
+		}
+	}
+}
+
+func TestEmbeddedMetricValidate(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	validDirective := emMetric.NewMetricDirective("SpecialNamespace", nil)
+	validDirective.Count("invocations", 1)
+	if validateErrs := emMetric.Validate(); len(validateErrs) != 0 {
+		t.Fatalf("Expected a valid metric. Found: %v", validateErrs)
+	}
+
+	tooManyDimensions := make(map[string]string)
+	for i := 0; i <= maxDirectiveDimensions; i++ {
+		tooManyDimensions[fmt.Sprintf("dim%d", i)] = "value"
+	}
+	brokenDirective := emMetric.NewMetricDirective("", tooManyDimensions)
+	for i := 0; i <= maxDirectiveMetrics; i++ {
+		brokenDirective.Count(fmt.Sprintf("metric%03d", i), float64(i))
+	}
+	brokenDirective.Metrics["bogusUnit"] = MetricValue{Value: 1, Unit: MetricUnit("Fortnights")}
+	brokenDirective.Metrics["latency"] = MetricValue{Value: math.NaN(), Unit: UnitMilliseconds}
+
+	validateErrs := emMetric.Validate()
+	expected := []string{
+		"Namespace must not be empty",
+		"9 dimensions",
+		"100 metrics",
+		"Metric bogusUnit has an unsupported MetricUnit",
+		"Metric latency has a NaN or infinite value",
+	}
+	if len(validateErrs) != len(expected) {
+		t.Fatalf("Expected %d errors. Found: %v", len(expected), validateErrs)
+	}
+	for _, eachExpected := range expected {
+		found := false
+		for _, eachErr := range validateErrs {
+			found = found || strings.Contains(eachErr.Error(), eachExpected)
+		}
+		if !found {
+			t.Fatalf("Expected an error containing %q. Found: %v", eachExpected, validateErrs)
+		}
+	}
+	var sink bytes.Buffer
+	if publishErr := emMetric.PublishToSink(nil, &sink); publishErr == nil {
+		t.Fatalf("Expected PublishToSink to fail validation")
+	}
+	var nilMetric *EmbeddedMetric
+	if validateErrs := nilMetric.Validate(); len(validateErrs) != 1 || validateErrs[0] != ErrNilEmbeddedMetric {
+		t.Fatalf("Expected ErrNilEmbeddedMetric. Found: %v", validateErrs)
+	}
+}
+
+func TestMetricNamespaceOverride(t *testing.T) {
+	emMetric, _ := NewEmbeddedMetric()
+	metricDirective := emMetric.NewMetricDirective("SpecialNamespace", map[string]string{
+		"Service": "sparta",
+	})
+	metricDirective.Count("invocations", 1)
+	metricDirective.Metrics["billedDuration"] = MetricValue{
+		Value:     100,
+		Unit:      UnitMilliseconds,
+		Namespace: "BillingNamespace",
+	}
+	rawJSON, rawJSONErr := json.Marshal(emMetric)
+	if rawJSONErr != nil {
+		t.Fatalf("Failed to marshal metric: %s", rawJSONErr)
+	}
+	validateErr := ValidateEMF(rawJSON)
+	if validateErr != nil {
+		t.Fatalf("Expected a valid EMF record: %s", validateErr)
+	}
+	var record emf
+	unmarshalErr := json.Unmarshal(rawJSON, &record)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal metric: %s", unmarshalErr)
+	}
+	elems := record.AWS.CloudWatchMetrics
+	if len(elems) != 2 ||
+		elems[0].Namespace != "SpecialNamespace" ||
+		elems[1].Namespace != "BillingNamespace" {
+		t.Fatalf("Expected one element per namespace. Found: %s", string(rawJSON))
+	}
+	if len(elems[0].Metrics) != 1 || elems[0].Metrics[0].Name != "invocations" ||
+		len(elems[1].Metrics) != 1 || elems[1].Metrics[0].Name != "billedDuration" {
+		t.Fatalf("Expected metrics grouped by namespace. Found: %s", string(rawJSON))
+	}
+	if len(elems[1].Dimensions) != 1 || elems[1].Dimensions[0][0] != "Service" {
+		t.Fatalf("Expected the override namespace to share the directive dimensions. Found: %s",
+			string(rawJSON))
+	}
+
+	metricDirective.Metrics["bogus"] = MetricValue{Value: 1, Namespace: " "}
+	if validateErrs := emMetric.Validate(); len(validateErrs) != 1 {
+		t.Fatalf("Expected an invalid Namespace error. Found: %v", validateErrs)
+	}
+}
+
+func TestGaugeTTL(t *testing.T) {
+	currentTime := time.Unix(1600000000, 0)
+	gauge := NewGauge("SpecialNamespace",
+		map[string]string{"Service": "sparta"},
+		"queueDepth",
+		UnitCount).
+		WithTTL(time.Minute).
+		WithClock(func() time.Time { return currentTime })
+
+	var output bytes.Buffer
+	flushErr := gauge.Flush(&output)
+	if flushErr != nil || output.Len() != 0 {
+		t.Fatalf("Expected nothing to be flushed before Set. Found: %s (%v)", output.String(), flushErr)
+	}
+	gauge.Set(42)
+	currentTime = currentTime.Add(30 * time.Second)
+	flushErr = gauge.Flush(&output)
+	if flushErr != nil {
+		t.Fatalf("Failed to flush gauge: %s", flushErr)
+	}
+	record, parseErr := ParseEmbeddedMetric(bytes.TrimSpace(output.Bytes()))
+	if parseErr != nil {
+		t.Fatalf("Failed to parse flushed gauge: %s", parseErr)
+	}
+	queueDepth := record.Directives()[0].Metrics["queueDepth"]
+	if queueDepth.Value != float64(42) || queueDepth.Unit != UnitCount {
+		t.Fatalf("Unexpected gauge value: %#v", queueDepth)
+	}
+
+	// Past the TTL the stale reading isn't emitted
+	output.Reset()
+	currentTime = currentTime.Add(time.Minute)
+	flushErr = gauge.Flush(&output)
+	if flushErr != nil || output.Len() != 0 {
+		t.Fatalf("Expected stale gauge to be skipped. Found: %s (%v)", output.String(), flushErr)
+	}
+
+	// A new reading is emitted again
+	gauge.Set(7)
+	flushErr = gauge.Flush(&output)
+	if flushErr != nil || output.Len() == 0 {
+		t.Fatalf("Expected refreshed gauge to be flushed (%v)", flushErr)
+	}
+}
@@ -31,6 +31,10 @@ func Describe(serviceName string,
 	workflowHooks *WorkflowHooks,
 	logger *logrus.Logger) error {
 
+	resourcesErr := verifyDescribeResources()
+	if resourcesErr != nil {
+		return resourcesErr
+	}
 	validationErr := validateSpartaPreconditions(lambdaAWSInfos, logger)
 	if validationErr != nil {
 		return validationErr
@@ -66,10 +70,7 @@ func Describe(serviceName string,
 	}
 
 	// Setup the describer
-	describer := descriptionWriter{
-		nodes:  make([]*cytoscapeNode, 0),
-		logger: logger,
-	}
+	describer := newDescriptionWriter(logger)
 
 	// Instead of inline mermaid stuff, we're going to stuff raw
 	// json through. We can also include AWS images in the icon
@@ -118,9 +119,10 @@ func Describe(serviceName string,
 					nodeColor = nodeColorEventSource
 				}
 
-				writeErr = describer.writeNode(name,
+				writeErr = describer.writeResourceNode(name,
 					nodeColor,
-					iconForAWSResource(eachNode.Name))
+					eachNode.ResourceType,
+					eachNode.Name)
 				if writeErr != nil {
 					return writeErr
 				}
@@ -142,9 +144,10 @@ func Describe(serviceName string,
 					index))
 			}
 			nodeName := string(jsonBytes)
-			writeErr = describer.writeNode(nodeName,
+			writeErr = describer.writeResourceNode(nodeName,
 				nodeColorEventSource,
-				iconForAWSResource(dynamicArn))
+				eventSourceResourceType(eachEventSourceMapping.EventSourceArn),
+				dynamicArn)
 			if writeErr != nil {
 				return writeErr
 			}
@@ -163,14 +166,21 @@ func Describe(serviceName string,
 	// API?
 	if nil != api {
 		// TODO - delegate
-		writeErr := api.Describe(&describer)
+		writeErr := api.Describe(describer)
 		if writeErr != nil {
 			return writeErr
 		}
 	}
-	cytoscapeBytes, cytoscapeBytesErr := json.MarshalIndent(describer.nodes, "", " ")
-	if cytoscapeBytesErr != nil {
-		return errors.Wrapf(cytoscapeBytesErr, "Failed to marshal cytoscape data")
+	describer.Finalize()
+	var cytoscapeJSON bytes.Buffer
+	cytoscapeJSONErr := describer.WriteJSON(&cytoscapeJSON)
+	if cytoscapeJSONErr != nil {
+		return cytoscapeJSONErr
+	}
+	var cytoscapeMetaJSON bytes.Buffer
+	cytoscapeMetaJSONErr := describer.WriteMetaJSON(&cytoscapeMetaJSON)
+	if cytoscapeMetaJSONErr != nil {
+		return cytoscapeMetaJSONErr
 	}
 	params := struct {
 		SpartaVersion          string
@@ -181,6 +191,7 @@ func Describe(serviceName string,
 		JSFiles                []*templateResource
 		ImageMap               map[string]string
 		CytoscapeData          interface{}
+		CytoscapeMeta          interface{}
 	}{
 		SpartaGitHash[0:8],
 		serviceName,
@@ -189,7 +200,8 @@ func Describe(serviceName string,
 		templateCSSFiles(logger),
 		templateJSFiles(logger),
 		templateImageMap(logger),
-		string(cytoscapeBytes),
+		cytoscapeJSON.String(),
+		cytoscapeMetaJSON.String(),
 	}
 	return tmpl.Execute(outputWriter, params)
 }
@@ -1,7 +1,10 @@
 package sparta
 
 import (
+	"bytes"
+	"encoding/base64"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -28,3 +31,101 @@ func TestDescribe(t *testing.T) {
 		t.Errorf("Failed to describe: %s", err)
 	}
 }
+
+// describeOutput returns the Describe output for the lambda functions
+func describeOutput(t *testing.T, lambdaAWSInfos []*LambdaAWSInfo) string {
+	logger, _ := NewLogger("info")
+	var output bytes.Buffer
+	err := Describe("SampleService",
+		"SampleService Description",
+		lambdaAWSInfos,
+		nil,
+		nil,
+		"",
+		"",
+		"",
+		&output,
+		nil,
+		logger)
+	if nil != err {
+		t.Fatalf("Failed to describe: %s", err)
+	}
+	return output.String()
+}
+
+// describeIconData returns the base64 encoded icon that Describe embeds
+// in a node's image
+func describeIconData(t *testing.T, iconPath string) string {
+	logger, _ := NewLogger("info")
+	resource := templateResourceForKey(iconPath, logger)
+	if resource == nil {
+		t.Fatalf("Icon isn't embedded: %s", iconPath)
+	}
+	return base64.StdEncoding.EncodeToString([]byte(resource.Data))
+}
+
+func TestDescribeResourceTypeIcons(t *testing.T) {
+	lambdaFn, lambdaFnErr := NewAWSLambda(LambdaName(mockLambda1),
+		mockLambda1,
+		lambdaTestExecuteARN)
+	if lambdaFnErr != nil {
+		t.Fatalf("Failed to create lambda: %s", lambdaFnErr)
+	}
+	// Resource names that mention other services must not change the icon
+	lambdaFn.Permissions = append(lambdaFn.Permissions, S3Permission{
+		BasePermission: BasePermission{
+			SourceArn: "arn:aws:s3:::sns-archive",
+		},
+		Events: []string{"s3:ObjectCreated:*"},
+	})
+	lambdaFn.EventSourceMappings = append(lambdaFn.EventSourceMappings, &EventSourceMapping{
+		EventSourceArn: "arn:aws:sqs:us-west-2:000000000000:dynamodb-retries",
+		BatchSize:      10,
+	})
+	output := describeOutput(t, []*LambdaAWSInfo{lambdaFn})
+	for _, eachService := range []string{"s3", "sqs"} {
+		if !strings.Contains(output, describeIconData(t, iconMappings[eachService])) {
+			t.Fatalf("Expected the %s icon to be used", eachService)
+		}
+	}
+	for _, eachService := range []string{"sns", "dynamodb"} {
+		if strings.Contains(output, describeIconData(t, iconMappings[eachService])) {
+			t.Fatalf("Expected the %s icon not to be used", eachService)
+		}
+	}
+}
+
+func TestDescribeShowsCloudWatchLogsTrigger(t *testing.T) {
+	lambdaFn, lambdaFnErr := NewAWSLambda(LambdaName(mockLambda1),
+		mockLambda1,
+		lambdaTestExecuteARN)
+	if lambdaFnErr != nil {
+		t.Fatalf("Failed to create lambda: %s", lambdaFnErr)
+	}
+	logGroupName := "/aws/lambda/sample-logs"
+	cloudWatchLogsPermission := CloudWatchLogsPermission{}
+	cloudWatchLogsPermission.Filters = map[string]CloudWatchLogsSubscriptionFilter{
+		"SampleFilter": {
+			LogGroupName: logGroupName,
+		},
+	}
+	lambdaFn.Permissions = append(lambdaFn.Permissions,
+		cloudWatchLogsPermission,
+		SNSPermission{
+			BasePermission: BasePermission{
+				SourceArn: snsTopicSourceArn,
+			},
+		})
+	output := describeOutput(t, []*LambdaAWSInfo{lambdaFn})
+
+	// The log group is an event source, so the default filter must not
+	// hide it even though its type is AWS::Logs::LogGroup
+	for _, eachVisible := range []string{lambdaFn.lambdaFunctionName(),
+		logGroupName,
+		snsTopicSourceArn} {
+		visibleID, _ := cytoscapeNodeID(eachVisible)
+		if !strings.Contains(output, visibleID) {
+			t.Fatalf("Expected a node for %s", eachVisible)
+		}
+	}
+}
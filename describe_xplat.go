@@ -1,13 +1,19 @@
 package sparta
 
 import (
+	"bytes"
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
-	"math/rand"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -24,6 +30,116 @@ const (
 	nodeNameAPIGateway   = "API Gateway"
 )
 
+// Describe themes select the node color palette. They don't change the
+// icons, which are always the embedded "SVG Light" architecture icons.
+const (
+	// DescribeThemeLight uses the default node colors and is the default
+	// describe theme
+	DescribeThemeLight = "light"
+	// DescribeThemeDark uses node colors intended for dark backgrounds
+	DescribeThemeDark = "dark"
+)
+
+const (
+	// DescribeLayoutTopToBottom lays out the describe graph with edges
+	// pointing down the page. This is the default layout direction.
+	DescribeLayoutTopToBottom = "TB"
+	// DescribeLayoutLeftToRight lays out the describe graph with edges
+	// pointing across the page
+	DescribeLayoutLeftToRight = "LR"
+)
+
+// nodeColorsDark are the dark theme alternatives for the nodeColor*
+// constants
+var nodeColorsDark = map[string]string{
+	nodeColorService:     "#E0625E",
+	nodeColorEventSource: "#FF8A65",
+	nodeColorLambda:      "#FFA24D",
+	nodeColorAPIGateway:  "#5CCFFA",
+}
+
+// describeRegistryMutex guards the describe registries below: the theme,
+// layout direction, icon overrides, custom icons, IconResolver and
+// DescribeNodeFilter. They're typically set from init functions but may
+// be read by concurrent Describe calls.
+var describeRegistryMutex sync.RWMutex
+
+// describeTheme is the theme used by Describe
+var describeTheme = DescribeThemeLight
+
+// SetDescribeTheme selects the node color theme used by Describe. The
+// theme must be either DescribeThemeLight or DescribeThemeDark.
+func SetDescribeTheme(theme string) error {
+	switch theme {
+	case DescribeThemeLight, DescribeThemeDark:
+		describeRegistryMutex.Lock()
+		defer describeRegistryMutex.Unlock()
+		describeTheme = theme
+		return nil
+	}
+	return errors.Errorf("Unsupported describe theme: %s. Must be one of: %s, %s",
+		theme,
+		DescribeThemeLight,
+		DescribeThemeDark)
+}
+
+// describeLayoutDirection is the graph layout direction used by Describe
+var describeLayoutDirection = DescribeLayoutTopToBottom
+
+// SetDescribeLayoutDirection selects the direction of the describe graph
+// layout. The direction must be either DescribeLayoutTopToBottom or
+// DescribeLayoutLeftToRight.
+func SetDescribeLayoutDirection(direction string) error {
+	switch direction {
+	case DescribeLayoutTopToBottom, DescribeLayoutLeftToRight:
+		describeRegistryMutex.Lock()
+		defer describeRegistryMutex.Unlock()
+		describeLayoutDirection = direction
+		return nil
+	}
+	return errors.Errorf("Unsupported describe layout direction: %s. Must be one of: %s, %s",
+		direction,
+		DescribeLayoutTopToBottom,
+		DescribeLayoutLeftToRight)
+}
+
+// themedNodeColor returns the node color for the theme
+func themedNodeColor(nodeColor string, theme string) string {
+	if theme == DescribeThemeDark {
+		if darkColor, exists := nodeColorsDark[nodeColor]; exists {
+			return darkColor
+		}
+	}
+	return nodeColor
+}
+
+// EdgeKind is the relationship represented by a describe edge. The kind is
+// emitted as the edge's cytoscape class so that edges can be styled and
+// filtered by relationship.
+type EdgeKind string
+
+const (
+	// EdgeKindNone is an untyped edge
+	EdgeKindNone EdgeKind = ""
+	// EdgeKindTriggers is an event source that invokes its target
+	EdgeKindTriggers EdgeKind = "triggers"
+	// EdgeKindReads is a resource that reads from its target
+	EdgeKindReads EdgeKind = "reads"
+	// EdgeKindWrites is a resource that writes to its target
+	EdgeKindWrites EdgeKind = "writes"
+	// EdgeKindPublishesTo is a resource that publishes messages to its target
+	EdgeKindPublishesTo EdgeKind = "publishes-to"
+)
+
+// validEdgeKinds are the supported EdgeKind values
+var validEdgeKinds = map[EdgeKind]bool{
+	EdgeKindNone:        true,
+	EdgeKindTriggers:    true,
+	EdgeKindReads:       true,
+	EdgeKindWrites:      true,
+	EdgeKindPublishesTo: true,
+}
+
 type cytoscapeData struct {
 	ID               string `json:"id"`
 	Image            string `json:"image"`
@@ -37,6 +153,12 @@ type cytoscapeNode struct {
 	Data    cytoscapeData `json:"data"`
 	Classes string        `json:"classes,omitempty"`
 }
+
+// cytoscapeMeta is the graph level data that accompanies the cytoscape
+// elements and is consumed by sparta.js
+type cytoscapeMeta struct {
+	LayoutDirection string `json:"layoutDirection"`
+}
 type templateResource struct {
 	KeyName string
 	Data    string
@@ -55,11 +177,204 @@ func cytoscapeNodeID(rawData interface{}) (string, error) {
 	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
+// describeIconOverrides are the user supplied icon paths, keyed by node name,
+// that take precedence over the type based icon detection
+var describeIconOverrides = make(map[string]string)
+
+// RegisterDescribeIconOverride forces the describe output to use the
+// iconPath image for the node with the given name (typically the
+// logical resource ID) rather than the icon inferred from its type.
+// The iconPath is relative to the embedded /resources/describe directory.
+func RegisterDescribeIconOverride(nodeName string, iconPath string) error {
+	describeRegistryMutex.Lock()
+	defer describeRegistryMutex.Unlock()
+	if _, exists := describeIconOverrides[nodeName]; exists {
+		return errors.Errorf("Icon override for (%s) has already been defined", nodeName)
+	}
+	describeIconOverrides[nodeName] = iconPath
+	return nil
+}
+
+// describeCustomIcons are the user supplied SVG icons keyed by name
+var describeCustomIcons = make(map[string][]byte)
+
+// RegisterDescribeIcon makes the SVG icon data available to the describe
+// output under iconName. The name can be returned from an IconResolver or
+// used as a RegisterDescribeIconOverride iconPath, and the icon is
+// embedded into the node like the built-in AWS icons. Custom icons take
+// precedence over embedded resources with the same name.
+func RegisterDescribeIcon(iconName string, svgData []byte) error {
+	if iconName == "" {
+		return errors.New("Describe icon name must not be empty")
+	}
+	if len(svgData) == 0 {
+		return errors.Errorf("Describe icon (%s) must not be empty", iconName)
+	}
+	describeRegistryMutex.Lock()
+	defer describeRegistryMutex.Unlock()
+	if _, exists := describeCustomIcons[iconName]; exists {
+		return errors.Errorf("Describe icon (%s) has already been defined", iconName)
+	}
+	describeCustomIcons[iconName] = svgData
+	return nil
+}
+
+// RegisterDescribeIconFromFileSystem registers the SVG icon at path in
+// fileSystem under iconName. See RegisterDescribeIcon.
+func RegisterDescribeIconFromFileSystem(iconName string,
+	fileSystem http.FileSystem,
+	path string) error {
+	iconFile, iconFileErr := fileSystem.Open(path)
+	if iconFileErr != nil {
+		return errors.Wrapf(iconFileErr, "Failed to open describe icon: %s", path)
+	}
+	defer iconFile.Close()
+	svgData, svgDataErr := ioutil.ReadAll(iconFile)
+	if svgDataErr != nil {
+		return errors.Wrapf(svgDataErr, "Failed to read describe icon: %s", path)
+	}
+	return RegisterDescribeIcon(iconName, svgData)
+}
+
+// IconResolver returns the icon path, relative to the embedded
+// /resources/describe directory, for a describe node's emitter. Return
+// the empty string to use the default icon resolution.
+type IconResolver func(rawEmitter interface{}) string
+
+// describeIconResolver is the user supplied IconResolver
+var describeIconResolver IconResolver
+
+// RegisterDescribeIconResolver installs an IconResolver that is consulted
+// before the default AWS resource icon resolution. Use it to supply icons
+// for custom resource types.
+func RegisterDescribeIconResolver(resolver IconResolver) error {
+	describeRegistryMutex.Lock()
+	defer describeRegistryMutex.Unlock()
+	if describeIconResolver != nil {
+		return errors.New("Describe IconResolver has already been defined")
+	}
+	describeIconResolver = resolver
+	return nil
+}
+
+// DescribeNodeFilter returns true if the describe node for the emitter
+// should be rendered. The resourceType is the node's CloudFormation
+// resource type (eg, AWS::Logs::LogGroup), or the empty string if it
+// isn't known. Edges to and from nodes that aren't rendered are dropped.
+type DescribeNodeFilter func(nodeName string, resourceType string, rawEmitter interface{}) bool
+
+// describeNodeFilter is the user supplied DescribeNodeFilter
+var describeNodeFilter DescribeNodeFilter
+
+// RegisterDescribeNodeFilter installs a DescribeNodeFilter that replaces
+// the default filter, which hides IAM and Lambda permission resources.
+func RegisterDescribeNodeFilter(filter DescribeNodeFilter) error {
+	describeRegistryMutex.Lock()
+	defer describeRegistryMutex.Unlock()
+	if describeNodeFilter != nil {
+		return errors.New("Describe node filter has already been defined")
+	}
+	describeNodeFilter = filter
+	return nil
+}
+
+// ResetDescribeRegistries restores the describe theme and layout direction
+// to their defaults and removes every registered icon override, custom
+// icon, IconResolver and DescribeNodeFilter. It's intended for tests that
+// register describe customizations.
+func ResetDescribeRegistries() {
+	describeRegistryMutex.Lock()
+	defer describeRegistryMutex.Unlock()
+	describeTheme = DescribeThemeLight
+	describeLayoutDirection = DescribeLayoutTopToBottom
+	describeIconOverrides = make(map[string]string)
+	describeCustomIcons = make(map[string][]byte)
+	describeIconResolver = nil
+	describeNodeFilter = nil
+}
+
+// describeNoiseResourceTypes are the CloudFormation resource types that
+// the default DescribeNodeFilter hides. They're IAM and permission
+// plumbing that clutters the diagram without describing the architecture.
+// Event source types, such as the AWS::Logs::LogGroup of a CloudWatch
+// Logs subscription, must not be listed here.
+var describeNoiseResourceTypes = map[string]bool{
+	"AWS::IAM::ManagedPolicy": true,
+	"AWS::IAM::Policy":        true,
+	"AWS::IAM::Role":          true,
+	"AWS::Lambda::Permission": true,
+}
+
+// defaultDescribeNodeFilter hides resources whose CloudFormation type is
+// one of the describeNoiseResourceTypes. If the type isn't known the
+// emitter's Type, if any, is used.
+func defaultDescribeNodeFilter(nodeName string, resourceType string, rawEmitter interface{}) bool {
+	if resourceType == "" {
+		jsonBytes, jsonBytesErr := json.Marshal(rawEmitter)
+		if jsonBytesErr != nil {
+			return true
+		}
+		resourceType = cloudFormationResourceType(jsonBytes)
+	}
+	return !describeNoiseResourceTypes[resourceType]
+}
+
+// newDescriptionWriter returns a descriptionWriter configured from the
+// describe registries. The icon overrides are copied so that a later
+// registration doesn't affect an in progress Describe.
+func newDescriptionWriter(logger *logrus.Logger) *descriptionWriter {
+	describeRegistryMutex.RLock()
+	defer describeRegistryMutex.RUnlock()
+
+	iconOverrides := make(map[string]string, len(describeIconOverrides))
+	for eachKey, eachValue := range describeIconOverrides {
+		iconOverrides[eachKey] = eachValue
+	}
+	nodeFilter := describeNodeFilter
+	if nodeFilter == nil {
+		nodeFilter = defaultDescribeNodeFilter
+	}
+	return &descriptionWriter{
+		nodes:           make([]*cytoscapeNode, 0),
+		logger:          logger,
+		iconOverrides:   iconOverrides,
+		theme:           describeTheme,
+		iconResolver:    describeIconResolver,
+		nodeFilter:      nodeFilter,
+		layoutDirection: describeLayoutDirection,
+	}
+}
+
 type descriptionWriter struct {
-	nodes  []*cytoscapeNode
-	logger *logrus.Logger
+	nodes         []*cytoscapeNode
+	nodeIDs       map[string]bool
+	edgeIDs       map[string]bool
+	hiddenNodeIDs map[string]bool
+	logger        *logrus.Logger
+	iconOverrides map[string]string
+	theme         string
+	iconResolver  IconResolver
+	nodeFilter    DescribeNodeFilter
+	// layoutDirection is one of the DescribeLayout* values. The empty
+	// string is treated as DescribeLayoutTopToBottom.
+	layoutDirection string
+}
+
+// iconForResource returns the icon path for the emitter, preferring the
+// user supplied IconResolver. The resourceType is the emitter's
+// CloudFormation resource type, if known.
+func (dw *descriptionWriter) iconForResource(resourceType string, rawEmitter interface{}) string {
+	if dw.iconResolver != nil {
+		if iconPath := dw.iconResolver(rawEmitter); iconPath != "" {
+			return iconPath
+		}
+	}
+	return iconForAWSResourceType(resourceType, rawEmitter)
 }
 
+// writeNode writes the node for nodeName. It's idempotent: since the node
+// ID is derived from the name, only the first write of a name produces a
+// node and edges to that name connect to it.
 func (dw *descriptionWriter) writeNode(nodeName string,
 	nodeColor string,
 	nodeImage string) error {
@@ -70,10 +385,23 @@ func (dw *descriptionWriter) writeNode(nodeName string,
 			"Failed to create nodeID for entry: %s",
 			nodeName)
 	}
+	if dw.nodeIDs == nil {
+		dw.nodeIDs = make(map[string]bool)
+	}
+	if dw.nodeIDs[nodeID] {
+		dw.logger.WithField("Node", nodeName).Debug("Skipping duplicate describe node")
+		return nil
+	}
+	dw.nodeIDs[nodeID] = true
+	nodeLabel := strings.Trim(nodeName, "\"")
+	if iconOverride, exists := dw.iconOverrides[nodeLabel]; exists {
+		nodeImage = iconOverride
+	}
 	appendNode := &cytoscapeNode{
 		Data: cytoscapeData{
-			ID:    nodeID,
-			Label: strings.Trim(nodeName, "\""),
+			ID:              nodeID,
+			Label:           nodeLabel,
+			BackgroundColor: themedNodeColor(nodeColor, dw.theme),
 		},
 	}
 	if nodeImage != "" {
@@ -87,10 +415,49 @@ func (dw *descriptionWriter) writeNode(nodeName string,
 	return nil
 }
 
+// writeResourceNode writes the node for a resource emitter, using the
+// resourceType, or the emitter if the type isn't known, to resolve the
+// node icon. Nodes rejected by the writer's nodeFilter aren't written and
+// their edges are pruned by Finalize.
+func (dw *descriptionWriter) writeResourceNode(nodeName string,
+	nodeColor string,
+	resourceType string,
+	rawEmitter interface{}) error {
+	if dw.nodeFilter != nil && !dw.nodeFilter(nodeName, resourceType, rawEmitter) {
+		nodeID, nodeErr := cytoscapeNodeID(nodeName)
+		if nodeErr != nil {
+			return errors.Wrapf(nodeErr,
+				"Failed to create nodeID for entry: %s",
+				nodeName)
+		}
+		if dw.hiddenNodeIDs == nil {
+			dw.hiddenNodeIDs = make(map[string]bool)
+		}
+		dw.hiddenNodeIDs[nodeID] = true
+		return nil
+	}
+	return dw.writeNode(nodeName, nodeColor, dw.iconForResource(resourceType, rawEmitter))
+}
+
 func (dw *descriptionWriter) writeEdge(fromNode string,
 	toNode string,
 	label string) error {
+	return dw.writeTypedEdge(fromNode, toNode, EdgeKindNone, label)
+}
 
+// writeTypedEdge writes an edge whose kind is recorded as the cytoscape
+// class of the edge
+func (dw *descriptionWriter) writeTypedEdge(fromNode string,
+	toNode string,
+	kind EdgeKind,
+	label string) error {
+
+	if !validEdgeKinds[kind] {
+		return errors.Errorf("Unsupported edge kind for entry %s -> %s: %s",
+			fromNode,
+			toNode,
+			kind)
+	}
 	nodeSource, nodeSourceErr := cytoscapeNodeID(fromNode)
 	if nodeSourceErr != nil {
 		return errors.Wrapf(nodeSourceErr,
@@ -99,63 +466,480 @@ func (dw *descriptionWriter) writeEdge(fromNode string,
 	}
 	nodeTarget, nodeTargetErr := cytoscapeNodeID(toNode)
 	if nodeTargetErr != nil {
-		return errors.Wrapf(nodeSourceErr,
+		return errors.Wrapf(nodeTargetErr,
 			"Failed to create nodeID for entry: %s",
 			toNode)
 	}
-
+	// The edge ID is derived from its endpoints, kind and label so that
+	// repeated describe runs produce identical output. Cytoscape rejects
+	// duplicate element IDs, so only the first write of an edge produces
+	// an element.
+	edgeID, edgeIDErr := cytoscapeNodeID([]string{nodeSource, nodeTarget, string(kind), label})
+	if edgeIDErr != nil {
+		return errors.Wrapf(edgeIDErr,
+			"Failed to create edgeID for entry: %s -> %s",
+			fromNode,
+			toNode)
+	}
+	if dw.edgeIDs == nil {
+		dw.edgeIDs = make(map[string]bool)
+	}
+	if dw.edgeIDs[edgeID] {
+		dw.logger.WithFields(logrus.Fields{
+			"Source": fromNode,
+			"Target": toNode,
+		}).Debug("Skipping duplicate describe edge")
+		return nil
+	}
+	dw.edgeIDs[edgeID] = true
 	dw.nodes = append(dw.nodes, &cytoscapeNode{
 		Data: cytoscapeData{
-			ID:     fmt.Sprintf("%d", rand.Uint64()),
+			ID:     edgeID,
 			Source: nodeSource,
 			Target: nodeTarget,
 			Label:  label,
 		},
+		Classes: string(kind),
 	})
 	return nil
 }
 
-func templateResourceForKey(resourceKeyName string, logger *logrus.Logger) *templateResource {
-	var resource *templateResource
-	resourcePath := fmt.Sprintf("/resources/describe/%s",
+// isEdge returns true if the cytoscapeNode represents an edge between
+// two other nodes
+func (cn *cytoscapeNode) isEdge() bool {
+	return cn.Data.Source != "" || cn.Data.Target != ""
+}
+
+// nodeDegrees returns the number of incoming and outgoing edges for every
+// connected node, keyed by node ID
+func (dw *descriptionWriter) nodeDegrees() map[string]int {
+	degrees := make(map[string]int)
+	for _, eachNode := range dw.nodes {
+		if eachNode.isEdge() {
+			degrees[eachNode.Data.Source]++
+			degrees[eachNode.Data.Target]++
+		}
+	}
+	return degrees
+}
+
+// pruneHiddenEdges removes the edges to and from nodes that were rejected
+// by the nodeFilter
+func (dw *descriptionWriter) pruneHiddenEdges() {
+	if len(dw.hiddenNodeIDs) == 0 {
+		return
+	}
+	visibleNodes := make([]*cytoscapeNode, 0, len(dw.nodes))
+	for _, eachNode := range dw.nodes {
+		if eachNode.isEdge() &&
+			(dw.hiddenNodeIDs[eachNode.Data.Source] || dw.hiddenNodeIDs[eachNode.Data.Target]) {
+			continue
+		}
+		visibleNodes = append(visibleNodes, eachNode)
+	}
+	dw.nodes = visibleNodes
+}
+
+// Finalize drops the edges to filtered nodes and updates the
+// DegreeCentrality of every node to the number of incoming and outgoing
+// edges. It must be called after all nodes and edges are written and
+// before the nodes are serialized.
+func (dw *descriptionWriter) Finalize() {
+	dw.pruneHiddenEdges()
+	degrees := dw.nodeDegrees()
+	for _, eachNode := range dw.nodes {
+		if !eachNode.isEdge() {
+			eachNode.Data.DegreeCentrality = degrees[eachNode.Data.ID]
+		}
+	}
+}
+
+// WriteJSON writes the nodes and edges as an indented JSON array of
+// cytoscape elements. Nodes are written before edges and each group is
+// sorted by ID so that the output is stable across runs.
+func (dw *descriptionWriter) WriteJSON(w io.Writer) error {
+	sortedNodes := make([]*cytoscapeNode, len(dw.nodes))
+	copy(sortedNodes, dw.nodes)
+	sort.SliceStable(sortedNodes, func(i, j int) bool {
+		if sortedNodes[i].isEdge() != sortedNodes[j].isEdge() {
+			return !sortedNodes[i].isEdge()
+		}
+		return sortedNodes[i].Data.ID < sortedNodes[j].Data.ID
+	})
+	jsonBytes, jsonBytesErr := json.MarshalIndent(sortedNodes, "", " ")
+	if jsonBytesErr != nil {
+		return errors.Wrapf(jsonBytesErr, "Failed to marshal cytoscape data")
+	}
+	_, writeErr := w.Write(jsonBytes)
+	if writeErr != nil {
+		return errors.Wrap(writeErr, "Failed to write cytoscape data")
+	}
+	return nil
+}
+
+// WriteMetaJSON writes the graph level settings, such as the layout
+// direction, as a JSON object
+func (dw *descriptionWriter) WriteMetaJSON(w io.Writer) error {
+	meta := cytoscapeMeta{
+		LayoutDirection: dw.layoutDirection,
+	}
+	if meta.LayoutDirection == "" {
+		meta.LayoutDirection = DescribeLayoutTopToBottom
+	}
+	jsonBytes, jsonBytesErr := json.Marshal(meta)
+	if jsonBytesErr != nil {
+		return errors.Wrapf(jsonBytesErr, "Failed to marshal cytoscape metadata")
+	}
+	_, writeErr := w.Write(jsonBytes)
+	if writeErr != nil {
+		return errors.Wrap(writeErr, "Failed to write cytoscape metadata")
+	}
+	return nil
+}
+
+// Stats returns the number of nodes and the number of edges written so
+// far. Edges are the entries with a Source or Target; every other entry
+// is a node. Call it after Finalize to exclude edges to filtered nodes.
+func (dw *descriptionWriter) Stats() (nodeCount int, edgeCount int) {
+	for _, eachNode := range dw.nodes {
+		if eachNode.isEdge() {
+			edgeCount++
+		} else {
+			nodeCount++
+		}
+	}
+	return nodeCount, edgeCount
+}
+
+// orphanedNodes returns the labels of the nodes that have no incoming
+// or outgoing edges. These are frequently leftover or misconfigured
+// resources.
+func (dw *descriptionWriter) orphanedNodes() []string {
+	degrees := dw.nodeDegrees()
+	orphans := make([]string, 0)
+	for _, eachNode := range dw.nodes {
+		if !eachNode.isEdge() && degrees[eachNode.Data.ID] == 0 {
+			orphans = append(orphans, eachNode.Data.Label)
+		}
+	}
+	return orphans
+}
+
+// dotNodeShapes are the Graphviz shapes for the describe node colors
+var dotNodeShapes = map[string]string{
+	nodeColorService:     "box3d",
+	nodeColorEventSource: "ellipse",
+	nodeColorLambda:      "box",
+	nodeColorAPIGateway:  "hexagon",
+}
+
+// dotQuote returns the value as a quoted Graphviz DOT ID
+func dotQuote(value string) string {
+	replacer := strings.NewReplacer("\\", "\\\\",
+		"\"", "\\\"",
+		"\n", "\\n")
+	return fmt.Sprintf("\"%s\"", replacer.Replace(value))
+}
+
+// WriteDOT writes the nodes and edges as a Graphviz DOT digraph so that
+// the describe output can be rendered by existing Graphviz toolchains
+func (dw *descriptionWriter) WriteDOT(w io.Writer) error {
+	var dot bytes.Buffer
+	dot.WriteString("digraph sparta {\n")
+	for _, eachNode := range dw.nodes {
+		if eachNode.isEdge() {
+			continue
+		}
+		attributes := []string{
+			fmt.Sprintf("label=%s", dotQuote(eachNode.Data.Label)),
+		}
+		shape, shapeExists := dotNodeShapes[eachNode.Data.BackgroundColor]
+		if !shapeExists {
+			shape = "box"
+		}
+		attributes = append(attributes, fmt.Sprintf("shape=%s", shape))
+		if eachNode.Data.BackgroundColor != "" {
+			attributes = append(attributes,
+				"style=filled",
+				fmt.Sprintf("fillcolor=%s", dotQuote(eachNode.Data.BackgroundColor)))
+		}
+		fmt.Fprintf(&dot, "  %s [%s];\n",
+			dotQuote(eachNode.Data.ID),
+			strings.Join(attributes, ", "))
+	}
+	for _, eachNode := range dw.nodes {
+		if !eachNode.isEdge() {
+			continue
+		}
+		fmt.Fprintf(&dot, "  %s -> %s",
+			dotQuote(eachNode.Data.Source),
+			dotQuote(eachNode.Data.Target))
+		if eachNode.Data.Label != "" {
+			fmt.Fprintf(&dot, " [label=%s]", dotQuote(eachNode.Data.Label))
+		}
+		dot.WriteString(";\n")
+	}
+	dot.WriteString("}\n")
+	_, writeErr := w.Write(dot.Bytes())
+	if writeErr != nil {
+		return errors.Wrap(writeErr, "Failed to write DOT output")
+	}
+	return nil
+}
+
+const (
+	svgMargin      = 20
+	svgNodeWidth   = 160
+	svgNodeHeight  = 90
+	svgIconSize    = 48
+	svgColumnWidth = 240
+	svgRowHeight   = 130
+)
+
+// svgEscape returns the value escaped for inclusion in SVG text and
+// attribute values
+func svgEscape(value string) string {
+	var escaped bytes.Buffer
+	_ = xml.EscapeText(&escaped, []byte(value))
+	return escaped.String()
+}
+
+// svgLayout assigns each node to a column using the longest path from a
+// source node, so that edges generally point left to right. Cycles are
+// tolerated by bounding the number of relaxation passes.
+func (dw *descriptionWriter) svgLayout() ([]*cytoscapeNode, map[string]int, map[string]int) {
+	nodes := make([]*cytoscapeNode, 0)
+	columns := make(map[string]int)
+	for _, eachNode := range dw.nodes {
+		if eachNode.isEdge() {
+			continue
+		}
+		if _, exists := columns[eachNode.Data.ID]; !exists {
+			columns[eachNode.Data.ID] = 0
+			nodes = append(nodes, eachNode)
+		}
+	}
+	for pass := 0; pass < len(nodes); pass++ {
+		changed := false
+		for _, eachEdge := range dw.nodes {
+			if !eachEdge.isEdge() {
+				continue
+			}
+			sourceColumn, sourceExists := columns[eachEdge.Data.Source]
+			targetColumn, targetExists := columns[eachEdge.Data.Target]
+			if sourceExists && targetExists && targetColumn <= sourceColumn &&
+				sourceColumn+1 < len(nodes) {
+				columns[eachEdge.Data.Target] = sourceColumn + 1
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	rows := make(map[string]int)
+	columnCounts := make(map[int]int)
+	for _, eachNode := range nodes {
+		column := columns[eachNode.Data.ID]
+		rows[eachNode.Data.ID] = columnCounts[column]
+		columnCounts[column]++
+	}
+	return nodes, columns, rows
+}
+
+// RenderSVG writes a static SVG image of the graph using a simple layered
+// layout. Nodes include the same embedded icons as the HTML output. This
+// doesn't require a browser, so it's suitable for CI artifacts.
+func (dw *descriptionWriter) RenderSVG(w io.Writer) error {
+	nodes, columns, rows := dw.svgLayout()
+	width := svgMargin * 2
+	height := svgMargin * 2
+	for _, eachNode := range nodes {
+		nodeRight := svgMargin + columns[eachNode.Data.ID]*svgColumnWidth + svgNodeWidth + svgMargin
+		nodeBottom := svgMargin + rows[eachNode.Data.ID]*svgRowHeight + svgNodeHeight + svgMargin
+		if nodeRight > width {
+			width = nodeRight
+		}
+		if nodeBottom > height {
+			height = nodeBottom
+		}
+	}
+	nodeOrigin := func(nodeID string) (int, int) {
+		return svgMargin + columns[nodeID]*svgColumnWidth,
+			svgMargin + rows[nodeID]*svgRowHeight
+	}
+
+	var svg bytes.Buffer
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height, width, height)
+	svg.WriteString(`<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto-start-reverse"><path d="M 0 0 L 10 5 L 0 10 z" fill="#555555"/></marker></defs>` + "\n")
+	for _, eachEdge := range dw.nodes {
+		if !eachEdge.isEdge() {
+			continue
+		}
+		_, sourceExists := columns[eachEdge.Data.Source]
+		_, targetExists := columns[eachEdge.Data.Target]
+		if !sourceExists || !targetExists {
+			continue
+		}
+		sourceX, sourceY := nodeOrigin(eachEdge.Data.Source)
+		targetX, targetY := nodeOrigin(eachEdge.Data.Target)
+		x1, y1 := sourceX+svgNodeWidth, sourceY+svgNodeHeight/2
+		x2, y2 := targetX, targetY+svgNodeHeight/2
+		if targetX <= sourceX {
+			x1, x2 = sourceX, targetX+svgNodeWidth
+		}
+		fmt.Fprintf(&svg, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#555555" marker-end="url(#arrow)"/>`+"\n",
+			x1, y1, x2, y2)
+		if eachEdge.Data.Label != "" {
+			fmt.Fprintf(&svg, `<text x="%d" y="%d" font-family="sans-serif" font-size="11" text-anchor="middle">%s</text>`+"\n",
+				(x1+x2)/2,
+				(y1+y2)/2-4,
+				svgEscape(eachEdge.Data.Label))
+		}
+	}
+	for _, eachNode := range nodes {
+		x, y := nodeOrigin(eachNode.Data.ID)
+		fillColor := eachNode.Data.BackgroundColor
+		if fillColor == "" {
+			fillColor = "#FFFFFF"
+		}
+		svg.WriteString("<g>")
+		fmt.Fprintf(&svg, `<rect x="%d" y="%d" width="%d" height="%d" rx="6" fill="%s" fill-opacity="0.15" stroke="%s"/>`,
+			x, y, svgNodeWidth, svgNodeHeight, svgEscape(fillColor), svgEscape(fillColor))
+		if eachNode.Data.Image != "" {
+			fmt.Fprintf(&svg, `<image x="%d" y="%d" width="%d" height="%d" xlink:href="%s"/>`,
+				x+(svgNodeWidth-svgIconSize)/2,
+				y+8,
+				svgIconSize,
+				svgIconSize,
+				svgEscape(eachNode.Data.Image))
+		}
+		fmt.Fprintf(&svg, `<text x="%d" y="%d" font-family="sans-serif" font-size="12" text-anchor="middle">%s</text>`,
+			x+svgNodeWidth/2,
+			y+svgNodeHeight-12,
+			svgEscape(eachNode.Data.Label))
+		svg.WriteString("</g>\n")
+	}
+	svg.WriteString("</svg>\n")
+	_, writeErr := w.Write(svg.Bytes())
+	if writeErr != nil {
+		return errors.Wrap(writeErr, "Failed to write SVG output")
+	}
+	return nil
+}
+
+// describeCriticalResources are the embedded resources without which
+// the describe output can't be rendered
+var describeCriticalResources = []string{
+	"template.html",
+	"sparta.js",
+	"cytoscape.js/dist/cytoscape.min.js",
+}
+
+var describeResourcesOnce sync.Once
+var describeResourcesErr error
+
+// verifyDescribeResources checks, once per process, that the critical
+// embedded describe resources exist
+func verifyDescribeResources() error {
+	describeResourcesOnce.Do(func() {
+		describeResourcesErr = verifyEmbeddedResources(describeCriticalResources)
+	})
+	return describeResourcesErr
+}
+
+// verifyEmbeddedResources returns an error listing any of the resource
+// keys that aren't available in the embedded filesystem
+func verifyEmbeddedResources(resourceKeyNames []string) error {
+	var missingKeys []string
+	for _, eachKey := range resourceKeyNames {
+		_, dataErr := _escFSString(false, describeResourcePath(eachKey))
+		if dataErr != nil {
+			missingKeys = append(missingKeys, eachKey)
+		}
+	}
+	if len(missingKeys) != 0 {
+		return errors.Errorf("Embedded describe resources are missing: %s. "+
+			"Regenerate the embedded assets with `mage generateConstants` and rebuild",
+			strings.Join(missingKeys, ", "))
+	}
+	return nil
+}
+
+func describeResourcePath(resourceKeyName string) string {
+	return fmt.Sprintf("/resources/describe/%s",
 		strings.TrimLeft(resourceKeyName, "/"))
+}
+
+// templateResourceForKeyE returns the custom icon or embedded resource for
+// the key, or an error if the resource isn't available
+func templateResourceForKeyE(resourceKeyName string) (*templateResource, error) {
+	describeRegistryMutex.RLock()
+	svgData, exists := describeCustomIcons[resourceKeyName]
+	describeRegistryMutex.RUnlock()
+	if exists {
+		return &templateResource{
+			KeyName: resourceKeyName,
+			Data:    string(svgData),
+		}, nil
+	}
+	resourcePath := describeResourcePath(resourceKeyName)
 	data, dataErr := _escFSString(false, resourcePath)
-	if dataErr == nil {
-		keyParts := strings.Split(resourcePath, "/")
-		keyName := keyParts[len(keyParts)-1]
-		resource = &templateResource{
-			KeyName: keyName,
-			Data:    data,
-		}
-		logger.WithFields(logrus.Fields{
-			"Path":    resourcePath,
-			"KeyName": keyName,
-		}).Debug("Embedded resource")
+	if dataErr != nil {
+		return nil, errors.Wrapf(dataErr, "Failed to load embedded resource: %s", resourcePath)
+	}
+	keyParts := strings.Split(resourcePath, "/")
+	return &templateResource{
+		KeyName: keyParts[len(keyParts)-1],
+		Data:    data,
+	}, nil
+}
 
-	} else {
+// templateResourceForKey returns the embedded resource for the key, or nil
+// if the resource isn't embedded. Missing resources are logged rather
+// than returned so that the describe output tolerates missing assets.
+func templateResourceForKey(resourceKeyName string, logger *logrus.Logger) *templateResource {
+	resource, resourceErr := templateResourceForKeyE(resourceKeyName)
+	if resourceErr != nil {
 		logger.WithFields(logrus.Fields{
-			"Path": resourcePath,
+			"Path":  describeResourcePath(resourceKeyName),
+			"Error": resourceErr,
 		}).Warn("Failed to embed resource")
+		return nil
 	}
+	logger.WithFields(logrus.Fields{
+		"Path":    describeResourcePath(resourceKeyName),
+		"KeyName": resource.KeyName,
+	}).Debug("Embedded resource")
 	return resource
 }
-func templateResourcesForKeys(resourceKeyNames []string, logger *logrus.Logger) []*templateResource {
-	var resources []*templateResource
 
+// templateResourcesForKeys returns the embedded resources for the keys. Every
+// resource that is embedded is returned, together with the first error
+// for a resource that isn't.
+func templateResourcesForKeys(resourceKeyNames []string, logger *logrus.Logger) ([]*templateResource, error) {
+	var resources []*templateResource
+	var firstErr error
 	for _, eachKey := range resourceKeyNames {
 		loadedResource := templateResourceForKey(eachKey, logger)
 		if loadedResource != nil {
 			resources = append(resources, loadedResource)
+		} else if firstErr == nil {
+			firstErr = errors.Errorf("Failed to load embedded resource: %s",
+				describeResourcePath(eachKey))
 		}
 	}
-	return resources
+	return resources, firstErr
 }
 
 func templateCSSFiles(logger *logrus.Logger) []*templateResource {
 	cssFiles := []string{"bootstrap-4.0.0/dist/css/bootstrap.min.css",
 		"highlight.js/styles/xcode.css",
 	}
-	return templateResourcesForKeys(cssFiles, logger)
+	// Missing assets are logged by templateResourcesForKeys
+	resources, _ := templateResourcesForKeys(cssFiles, logger)
+	return resources
 }
 
 func templateJSFiles(logger *logrus.Logger) []*templateResource {
@@ -168,7 +952,8 @@ func templateJSFiles(logger *logrus.Logger) []*templateResource {
 		"cytoscape.js-dagre/cytoscape-dagre.js",
 		"sparta.js",
 	}
-	return templateResourcesForKeys(jsFiles, logger)
+	resources, _ := templateResourcesForKeys(jsFiles, logger)
+	return resources
 }
 
 func templateImageMap(logger *logrus.Logger) map[string]string {
@@ -176,7 +961,7 @@ func templateImageMap(logger *logrus.Logger) map[string]string {
 		"AWS-Architecture-Icons_SVG_20200131/SVG Light/Compute/AWS-Lambda_Lambda-Function_light-bg.svg",
 		"AWS-Architecture-Icons_SVG_20200131/SVG Light/Management & Governance/AWS-CloudFormation_light-bg.svg",
 	}
-	resources := templateResourcesForKeys(images, logger)
+	resources, _ := templateResourcesForKeys(images, logger)
 	imageMap := make(map[string]string)
 	for _, eachResource := range resources {
 		imageMap[eachResource.KeyName] = base64.StdEncoding.EncodeToString([]byte(eachResource.Data))
@@ -184,29 +969,128 @@ func templateImageMap(logger *logrus.Logger) map[string]string {
 	return imageMap
 }
 
-// TODO - this should really be smarter, including
-// looking at the referred resource to understand it's
-// type
+// iconMappings are the icon paths keyed by the lowercase substring that
+// identifies the AWS service in the marshalled resource
+var iconMappings = map[string]string{
+	"dynamodb":   "AWS-Architecture-Icons_SVG_20200131/SVG Light/Database/Amazon-DynamoDB_Table_light-bg.svg",
+	"sqs":        "AWS-Architecture-Icons_SVG_20200131/SVG Light/Application Integration/Amazon-Simple-Queue-Service-SQS_light-bg.svg",
+	"sns":        "AWS-Architecture-Icons_SVG_20200131/SVG Light/Application Integration/Amazon-Simple-Notification-Service-SNS_light-bg.svg",
+	"cloudwatch": "AWS-Architecture-Icons_SVG_20200131/SVG Light/Management & Governance/Amazon-CloudWatch.svg",
+	"kinesis":    "AWS-Architecture-Icons_SVG_20200131/SVG Light/Analytics/Amazon-Kinesis_light-bg.svg",
+	//lint:ignore ST1018 This is the name of the icon
+	"s3":                  "AWS-Architecture-Icons_SVG_20200131/SVG Light/Storage/Amazon-Simple-Storage-Service-S3.svg",
+	"codecommit":          "AWS-Architecture-Icons_SVG_20200131/SVG Light/Developer Tools/AWS-CodeCommit_light-bg.svg",
+	"stepfunctions":       "AWS-Architecture-Icons_SVG_20200131/SVG Light/Application Integration/AWS-Step-Functions_light-bg.svg",
+	":states:":            "AWS-Architecture-Icons_SVG_20200131/SVG Light/Application Integration/AWS-Step-Functions_light-bg.svg",
+	"aws::events::":       "AWS-Architecture-Icons_SVG_20200131/SVG Light/Application Integration/Amazon-EventBridge_light-bg.svg",
+	":events:":            "AWS-Architecture-Icons_SVG_20200131/SVG Light/Application Integration/Amazon-EventBridge_light-bg.svg",
+	"apigateway":          "AWS-Architecture-Icons_SVG_20200131/SVG Light/Mobile/Amazon-API-Gateway_light-bg.svg",
+	":execute-api:":       "AWS-Architecture-Icons_SVG_20200131/SVG Light/Mobile/Amazon-API-Gateway_light-bg.svg",
+	"secretsmanager":      "AWS-Architecture-Icons_SVG_20200131/SVG Light/Security, Identity, & Compliance/AWS-Secrets-Manager_light-bg.svg",
+	"aws::ssm::parameter": "AWS-Architecture-Icons_SVG_20200131/SVG Light/Management & Governance/AWS-Systems-Manager_Parameter-Store_light-bg.svg",
+	":ssm:":               "AWS-Architecture-Icons_SVG_20200131/SVG Light/Management & Governance/AWS-Systems-Manager_Parameter-Store_light-bg.svg",
+	"aws::rds::":          "AWS-Architecture-Icons_SVG_20200131/SVG Light/Database/Amazon-RDS_light-bg.svg",
+	":rds:":               "AWS-Architecture-Icons_SVG_20200131/SVG Light/Database/Amazon-RDS_light-bg.svg",
+	"elasticache":         "AWS-Architecture-Icons_SVG_20200131/SVG Light/Database/Amazon-ElastiCache_light-bg.svg",
+	"aws::efs::":          "AWS-Architecture-Icons_SVG_20200131/SVG Light/Storage/Amazon-Elastic-File-System_EFS_light-bg.svg",
+	":elasticfilesystem:": "AWS-Architecture-Icons_SVG_20200131/SVG Light/Storage/Amazon-Elastic-File-System_EFS_light-bg.svg",
+	"aws::logs::":         "AWS-Architecture-Icons_SVG_20200131/SVG Light/Management & Governance/Amazon-CloudWatch.svg",
+}
+
+// iconMappingKeys are the iconMappings keys in match precedence order.
+// Longer keys are more specific and are tested first. Keys of the same
+// length are ordered alphabetically.
+var iconMappingKeys = sortedIconMappingKeys()
+
+func sortedIconMappingKeys() []string {
+	keys := make([]string, 0, len(iconMappings))
+	for eachKey := range iconMappings {
+		keys = append(keys, eachKey)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// cloudFormationResourceType returns the CloudFormation resource Type
+// (eg, AWS::DynamoDB::Table) of the marshalled resource, or the empty
+// string if it doesn't define one
+func cloudFormationResourceType(jsonBytes []byte) string {
+	resource := struct {
+		Type interface{} `json:"Type"`
+	}{}
+	unmarshalErr := json.Unmarshal(jsonBytes, &resource)
+	if unmarshalErr != nil {
+		return ""
+	}
+	resourceType, isString := resource.Type.(string)
+	if !isString || !strings.HasPrefix(resourceType, "AWS::") {
+		return ""
+	}
+	return resourceType
+}
+
+// eventSourceResourceTypes are the CloudFormation resource types of the
+// event sources, keyed by the ARN service name
+var eventSourceResourceTypes = map[string]string{
+	"dynamodb": "AWS::DynamoDB::Table",
+	"kafka":    "AWS::MSK::Cluster",
+	"kinesis":  "AWS::Kinesis::Stream",
+	"sqs":      "AWS::SQS::Queue",
+}
+
+// eventSourceResourceType returns the CloudFormation resource type of an
+// EventSourceMapping's literal EventSourceArn, or the empty string if the
+// ARN is dynamic or the service isn't known
+func eventSourceResourceType(eventSourceArn interface{}) string {
+	arnValue, isString := eventSourceArn.(string)
+	if !isString {
+		return ""
+	}
+	arnParts := strings.SplitN(arnValue, ":", 4)
+	if len(arnParts) < 4 || arnParts[0] != "arn" {
+		return ""
+	}
+	return eventSourceResourceTypes[arnParts[2]]
+}
+
+// iconForAWSResourceType returns the icon for a resource of the given
+// CloudFormation resource type. Only the type is matched, so resource
+// names that mention other services don't change the icon. If the type
+// isn't known the icon is resolved from the emitter.
+func iconForAWSResourceType(resourceType string, rawEmitter interface{}) string {
+	if resourceType == "" {
+		return iconForAWSResource(rawEmitter)
+	}
+	return iconForCanonicalResource(strings.ToLower(resourceType))
+}
+
+// iconForAWSResource returns the icon for the resource. If the resource
+// defines a CloudFormation Type only the Type is matched. Otherwise the
+// entire marshalled resource is searched, which can produce false
+// positives for resources that refer to other services.
 func iconForAWSResource(rawEmitter interface{}) string {
 	jsonBytes, jsonBytesErr := json.Marshal(rawEmitter)
 	if jsonBytesErr != nil {
 		jsonBytes = make([]byte, 0)
 	}
 	canonicalRaw := strings.ToLower(string(jsonBytes))
-	iconMappings := map[string]string{
-		"dynamodb":   "AWS-Architecture-Icons_SVG_20200131/SVG Light/Database/Amazon-DynamoDB_Table_light-bg.svg",
-		"sqs":        "AWS-Architecture-Icons_SVG_20200131/SVG Light/Application Integration/Amazon-Simple-Queue-Service-SQS_light-bg.svg",
-		"sns":        "AWS-Architecture-Icons_SVG_20200131/SVG Light/Application Integration/Amazon-Simple-Notification-Service-SNS_light-bg.svg",
-		"cloudwatch": "AWS-Architecture-Icons_SVG_20200131/SVG Light/Management & Governance/Amazon-CloudWatch.svg",
-		"kinesis":    "AWS-Architecture-Icons_SVG_20200131/SVG Light/Analytics/Amazon-Kinesis_light-bg.svg",
-		//lint:ignore ST1018 This is the name of the icon
-		"s3": "AWS-Architecture-Icons_SVG_20200131/SVG Light/Storage/Amazon-Simple-Storage-Service-S3.svg",
-		"codecommit": "AWS-Architecture-Icons_SVG_20200131/SVG Light/Developer Tools/AWS-CodeCommit_light-bg.svg",
-	}
-	// Return it if we have it...
-	for eachKey, eachPath := range iconMappings {
+	if resourceType := cloudFormationResourceType(jsonBytes); resourceType != "" {
+		canonicalRaw = strings.ToLower(resourceType)
+	}
+	return iconForCanonicalResource(canonicalRaw)
+}
+
+// iconForCanonicalResource returns the icon for the first iconMappings key
+// in the lowercase canonicalRaw value, or the general icon if none match
+func iconForCanonicalResource(canonicalRaw string) string {
+	for _, eachKey := range iconMappingKeys {
 		if strings.Contains(canonicalRaw, eachKey) {
-			return eachPath
+			return iconMappings[eachKey]
 		}
 	}
 	return "AWS-Architecture-Icons_SVG_20200131/SVG Light/_General/General_light-bg.svg"
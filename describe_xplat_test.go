@@ -0,0 +1,822 @@
+package sparta
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testLambdaIconPath = "AWS-Architecture-Icons_SVG_20200131/SVG Light/Compute/AWS-Lambda_Lambda-Function_light-bg.svg"
+
+func testDescriptionWriter(t *testing.T) *descriptionWriter {
+	logger, loggerErr := NewLogger("info")
+	if loggerErr != nil {
+		t.Fatalf("Failed to create logger: %s", loggerErr)
+	}
+	return &descriptionWriter{
+		nodes:  make([]*cytoscapeNode, 0),
+		logger: logger,
+	}
+}
+
+func testEmbeddedImage(t *testing.T, iconPath string) string {
+	resource := templateResourceForKey(iconPath, testDescriptionWriter(t).logger)
+	if resource == nil {
+		t.Fatalf("Failed to load embedded icon: %s", iconPath)
+	}
+	return fmt.Sprintf("data:image/svg+xml;base64,%s",
+		base64.StdEncoding.EncodeToString([]byte(resource.Data)))
+}
+
+func TestDescribeIconOverride(t *testing.T) {
+	describer := testDescriptionWriter(t)
+	describer.iconOverrides = map[string]string{
+		"MigrationResource": testLambdaIconPath,
+	}
+	writeErr := describer.writeNode("MigrationResource",
+		nodeColorEventSource,
+		iconForAWSResource("dynamodb"))
+	if writeErr != nil {
+		t.Fatalf("Failed to write node: %s", writeErr)
+	}
+	if describer.nodes[0].Data.Image != testEmbeddedImage(t, testLambdaIconPath) {
+		t.Fatalf("Expected icon override to take precedence over type detection")
+	}
+}
+
+func TestDescribeDuplicateNode(t *testing.T) {
+	describer := testDescriptionWriter(t)
+	for i := 0; i != 2; i++ {
+		writeErr := describer.writeNode("Producer", nodeColorEventSource, "")
+		if writeErr != nil {
+			t.Fatalf("Failed to write node: %s", writeErr)
+		}
+	}
+	writeErr := describer.writeNode("Consumer", nodeColorLambda, "")
+	if writeErr != nil {
+		t.Fatalf("Failed to write node: %s", writeErr)
+	}
+	writeErr = describer.writeEdge("Producer", "Consumer", "")
+	if writeErr != nil {
+		t.Fatalf("Failed to write edge: %s", writeErr)
+	}
+	describer.Finalize()
+	producerCount := 0
+	for _, eachNode := range describer.nodes {
+		if !eachNode.isEdge() && eachNode.Data.Label == "Producer" {
+			producerCount++
+			if eachNode.Data.DegreeCentrality != 1 {
+				t.Fatalf("Expected the edge to connect to the single node. Found degree: %d",
+					eachNode.Data.DegreeCentrality)
+			}
+		}
+	}
+	if producerCount != 1 {
+		t.Fatalf("Expected a single Producer node. Found: %d", producerCount)
+	}
+	if len(describer.nodes) != 3 {
+		t.Fatalf("Expected 2 nodes and 1 edge. Found %d elements", len(describer.nodes))
+	}
+}
+
+func TestDescribeNodeFilter(t *testing.T) {
+	describer := testDescriptionWriter(t)
+	describer.nodeFilter = defaultDescribeNodeFilter
+	writeErr := describer.writeNode("Handler", nodeColorLambda, "")
+	if writeErr != nil {
+		t.Fatalf("Failed to write node: %s", writeErr)
+	}
+	resourceTypes := map[string]string{
+		"Table":      "AWS::DynamoDB::Table",
+		"Policy":     "AWS::IAM::Policy",
+		"Permission": "AWS::Lambda::Permission",
+	}
+	for eachName, eachType := range resourceTypes {
+		writeErr = describer.writeResourceNode(eachName, nodeColorEventSource, eachType, eachName)
+		if writeErr != nil {
+			t.Fatalf("Failed to write node: %s", writeErr)
+		}
+		writeErr = describer.writeEdge(eachName, "Handler", "")
+		if writeErr != nil {
+			t.Fatalf("Failed to write edge: %s", writeErr)
+		}
+	}
+	// Edges to a filtered node are pruned even if written first. Without a
+	// resource type the emitter's Type is used.
+	writeErr = describer.writeEdge("Handler", "Role", "")
+	if writeErr != nil {
+		t.Fatalf("Failed to write edge: %s", writeErr)
+	}
+	writeErr = describer.writeResourceNode("Role",
+		nodeColorEventSource,
+		"",
+		map[string]interface{}{"Type": "AWS::IAM::Role"})
+	if writeErr != nil {
+		t.Fatalf("Failed to write node: %s", writeErr)
+	}
+	describer.Finalize()
+
+	labels := make([]string, 0)
+	edgeCount := 0
+	for _, eachNode := range describer.nodes {
+		if eachNode.isEdge() {
+			edgeCount++
+		} else {
+			labels = append(labels, eachNode.Data.Label)
+		}
+	}
+	if len(labels) != 2 || labels[0] != "Handler" || labels[1] != "Table" {
+		t.Fatalf("Expected only the Handler and Table nodes. Found: %v", labels)
+	}
+	if edgeCount != 1 {
+		t.Fatalf("Expected dangling edges to be dropped. Found %d edges", edgeCount)
+	}
+}
+
+func TestDescribeOrphanedNodes(t *testing.T) {
+	describer := testDescriptionWriter(t)
+	for _, eachNode := range []string{"Producer", "Consumer", "Isolated"} {
+		writeErr := describer.writeNode(eachNode, nodeColorEventSource, "")
+		if writeErr != nil {
+			t.Fatalf("Failed to write node: %s", writeErr)
+		}
+	}
+	writeErr := describer.writeEdge("Producer", "Consumer", "")
+	if writeErr != nil {
+		t.Fatalf("Failed to write edge: %s", writeErr)
+	}
+	orphans := describer.orphanedNodes()
+	if len(orphans) != 1 || orphans[0] != "Isolated" {
+		t.Fatalf("Expected only the isolated node to be orphaned. Found: %v", orphans)
+	}
+}
+
+func TestDescribeStats(t *testing.T) {
+	describer := testDescriptionWriter(t)
+	nodeCount, edgeCount := describer.Stats()
+	if nodeCount != 0 || edgeCount != 0 {
+		t.Fatalf("Expected an empty graph. Found %d nodes and %d edges", nodeCount, edgeCount)
+	}
+	for _, eachNode := range []string{"Producer", "Queue", "Consumer", "Isolated"} {
+		writeErr := describer.writeNode(eachNode, nodeColorEventSource, "")
+		if writeErr != nil {
+			t.Fatalf("Failed to write node: %s", writeErr)
+		}
+	}
+	for _, eachEdge := range [][]string{{"Producer", "Queue"}, {"Queue", "Consumer"}} {
+		writeErr := describer.writeEdge(eachEdge[0], eachEdge[1], "")
+		if writeErr != nil {
+			t.Fatalf("Failed to write edge: %s", writeErr)
+		}
+	}
+	writeErr := describer.writeTypedEdge("Consumer", "Producer", EdgeKindTriggers, "")
+	if writeErr != nil {
+		t.Fatalf("Failed to write edge: %s", writeErr)
+	}
+	nodeCount, edgeCount = describer.Stats()
+	if nodeCount != 4 || edgeCount != 3 {
+		t.Fatalf("Expected 4 nodes and 3 edges. Found %d nodes and %d edges", nodeCount, edgeCount)
+	}
+}
+
+func TestDescribeMissingEmbeddedResource(t *testing.T) {
+	if err := verifyEmbeddedResources(describeCriticalResources); err != nil {
+		t.Fatalf("Expected critical resources to be embedded: %s", err)
+	}
+	missingErr := verifyEmbeddedResources([]string{"sparta.js", "missing/critical.js"})
+	if missingErr == nil {
+		t.Fatalf("Expected error for missing embedded resource")
+	}
+	if !strings.Contains(missingErr.Error(), "missing/critical.js") ||
+		strings.Contains(missingErr.Error(), "sparta.js") {
+		t.Fatalf("Expected error to name only the missing resource: %s", missingErr)
+	}
+}
+
+func TestDescribeWriteDOT(t *testing.T) {
+	describer := testDescriptionWriter(t)
+	for _, eachNode := range []string{"Producer", "Consumer \"v2\" Queue"} {
+		writeErr := describer.writeNode(eachNode, nodeColorLambda, "")
+		if writeErr != nil {
+			t.Fatalf("Failed to write node: %s", writeErr)
+		}
+	}
+	writeErr := describer.writeEdge("Producer", "Consumer \"v2\" Queue", "invokes")
+	if writeErr != nil {
+		t.Fatalf("Failed to write edge: %s", writeErr)
+	}
+	var output bytes.Buffer
+	dotErr := describer.WriteDOT(&output)
+	if dotErr != nil {
+		t.Fatalf("Failed to write DOT: %s", dotErr)
+	}
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if lines[0] != "digraph sparta {" || lines[len(lines)-1] != "}" {
+		t.Fatalf("Expected digraph. Found:\n%s", output.String())
+	}
+	dotID := `"(?:[^"\\]|\\.)*"`
+	reNode := regexp.MustCompile(`^  (` + dotID + `) \[label=(` + dotID + `), shape=box, style=filled, fillcolor="#F35B05"\];$`)
+	reEdge := regexp.MustCompile(`^  (` + dotID + `) -> (` + dotID + `) \[label="invokes"\];$`)
+	nodeIDs := make(map[string]string)
+	edgeCount := 0
+	for _, eachLine := range lines[1 : len(lines)-1] {
+		if nodeMatch := reNode.FindStringSubmatch(eachLine); nodeMatch != nil {
+			nodeIDs[nodeMatch[1]] = nodeMatch[2]
+		} else if edgeMatch := reEdge.FindStringSubmatch(eachLine); edgeMatch != nil {
+			if nodeIDs[edgeMatch[1]] != `"Producer"` ||
+				nodeIDs[edgeMatch[2]] != `"Consumer \"v2\" Queue"` {
+				t.Fatalf("Edge references unexpected nodes: %s", eachLine)
+			}
+			edgeCount++
+		} else {
+			t.Fatalf("Unexpected DOT statement: %s", eachLine)
+		}
+	}
+	if len(nodeIDs) != 2 || edgeCount != 1 {
+		t.Fatalf("Expected 2 nodes and 1 edge. Found:\n%s", output.String())
+	}
+}
+
+func TestDescribeEdgeID(t *testing.T) {
+	edgeIDs := make([]string, 0)
+	for i := 0; i != 2; i++ {
+		describer := testDescriptionWriter(t)
+		writeErr := describer.writeEdge("Producer", "Consumer", "invokes")
+		if writeErr != nil {
+			t.Fatalf("Failed to write edge: %s", writeErr)
+		}
+		edgeIDs = append(edgeIDs, describer.nodes[0].Data.ID)
+	}
+	if edgeIDs[0] != edgeIDs[1] {
+		t.Fatalf("Expected identical edge IDs. Found: %v", edgeIDs)
+	}
+	// A different label is a different edge
+	describer := testDescriptionWriter(t)
+	writeErr := describer.writeEdge("Producer", "Consumer", "subscribes")
+	if writeErr != nil {
+		t.Fatalf("Failed to write edge: %s", writeErr)
+	}
+	if describer.nodes[0].Data.ID == edgeIDs[0] {
+		t.Fatalf("Expected edges with different labels to have different IDs")
+	}
+}
+
+func TestDescribeDuplicateEdge(t *testing.T) {
+	describer := testDescriptionWriter(t)
+	for _, eachNode := range []string{"Queue", "Consumer"} {
+		writeErr := describer.writeNode(eachNode, nodeColorEventSource, "")
+		if writeErr != nil {
+			t.Fatalf("Failed to write node: %s", writeErr)
+		}
+	}
+	// Two event source mappings from the same queue
+	for i := 0; i != 2; i++ {
+		writeErr := describer.writeEdge("Queue", "Consumer", "")
+		if writeErr != nil {
+			t.Fatalf("Failed to write edge: %s", writeErr)
+		}
+	}
+	// The same endpoints with a different kind is a different edge
+	writeErr := describer.writeTypedEdge("Queue", "Consumer", EdgeKindTriggers, "")
+	if writeErr != nil {
+		t.Fatalf("Failed to write edge: %s", writeErr)
+	}
+	var output bytes.Buffer
+	writeErr = describer.WriteJSON(&output)
+	if writeErr != nil {
+		t.Fatalf("Failed to write JSON: %s", writeErr)
+	}
+	var elements []map[string]interface{}
+	unmarshalErr := json.Unmarshal(output.Bytes(), &elements)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal JSON: %s", unmarshalErr)
+	}
+	elementIDs := make(map[string]bool)
+	for _, eachElement := range elements {
+		elementID := eachElement["data"].(map[string]interface{})["id"].(string)
+		if elementIDs[elementID] {
+			t.Fatalf("Duplicate element ID: %s", elementID)
+		}
+		elementIDs[elementID] = true
+	}
+	if len(elements) != 4 {
+		t.Fatalf("Expected 2 nodes and 2 edges. Found %d elements", len(elements))
+	}
+}
+
+func TestDescribeTypedEdge(t *testing.T) {
+	describer := testDescriptionWriter(t)
+	writeErr := describer.writeTypedEdge("Producer", "Topic", EdgeKindPublishesTo, "notifies")
+	if writeErr != nil {
+		t.Fatalf("Failed to write edge: %s", writeErr)
+	}
+	writeErr = describer.writeEdge("Topic", "Consumer", "subscribes")
+	if writeErr != nil {
+		t.Fatalf("Failed to write edge: %s", writeErr)
+	}
+	var output bytes.Buffer
+	writeErr = describer.WriteJSON(&output)
+	if writeErr != nil {
+		t.Fatalf("Failed to write JSON: %s", writeErr)
+	}
+	var elements []map[string]interface{}
+	unmarshalErr := json.Unmarshal(output.Bytes(), &elements)
+	if unmarshalErr != nil {
+		t.Fatalf("Failed to unmarshal JSON: %s", unmarshalErr)
+	}
+	classes := make(map[string]interface{})
+	for _, eachElement := range elements {
+		data := eachElement["data"].(map[string]interface{})
+		classes[data["label"].(string)] = eachElement["classes"]
+	}
+	if classes["notifies"] != string(EdgeKindPublishesTo) {
+		t.Fatalf("Expected typed edge class %s. Found: %v", EdgeKindPublishesTo, classes["notifies"])
+	}
+	if classes["subscribes"] != nil {
+		t.Fatalf("Expected untyped edge to omit classes. Found: %v", classes["subscribes"])
+	}
+	writeErr = describer.writeTypedEdge("Producer", "Topic", EdgeKind("calls"), "")
+	if writeErr == nil {
+		t.Fatalf("Expected an error for an unsupported edge kind")
+	}
+}
+
+func TestDescribeLayoutDirection(t *testing.T) {
+	for _, eachDirection := range []string{"", DescribeLayoutLeftToRight} {
+		describer := testDescriptionWriter(t)
+		describer.layoutDirection = eachDirection
+		var output bytes.Buffer
+		writeErr := describer.WriteMetaJSON(&output)
+		if writeErr != nil {
+			t.Fatalf("Failed to write metadata: %s", writeErr)
+		}
+		var meta map[string]interface{}
+		unmarshalErr := json.Unmarshal(output.Bytes(), &meta)
+		if unmarshalErr != nil {
+			t.Fatalf("Failed to unmarshal metadata: %s", unmarshalErr)
+		}
+		expected := eachDirection
+		if expected == "" {
+			expected = DescribeLayoutTopToBottom
+		}
+		if meta["layoutDirection"] != expected {
+			t.Fatalf("Expected layoutDirection %s. Found: %v", expected, meta["layoutDirection"])
+		}
+	}
+	if SetDescribeLayoutDirection("diagonal") == nil {
+		t.Fatalf("Expected an error for an unsupported layout direction")
+	}
+	template := _escFSMustString(false, "/resources/describe/template.html")
+	if !strings.Contains(template, "{{ .CytoscapeMeta }}") {
+		t.Fatalf("Expected the describe template to include the layout metadata")
+	}
+}
+
+func TestDescribeDarkThemeNodeColors(t *testing.T) {
+	if themedNodeColor(nodeColorLambda, DescribeThemeLight) != nodeColorLambda {
+		t.Fatalf("Expected light theme to use the default node color")
+	}
+	if themedNodeColor(nodeColorLambda, DescribeThemeDark) == nodeColorLambda {
+		t.Fatalf("Expected dark theme to use an alternative node color")
+	}
+	// Only the node colors change. Every theme uses the embedded light icons.
+	describer := testDescriptionWriter(t)
+	describer.theme = DescribeThemeDark
+	writeErr := describer.writeNode("Lambda", nodeColorLambda, testLambdaIconPath)
+	if writeErr != nil {
+		t.Fatalf("Failed to write node: %s", writeErr)
+	}
+	if describer.nodes[0].Data.Image != testEmbeddedImage(t, testLambdaIconPath) {
+		t.Fatalf("Expected dark theme node to use the embedded icon")
+	}
+	if SetDescribeTheme("sepia") == nil {
+		t.Fatalf("Expected error for unsupported theme")
+	}
+}
+
+func TestDescribeIconMappings(t *testing.T) {
+	testCases := []struct {
+		emitter  interface{}
+		expected string
+	}{
+		{"arn:aws:states:us-east-1:123456789012:stateMachine:Workflow",
+			"Application Integration/AWS-Step-Functions_light-bg.svg"},
+		{"arn:aws:events:us-east-1:123456789012:rule/Nightly",
+			"Application Integration/Amazon-EventBridge_light-bg.svg"},
+		{"arn:aws:execute-api:us-east-1:123456789012:abc123/*",
+			"Mobile/Amazon-API-Gateway_light-bg.svg"},
+		{"arn:aws:secretsmanager:us-east-1:123456789012:secret:DatabasePassword",
+			"Security, Identity, & Compliance/AWS-Secrets-Manager_light-bg.svg"},
+		{"arn:aws:ssm:us-east-1:123456789012:parameter/config",
+			"Management & Governance/AWS-Systems-Manager_Parameter-Store_light-bg.svg"},
+		{"arn:aws:rds:us-east-1:123456789012:db:orders",
+			"Database/Amazon-RDS_light-bg.svg"},
+		{"arn:aws:elasticache:us-east-1:123456789012:cluster:sessions",
+			"Database/Amazon-ElastiCache_light-bg.svg"},
+		{"arn:aws:elasticfilesystem:us-east-1:123456789012:file-system/fs-1234",
+			"Storage/Amazon-Elastic-File-System_EFS_light-bg.svg"},
+		// Matches both "cloudwatch" and "s3"; the longer key wins
+		{"arn:aws:logs:us-east-1:123456789012:log-group:cloudwatch-s3-export",
+			"Management & Governance/Amazon-CloudWatch.svg"},
+		// Matches both ":states:" and "dynamodb"; equal length keys are
+		// ordered alphabetically
+		{"arn:aws:states:us-east-1:123456789012:stateMachine:dynamodbLoader",
+			"Application Integration/AWS-Step-Functions_light-bg.svg"},
+	}
+	for _, eachTestCase := range testCases {
+		// Run it more than once to ensure the precedence doesn't depend
+		// on map iteration order
+		for i := 0; i != 10; i++ {
+			iconPath := iconForAWSResource(eachTestCase.emitter)
+			if !strings.HasSuffix(iconPath, "/SVG Light/"+eachTestCase.expected) {
+				t.Fatalf("Expected %v to resolve to %s. Found: %s",
+					eachTestCase.emitter,
+					eachTestCase.expected,
+					iconPath)
+			}
+		}
+	}
+	// Every mapped icon must be embedded
+	for eachKey, eachPath := range iconMappings {
+		if templateResourceForKey(eachPath, testDescriptionWriter(t).logger) == nil {
+			t.Fatalf("Icon for %s isn't embedded: %s", eachKey, eachPath)
+		}
+	}
+}
+
+func TestDescribeIconResourceType(t *testing.T) {
+	queueResource := map[string]interface{}{
+		"Type": "AWS::SQS::Queue",
+		"Properties": map[string]interface{}{
+			"QueueName": "ExportNotifications",
+			"Tags": []map[string]string{
+				{"Key": "Source", "Value": "arn:aws:s3:::dynamodb-exports"},
+			},
+		},
+	}
+	iconPath := iconForAWSResource(queueResource)
+	if iconPath != iconMappings["sqs"] {
+		t.Fatalf("Expected SQS icon for SQS resource. Found: %s", iconPath)
+	}
+	// Without a Type the entire resource is searched
+	delete(queueResource, "Type")
+	iconPath = iconForAWSResource(queueResource)
+	if iconPath != iconMappings["dynamodb"] {
+		t.Fatalf("Expected substring match without a Type. Found: %s", iconPath)
+	}
+	// Unknown types use the general icon rather than matching properties
+	unknownResource := map[string]interface{}{
+		"Type": "AWS::IAM::Role",
+		"Properties": map[string]interface{}{
+			"RoleName": "SQSReader",
+		},
+	}
+	iconPath = iconForAWSResource(unknownResource)
+	if !strings.HasSuffix(iconPath, "_General/General_light-bg.svg") {
+		t.Fatalf("Expected general icon for unmapped Type. Found: %s", iconPath)
+	}
+}
+
+func TestDescribeEventSourceResourceType(t *testing.T) {
+	testCases := map[string]interface{}{
+		"AWS::DynamoDB::Table": "arn:aws:dynamodb:us-west-2:000000000000:table/sampleTable/stream/2020-01-01T00:00:00.000",
+		"AWS::Kinesis::Stream": "arn:aws:kinesis:us-west-2:000000000000:stream/sqs-archive",
+		"AWS::SQS::Queue":      "arn:aws:sqs:us-west-2:000000000000:dynamodb-retries",
+		"":                     map[string]interface{}{"Fn::GetAtt": []string{"Queue", "Arn"}},
+	}
+	for eachType, eachArn := range testCases {
+		resourceType := eventSourceResourceType(eachArn)
+		if resourceType != eachType {
+			t.Fatalf("Expected %v to be a %s. Found: %s", eachArn, eachType, resourceType)
+		}
+	}
+	// The type takes precedence over service names in the ARN
+	iconPath := iconForAWSResourceType("AWS::SQS::Queue",
+		testCases["AWS::SQS::Queue"])
+	if iconPath != iconMappings["sqs"] {
+		t.Fatalf("Expected SQS icon. Found: %s", iconPath)
+	}
+}
+
+func TestDescribeDegreeCentrality(t *testing.T) {
+	describer := testDescriptionWriter(t)
+	for _, eachNode := range []string{"Service", "Lambda", "Queue", "Isolated"} {
+		writeErr := describer.writeNode(eachNode, nodeColorLambda, "")
+		if writeErr != nil {
+			t.Fatalf("Failed to write node: %s", writeErr)
+		}
+	}
+	edges := [][]string{
+		{"Lambda", "Service"},
+		{"Queue", "Lambda"},
+		{"Lambda", "Queue"},
+	}
+	for _, eachEdge := range edges {
+		writeErr := describer.writeEdge(eachEdge[0], eachEdge[1], "")
+		if writeErr != nil {
+			t.Fatalf("Failed to write edge: %s", writeErr)
+		}
+	}
+	describer.Finalize()
+	expected := map[string]int{
+		"Service":  1,
+		"Lambda":   3,
+		"Queue":    2,
+		"Isolated": 0,
+	}
+	for _, eachNode := range describer.nodes {
+		if eachNode.isEdge() {
+			continue
+		}
+		if eachNode.Data.DegreeCentrality != expected[eachNode.Data.Label] {
+			t.Fatalf("Expected %s DegreeCentrality %d. Found: %d",
+				eachNode.Data.Label,
+				expected[eachNode.Data.Label],
+				eachNode.Data.DegreeCentrality)
+		}
+	}
+}
+
+func TestDescribeCustomIcon(t *testing.T) {
+	iconName := "custom/OrderService.svg"
+	svgData := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="8" height="8"><rect width="8" height="8"/></svg>`)
+	registerErr := RegisterDescribeIcon(iconName, svgData)
+	if registerErr != nil {
+		t.Fatalf("Failed to register icon: %s", registerErr)
+	}
+	defer ResetDescribeRegistries()
+	if RegisterDescribeIcon(iconName, svgData) == nil {
+		t.Fatalf("Expected an error registering a duplicate icon")
+	}
+
+	describer := testDescriptionWriter(t)
+	describer.iconResolver = func(rawEmitter interface{}) string {
+		return iconName
+	}
+	writeErr := describer.writeResourceNode("Orders", nodeColorService, "", "orders")
+	if writeErr != nil {
+		t.Fatalf("Failed to write node: %s", writeErr)
+	}
+	expected := "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString(svgData)
+	if describer.nodes[0].Data.Image != expected {
+		t.Fatalf("Expected custom icon to be embedded. Found: %s", describer.nodes[0].Data.Image)
+	}
+}
+
+func TestDescribeCustomIconFromFileSystem(t *testing.T) {
+	iconDir, iconDirErr := ioutil.TempDir("", "describe-icons")
+	if iconDirErr != nil {
+		t.Fatalf("Failed to create temp dir: %s", iconDirErr)
+	}
+	defer os.RemoveAll(iconDir)
+	svgData := []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`)
+	writeErr := ioutil.WriteFile(filepath.Join(iconDir, "glyph.svg"), svgData, 0644)
+	if writeErr != nil {
+		t.Fatalf("Failed to write icon: %s", writeErr)
+	}
+	registerErr := RegisterDescribeIconFromFileSystem("glyph", http.Dir(iconDir), "/glyph.svg")
+	if registerErr != nil {
+		t.Fatalf("Failed to register icon: %s", registerErr)
+	}
+	defer ResetDescribeRegistries()
+	resource, resourceErr := templateResourceForKeyE("glyph")
+	if resourceErr != nil || resource.Data != string(svgData) {
+		t.Fatalf("Expected registered icon. Found: %v, %v", resource, resourceErr)
+	}
+	if RegisterDescribeIconFromFileSystem("missing", http.Dir(iconDir), "/missing.svg") == nil {
+		t.Fatalf("Expected an error for a missing icon")
+	}
+}
+
+func TestDescribeWriteJSON(t *testing.T) {
+	outputs := make([]string, 0)
+	for _, eachOrder := range [][]string{{"Producer", "Consumer"}, {"Consumer", "Producer"}} {
+		describer := testDescriptionWriter(t)
+		for _, eachNode := range eachOrder {
+			writeErr := describer.writeNode(eachNode, nodeColorLambda, "")
+			if writeErr != nil {
+				t.Fatalf("Failed to write node: %s", writeErr)
+			}
+		}
+		writeErr := describer.writeEdge("Producer", "Consumer", "invokes")
+		if writeErr != nil {
+			t.Fatalf("Failed to write edge: %s", writeErr)
+		}
+		describer.Finalize()
+		var output bytes.Buffer
+		jsonErr := describer.WriteJSON(&output)
+		if jsonErr != nil {
+			t.Fatalf("Failed to write JSON: %s", jsonErr)
+		}
+		outputs = append(outputs, output.String())
+
+		var roundTrip []*cytoscapeNode
+		unmarshalErr := json.Unmarshal(output.Bytes(), &roundTrip)
+		if unmarshalErr != nil {
+			t.Fatalf("Failed to unmarshal JSON: %s", unmarshalErr)
+		}
+		if len(roundTrip) != 3 {
+			t.Fatalf("Expected 3 elements. Found: %d", len(roundTrip))
+		}
+		if roundTrip[0].isEdge() || roundTrip[1].isEdge() || !roundTrip[2].isEdge() {
+			t.Fatalf("Expected nodes before edges: %s", output.String())
+		}
+		if roundTrip[2].Data.Label != "invokes" ||
+			roundTrip[0].Data.DegreeCentrality != 1 {
+			t.Fatalf("Unexpected round trip values: %s", output.String())
+		}
+	}
+	if outputs[0] != outputs[1] {
+		t.Fatalf("Expected identical output.\nFirst: %s\nSecond: %s", outputs[0], outputs[1])
+	}
+}
+
+func TestDescribeNodeColor(t *testing.T) {
+	describer := testDescriptionWriter(t)
+	for _, eachColor := range []string{nodeColorService, "#123ABC", ""} {
+		writeErr := describer.writeNode("Node"+eachColor, eachColor, "")
+		if writeErr != nil {
+			t.Fatalf("Failed to write node: %s", writeErr)
+		}
+		appendedNode := describer.nodes[len(describer.nodes)-1]
+		if appendedNode.Data.BackgroundColor != eachColor {
+			t.Fatalf("Expected BackgroundColor %s. Found: %s",
+				eachColor,
+				appendedNode.Data.BackgroundColor)
+		}
+	}
+	describer.theme = DescribeThemeDark
+	writeErr := describer.writeNode("DarkNode", nodeColorService, "")
+	if writeErr != nil {
+		t.Fatalf("Failed to write node: %s", writeErr)
+	}
+	darkColor := describer.nodes[len(describer.nodes)-1].Data.BackgroundColor
+	if darkColor != nodeColorsDark[nodeColorService] {
+		t.Fatalf("Expected dark theme BackgroundColor. Found: %s", darkColor)
+	}
+}
+
+func TestDescribeIconResolver(t *testing.T) {
+	describer := testDescriptionWriter(t)
+	describer.iconResolver = func(rawEmitter interface{}) string {
+		if rawEmitter == "Custom::Migration" {
+			return testLambdaIconPath
+		}
+		return ""
+	}
+	writeErr := describer.writeNode("Migration",
+		nodeColorEventSource,
+		describer.iconForResource("", "Custom::Migration"))
+	if writeErr != nil {
+		t.Fatalf("Failed to write node: %s", writeErr)
+	}
+	if describer.nodes[0].Data.Image != testEmbeddedImage(t, testLambdaIconPath) {
+		t.Fatalf("Expected custom resolver icon to be embedded")
+	}
+	// Empty results fall back to the default resolution
+	defaultIcon := describer.iconForResource("", "arn:aws:sqs:us-east-1:123456789012:Queue")
+	if defaultIcon != iconMappings["sqs"] {
+		t.Fatalf("Expected default icon resolution. Found: %s", defaultIcon)
+	}
+}
+
+func TestDescribeTemplateResourceErrors(t *testing.T) {
+	_, resourceErr := templateResourceForKeyE("missing/nonexistent.svg")
+	if resourceErr == nil {
+		t.Fatalf("Expected error for nonexistent resource")
+	}
+	resource, resourceErr := templateResourceForKeyE("sparta.js")
+	if resourceErr != nil || resource.KeyName != "sparta.js" {
+		t.Fatalf("Expected embedded resource. Found: %#v, %v", resource, resourceErr)
+	}
+	resources, resourcesErr := templateResourcesForKeys([]string{"sparta.js",
+		"missing/nonexistent.svg",
+		"missing/other.svg"},
+		testDescriptionWriter(t).logger)
+	if len(resources) != 1 {
+		t.Fatalf("Expected the embedded resource to be returned. Found: %d", len(resources))
+	}
+	if resourcesErr == nil || !strings.Contains(resourcesErr.Error(), "missing/nonexistent.svg") {
+		t.Fatalf("Expected error for first missing resource. Found: %v", resourcesErr)
+	}
+}
+
+func TestDescribeRenderSVG(t *testing.T) {
+	describer := testDescriptionWriter(t)
+	cloudFormationIconPath := "AWS-Architecture-Icons_SVG_20200131/SVG Light/Management & Governance/AWS-CloudFormation_light-bg.svg"
+	writeErr := describer.writeNode("Service", nodeColorService, cloudFormationIconPath)
+	if writeErr != nil {
+		t.Fatalf("Failed to write node: %s", writeErr)
+	}
+	writeErr = describer.writeNode("Lambda <Handler>", nodeColorLambda, testLambdaIconPath)
+	if writeErr != nil {
+		t.Fatalf("Failed to write node: %s", writeErr)
+	}
+	writeErr = describer.writeEdge("Lambda <Handler>", "Service", "member")
+	if writeErr != nil {
+		t.Fatalf("Failed to write edge: %s", writeErr)
+	}
+	var output bytes.Buffer
+	renderErr := describer.RenderSVG(&output)
+	if renderErr != nil {
+		t.Fatalf("Failed to render SVG: %s", renderErr)
+	}
+	// Walk the document to verify it's well formed and collect the images
+	images := make(map[string]bool)
+	rootName := ""
+	decoder := xml.NewDecoder(&output)
+	for {
+		token, tokenErr := decoder.Token()
+		if tokenErr == io.EOF {
+			break
+		}
+		if tokenErr != nil {
+			t.Fatalf("Invalid SVG: %s", tokenErr)
+		}
+		if startElement, isStart := token.(xml.StartElement); isStart {
+			if rootName == "" {
+				rootName = startElement.Name.Local
+			}
+			if startElement.Name.Local == "image" {
+				for _, eachAttr := range startElement.Attr {
+					if eachAttr.Name.Local == "href" {
+						images[eachAttr.Value] = true
+					}
+				}
+			}
+		}
+	}
+	if rootName != "svg" {
+		t.Fatalf("Expected svg root element. Found: %s", rootName)
+	}
+	for _, eachIconPath := range []string{cloudFormationIconPath, testLambdaIconPath} {
+		if !images[testEmbeddedImage(t, eachIconPath)] {
+			t.Fatalf("Expected SVG to embed icon: %s", eachIconPath)
+		}
+	}
+}
+
+func TestDescribeRegistries(t *testing.T) {
+	defer ResetDescribeRegistries()
+	logger := testDescriptionWriter(t).logger
+
+	// Registrations are safe to make concurrently with Describe
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			nodeName := fmt.Sprintf("Node%d", index)
+			registerErr := RegisterDescribeIconOverride(nodeName, testLambdaIconPath)
+			if registerErr != nil {
+				t.Errorf("Failed to register icon override: %s", registerErr)
+			}
+			newDescriptionWriter(logger)
+		}(i)
+	}
+	wg.Wait()
+	themeErr := SetDescribeTheme(DescribeThemeDark)
+	if themeErr != nil {
+		t.Fatalf("Failed to set theme: %s", themeErr)
+	}
+	filterErr := RegisterDescribeNodeFilter(func(string, string, interface{}) bool {
+		return true
+	})
+	if filterErr != nil {
+		t.Fatalf("Failed to register node filter: %s", filterErr)
+	}
+
+	describer := newDescriptionWriter(logger)
+	if len(describer.iconOverrides) != 8 || describer.theme != DescribeThemeDark {
+		t.Fatalf("Expected the describer to use the registered values. Found: %d overrides, %s theme",
+			len(describer.iconOverrides),
+			describer.theme)
+	}
+
+	ResetDescribeRegistries()
+	describer = newDescriptionWriter(logger)
+	if len(describer.iconOverrides) != 0 ||
+		describer.theme != DescribeThemeLight ||
+		describer.layoutDirection != DescribeLayoutTopToBottom ||
+		describer.iconResolver != nil {
+		t.Fatalf("Expected reset registries to restore the defaults")
+	}
+	// The default node filter hides IAM resources again
+	if describer.nodeFilter("Role", "AWS::IAM::Role", nil) {
+		t.Fatalf("Expected the default node filter after reset")
+	}
+	if RegisterDescribeNodeFilter(defaultDescribeNodeFilter) != nil {
+		t.Fatalf("Expected the node filter to be registrable after reset")
+	}
+}
@@ -19,6 +19,9 @@ type descriptionNode struct {
 	Name     string
 	Relation string
 	Color    string
+	// ResourceType is the CloudFormation resource type (eg, AWS::S3::Bucket)
+	// of the node, used to select its describe icon
+	ResourceType string
 }
 
 // LambdaPermissionExporter defines an interface for polymorphic collection of
@@ -220,8 +223,9 @@ func (perm S3Permission) descriptionInfo() ([]descriptionNode, error) {
 	nodes := make([]descriptionNode, 0)
 	if perm.Filter.Key == nil || len(perm.Filter.Key.FilterRules) == 0 {
 		nodes = append(nodes, descriptionNode{
-			Name:     describeInfoValue(perm.SourceArn),
-			Relation: s3Events,
+			Name:         describeInfoValue(perm.SourceArn),
+			Relation:     s3Events,
+			ResourceType: "AWS::S3::Bucket",
 		})
 	} else {
 		for _, eachFilter := range perm.Filter.Key.FilterRules {
@@ -230,8 +234,9 @@ func (perm S3Permission) descriptionInfo() ([]descriptionNode, error) {
 				*eachFilter.Name,
 				*eachFilter.Value)
 			nodes = append(nodes, descriptionNode{
-				Name:     describeInfoValue(perm.SourceArn),
-				Relation: filterRel,
+				Name:         describeInfoValue(perm.SourceArn),
+				Relation:     filterRel,
+				ResourceType: "AWS::S3::Bucket",
 			})
 		}
 	}
@@ -317,8 +322,9 @@ func (perm SNSPermission) export(serviceName string,
 func (perm SNSPermission) descriptionInfo() ([]descriptionNode, error) {
 	nodes := []descriptionNode{
 		{
-			Name:     describeInfoValue(perm.SourceArn),
-			Relation: "",
+			Name:         describeInfoValue(perm.SourceArn),
+			Relation:     "",
+			ResourceType: "AWS::SNS::Topic",
 		},
 	}
 	return nodes, nil
@@ -692,8 +698,9 @@ func (perm SESPermission) export(serviceName string,
 func (perm SESPermission) descriptionInfo() ([]descriptionNode, error) {
 	nodes := []descriptionNode{
 		{
-			Name:     "SimpleEmailService",
-			Relation: "All verified domain(s) email",
+			Name:         "SimpleEmailService",
+			Relation:     "All verified domain(s) email",
+			ResourceType: "AWS::SES::ReceiptRule",
 		},
 	}
 	return nodes, nil
@@ -886,8 +893,9 @@ func (perm CloudWatchEventsPermission) descriptionInfo() ([]descriptionNode, err
 	}
 	nodes := []descriptionNode{
 		{
-			Name:     "CloudWatch Events",
-			Relation: ruleTriggers,
+			Name:         "CloudWatch Events",
+			Relation:     ruleTriggers,
+			ResourceType: "AWS::Events::Rule",
 		},
 	}
 	return nodes, nil
@@ -1046,8 +1054,9 @@ func (perm EventBridgePermission) descriptionInfo() ([]descriptionNode, error) {
 
 	nodes := []descriptionNode{
 		{
-			Name:     "EventBridge Event",
-			Relation: ruleTriggers,
+			Name:         "EventBridge Event",
+			Relation:     ruleTriggers,
+			ResourceType: "AWS::Events::Rule",
 		},
 	}
 	return nodes, nil
@@ -1210,8 +1219,9 @@ func (perm CloudWatchLogsPermission) descriptionInfo() ([]descriptionNode, error
 	nodeIndex := 0
 	for eachFilterName, eachFilterDef := range perm.Filters {
 		nodes[nodeIndex] = descriptionNode{
-			Name:     describeInfoValue(eachFilterDef.LogGroupName),
-			Relation: fmt.Sprintf("%s (%s)", eachFilterName, eachFilterDef.FilterPattern),
+			Name:         describeInfoValue(eachFilterDef.LogGroupName),
+			Relation:     fmt.Sprintf("%s (%s)", eachFilterName, eachFilterDef.FilterPattern),
+			ResourceType: "AWS::Logs::LogGroup",
 		}
 		nodeIndex++
 	}
@@ -1325,8 +1335,9 @@ func (perm CodeCommitPermission) descriptionInfo() ([]descriptionNode, error) {
 	nodes := make([]descriptionNode, 0)
 	if len(perm.Branches) <= 0 {
 		nodes = append(nodes, descriptionNode{
-			Name:     describeInfoValue(perm.SourceArn),
-			Relation: "all",
+			Name:         describeInfoValue(perm.SourceArn),
+			Relation:     "all",
+			ResourceType: "AWS::CodeCommit::Repository",
 		})
 	} else {
 		for _, eachBranch := range perm.Branches {
@@ -1334,8 +1345,9 @@ func (perm CodeCommitPermission) descriptionInfo() ([]descriptionNode, error) {
 				eachBranch,
 				perm.Events)
 			nodes = append(nodes, descriptionNode{
-				Name:     describeInfoValue(perm.SourceArn),
-				Relation: filterRel,
+				Name:         describeInfoValue(perm.SourceArn),
+				Relation:     filterRel,
+				ResourceType: "AWS::CodeCommit::Repository",
 			})
 		}
 	}
@@ -12,6 +12,22 @@ function showView(newElementID) {
   $(tabID).addClass('active')
 }
 
+// The initial layout honors the layout direction. Breadth first
+// layouts always flow top to bottom, so left to right uses dagre.
+function layoutOptions(layoutType) {
+  var layoutDirection = (typeof CYTOSCAPE_META !== 'undefined' && CYTOSCAPE_META.layoutDirection) || 'TB'
+  if (layoutType === 'breadthfirst' && layoutDirection === 'LR') {
+    layoutType = 'dagre'
+  }
+  var options = {
+    name: layoutType,
+  }
+  if (layoutType === 'dagre') {
+    options.rankDir = layoutDirection
+  }
+  return options
+}
+
 $(document).ready(function () {
   var cloudformationTemplate = null
   try {
@@ -55,9 +71,7 @@ $(document).ready(function () {
           }
         }
       ],
-      layout: {
-        name: 'breadthfirst',
-      }
+      layout: layoutOptions('breadthfirst')
     });
   } catch (err) {
     console.log("Failed to initialize topology view: " + err)
@@ -74,9 +88,7 @@ $(document).ready(function () {
       event.preventDefault();
       var layoutType = eachElement.split('-').pop();
       console.log("Layout type: " + layoutType);
-      cytoscapeView.makeLayout({
-        name: layoutType,
-      }).run();
+      cytoscapeView.makeLayout(layoutOptions(layoutType)).run();
     });
   });
   showView('lambda');